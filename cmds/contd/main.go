@@ -57,12 +57,22 @@ func main() {
 		log.Fatal().Msgf("fail to create module root: %s", err)
 	}
 
+	client, err := zbus.NewRedisClient(msgBrokerCon)
+	if err != nil {
+		log.Fatal().Msgf("fail to connect to message broker server: %v", err)
+	}
+
 	server, err := zbus.NewRedisServer(module, msgBrokerCon, workerNr)
 	if err != nil {
 		log.Fatal().Msgf("fail to connect to message broker server: %v", err)
 	}
 
-	containerd := container.New(moduleRoot, containerdCon)
+	ctx, _ := utils.WithSignal(context.Background())
+	utils.OnDone(ctx, func(_ error) {
+		log.Info().Msg("shutting down")
+	})
+
+	containerd := container.New(ctx, moduleRoot, containerdCon, client)
 
 	server.Register(zbus.ObjectID{Name: module, Version: "0.0.1"}, containerd)
 
@@ -71,11 +81,6 @@ func main() {
 		Uint("worker nr", workerNr).
 		Msg("starting containerd module")
 
-	ctx, _ := utils.WithSignal(context.Background())
-	utils.OnDone(ctx, func(_ error) {
-		log.Info().Msg("shutting down")
-	})
-
 	if err := server.Run(ctx); err != nil && err != context.Canceled {
 		log.Fatal().Err(err).Msg("unexpected error")
 	}
@@ -15,7 +15,10 @@ import (
 	"github.com/threefoldtech/zos/pkg/version"
 )
 
-const module = "flist"
+const (
+	module = "flist"
+	mib    = 1024 * 1024
+)
 
 func main() {
 	app.Initialize()
@@ -24,12 +27,14 @@ func main() {
 		moduleRoot   string
 		msgBrokerCon string
 		workerNr     uint
+		cacheBudget  uint64
 		ver          bool
 	)
 
 	flag.StringVar(&moduleRoot, "root", "/var/cache/modules/flistd", "root working directory of the module")
 	flag.StringVar(&msgBrokerCon, "broker", "unix:///var/run/redis.sock", "connection string to the message broker")
 	flag.UintVar(&workerNr, "workers", 1, "number of workers")
+	flag.Uint64Var(&cacheBudget, "cache-budget", flist.DefaultCacheBudget/mib, "size budget of the flists cache in MiB, 0 means no limit")
 	flag.BoolVar(&ver, "v", false, "show version and exit")
 
 	flag.Parse()
@@ -48,7 +53,7 @@ func main() {
 		log.Fatal().Msgf("fail to connect to message broker server: %v\n", err)
 	}
 
-	flist := flist.New(moduleRoot, storage)
+	flist := flist.New(moduleRoot, storage, cacheBudget*mib)
 	server.Register(zbus.ObjectID{Name: module, Version: "0.0.1"}, flist)
 
 	log.Info().
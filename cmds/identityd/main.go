@@ -202,12 +202,28 @@ func main() {
 
 	// with volume allocation is set to nil this flister can be
 	// only used for RO mounts. Otherwise it will panic
-	flister := flist.New(root, nil)
+	flister := flist.New(root, nil, 0)
+
+	env, err := environment.Get()
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to parse node environment")
+	}
+
+	if env.UpgradePublisher == "" {
+		if env.UpgradeVerified() {
+			// the node keeps running, the upgrader refuses the flists it can't verify
+			log.Error().Str("runmode", env.RunningMode.String()).Msg("no upgrade publisher key, upgrades cannot be verified")
+		} else {
+			log.Warn().Msg("no upgrade publisher key, the signature of the upgrade flists is not verified")
+		}
+	}
 
 	upgrader := upgrade.Upgrader{
-		FLister:      flister,
-		Zinit:        zinit,
-		NoSelfUpdate: debug,
+		FLister:       flister,
+		Zinit:         zinit,
+		NoSelfUpdate:  debug,
+		PublisherKey:  env.UpgradePublisher,
+		AllowUnsigned: !env.UpgradeVerified(),
 	}
 
 	installBinaries(&boot, &upgrader)
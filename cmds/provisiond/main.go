@@ -12,6 +12,7 @@ import (
 	"github.com/threefoldtech/zos/pkg/app"
 	"github.com/threefoldtech/zos/pkg/environment"
 	"github.com/threefoldtech/zos/pkg/provision/explorer"
+	"github.com/threefoldtech/zos/pkg/provision/local"
 	"github.com/threefoldtech/zos/pkg/provision/primitives"
 	"github.com/threefoldtech/zos/pkg/provision/primitives/cache"
 
@@ -36,12 +37,16 @@ func main() {
 	var (
 		msgBrokerCon string
 		storageDir   string
+		localDir     string
+		workers      int
 		debug        bool
 		ver          bool
 	)
 
 	flag.StringVar(&storageDir, "root", "/var/cache/modules/provisiond", "root path of the module")
 	flag.StringVar(&msgBrokerCon, "broker", "unix:///var/run/redis.sock", "connection string to the message broker")
+	flag.StringVar(&localDir, "local", "", "if set, reservations are read from <local>/reservations, verified with the user keys from <local>/users and results written to <local>/results instead of using the explorer")
+	flag.IntVar(&workers, "workers", 4, "number of reservations provisioned concurrently")
 	flag.BoolVar(&debug, "debug", false, "enable debug logging")
 	flag.BoolVar(&ver, "v", false, "show version and exit")
 
@@ -75,7 +80,7 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to parse node environment")
 	}
 
-	if env.Orphan {
+	if env.Orphan && localDir == "" {
 		// disable providiond on this node
 		// we don't have a valid farmer id set
 		log.Fatal().Msg("orphan node, we won't provision anything at all")
@@ -105,15 +110,12 @@ func main() {
 		log.Error().Err(err).Msgf("networkd is not ready yet")
 	})
 
-	// to get reservation from tnodb
-	e, err := app.ExplorerClient()
-	if err != nil {
-		log.Fatal().Err(err).Msg("failed to instantiate BCDB client")
-	}
-
 	// keep track of resource unnits reserved and amount of workloads provisionned
 	statser := &primitives.Counters{}
 
+	// checked before the reservation store marks provisiond as booted
+	firstBoot := app.IsFirstBoot("provisiond")
+
 	// to store reservation locally on the node
 	localStore, err := cache.NewFSStore(filepath.Join(storageDir, "reservations"))
 	if err != nil {
@@ -122,20 +124,66 @@ func main() {
 	// update stats from the local reservation cache
 	localStore.Sync(statser)
 
+	// to keep track of failed operations that need to be retried
+	retries, err := cache.NewFSQueue(filepath.Join(storageDir, "retries"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create retry queue")
+	}
+	// the reservations of a previous boot are all provisioned again
+	if firstBoot {
+		if err := retries.Clear(); err != nil {
+			log.Fatal().Err(err).Msg("failed to clear retry queue")
+		}
+	}
+
 	provisioner := primitives.NewProvisioner(localStore, zbusCl)
 
+	var (
+		source   provision.ReservationSource
+		feedback provision.Feedbacker
+		users    provision.KeyResolver
+	)
+
+	if localDir != "" {
+		log.Info().Str("dir", localDir).Msg("using local reservation source")
+		source, feedback, users, err = localBackend(localDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to create local reservation source")
+		}
+	} else {
+		// to get reservation from tnodb
+		e, err := app.ExplorerClient()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to instantiate BCDB client")
+		}
+
+		source = provision.PollSource(explorer.NewPoller(e, primitives.WorkloadToProvisionType, primitives.ProvisionOrder), nodeID)
+		feedback = explorer.NewFeedback(e, primitives.ResultToSchemaType)
+		users = explorer.NewKeyResolver(e)
+	}
+
 	engine := provision.New(provision.EngineOps{
 		NodeID: nodeID.Identity(),
 		Cache:  localStore,
 		Source: provision.CombinedSource(
-			provision.PollSource(explorer.NewPoller(e, primitives.WorkloadToProvisionType, primitives.ProvisionOrder), nodeID),
+			source,
 			provision.NewDecommissionSource(localStore),
 		),
 		Provisioners:   provisioner.Provisioners,
 		Decomissioners: provisioner.Decommissioners,
-		Feedback:       explorer.NewFeedback(e, primitives.ResultToSchemaType),
+		Updaters:       provisioner.Updaters,
+		Checkers:       provisioner.Checkers,
+		Suspenders:     provisioner.Suspenders,
+		Resumers:       provisioner.Resumers,
+		Prefetchers:    provisioner.Prefetchers,
+		Watcher:        provisioner,
+		Feedback:       feedback,
 		Signer:         identity,
+		Users:          users,
 		Statser:        statser,
+		Retries:        retries,
+		Workers:        workers,
+		ProvisionOrder: primitives.ProvisionOrder,
 	})
 
 	server.Register(zbus.ObjectID{Name: module, Version: "0.0.1"}, pkg.ProvisionMonitor(engine))
@@ -166,6 +214,22 @@ func main() {
 	log.Info().Msg("provision engine stopped")
 }
 
+// localBackend creates the reservation source, feedbacker and key resolver used
+// to run the provision engine without the explorer
+func localBackend(root string) (provision.ReservationSource, provision.Feedbacker, provision.KeyResolver, error) {
+	source, err := local.NewSource(filepath.Join(root, "reservations"), primitives.ProvisionOrder)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	feedback, err := local.NewFeedback(filepath.Join(root, "results"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return source, feedback, local.NewKeyResolver(filepath.Join(root, "users")), nil
+}
+
 type store interface {
 	provision.ReservationPoller
 	provision.Feedbacker
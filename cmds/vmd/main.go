@@ -46,7 +46,12 @@ func main() {
 		log.Fatal().Msgf("fail to connect to message broker server: %v\n", err)
 	}
 
-	mod, err := vm.NewVMModule(moduleRoot)
+	client, err := zbus.NewRedisClient(msgBrokerCon)
+	if err != nil {
+		log.Fatal().Msgf("fail to connect to message broker server: %v", err)
+	}
+
+	mod, err := vm.NewVMModule(moduleRoot, client)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create a new instance of manager")
 	}
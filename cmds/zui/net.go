@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	ui "github.com/gizak/termui/v3"
 	"github.com/gizak/termui/v3/widgets"
@@ -80,9 +81,60 @@ func addressRender(ctx context.Context, table *widgets.Table, client zbus.Client
 	return nil
 }
 
+// activePeerTimeout is the time after the last handshake
+// of a wireguard peer after which it is not active anymore
+const activePeerTimeout = 3 * time.Minute
+
+func tenantRender(ctx context.Context, table *widgets.Table, client zbus.Client, render *Flag) error {
+	table.Title = "Tenant Networks"
+	table.RowSeparator = false
+	table.Rows = [][]string{
+		{"NETWORK", "PEERS", "SENT", "RECV", "DROPPED"},
+	}
+
+	stub := stubs.NewNetworkerStub(client)
+	counters, err := stub.NetResourceCounters(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to start network resource counters stream")
+	}
+
+	go func() {
+		for s := range counters {
+			// keep the header
+			rows := table.Rows[:1]
+			for _, nr := range s {
+				active := 0
+				for _, peer := range nr.Peers {
+					if time.Since(peer.LastHandshake) < activePeerTimeout {
+						active++
+					}
+				}
+
+				rows = append(rows,
+					[]string{
+						string(nr.NetID),
+						fmt.Sprintf("%d/%d", active, len(nr.Peers)),
+						// the traffic sent by the workloads is
+						// received by the network resource bridge
+						fmt.Sprintf("%d KB", nr.BridgeRxBytes/1024),
+						fmt.Sprintf("%d KB", nr.BridgeTxBytes/1024),
+						fmt.Sprint(nr.DroppedPackets),
+					},
+				)
+			}
+
+			table.Rows = rows
+			render.Signal()
+		}
+	}()
+
+	return nil
+}
+
 func netRender(client zbus.Client, grid *ui.Grid, render *Flag) error {
 	addresses := widgets.NewTable()
 	statistics := widgets.NewTable()
+	tenants := widgets.NewTable()
 
 	statistics.Title = "Traffic"
 	statistics.RowSeparator = false
@@ -94,9 +146,12 @@ func netRender(client zbus.Client, grid *ui.Grid, render *Flag) error {
 		ui.NewRow(1./6,
 			ui.NewCol(1, addresses),
 		),
-		ui.NewRow(5.0/6,
+		ui.NewRow(3.0/6,
 			ui.NewCol(1, statistics),
 		),
+		ui.NewRow(2.0/6,
+			ui.NewCol(1, tenants),
+		),
 	)
 
 	ctx := context.Background()
@@ -105,6 +160,10 @@ func netRender(client zbus.Client, grid *ui.Grid, render *Flag) error {
 		return err
 	}
 
+	if err := tenantRender(ctx, tenants, client, render); err != nil {
+		return err
+	}
+
 	monitor := stubs.NewSystemMonitorStub(client)
 	stats, err := monitor.Nics(ctx)
 	if err != nil {
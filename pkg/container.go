@@ -4,6 +4,9 @@ package pkg
 //go:generate zbusc -module container -version 0.0.1 -name container -package stubs github.com/threefoldtech/zos/pkg+ContainerModule stubs/container_stub.go
 
 import (
+	"context"
+	"time"
+
 	"github.com/threefoldtech/zos/pkg/container/logger"
 	"github.com/threefoldtech/zos/pkg/container/stats"
 )
@@ -29,6 +32,78 @@ type MountInfo struct {
 	Target string // target of mount inside the container
 }
 
+// RestartPolicyType defines when a container task is restarted after it exits
+type RestartPolicyType string
+
+const (
+	// RestartAlways always restarts the container task when it exits.
+	// This is the default policy
+	RestartAlways RestartPolicyType = "always"
+	// RestartOnFailure restarts the container task only if it exits
+	// with a non zero exit code
+	RestartOnFailure RestartPolicyType = "on-failure"
+	// RestartNever never restarts the container task
+	RestartNever RestartPolicyType = "never"
+)
+
+// RestartPolicy defines how the container is restarted when its entrypoint exits
+type RestartPolicy struct {
+	// Policy of the restart, defaults to RestartAlways
+	Policy RestartPolicyType
+	// MaxRetries is the maximum number of times the container is restarted
+	// before giving up. 0 means no limit
+	MaxRetries uint
+}
+
+// ShouldRestart decides if a container task that exited with code, after
+// being restarted restarts times, needs to be restarted according to the policy
+func (p RestartPolicy) ShouldRestart(code uint32, restarts uint) bool {
+	if p.MaxRetries != 0 && restarts >= p.MaxRetries {
+		return false
+	}
+
+	switch p.Policy {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return code != 0
+	default:
+		return false
+	}
+}
+
+// HealthCheckType is the type of probe used to check the health of a container
+type HealthCheckType string
+
+const (
+	// HealthCheckExec runs a command inside the container, the container is
+	// healthy if the command exits with 0
+	HealthCheckExec HealthCheckType = "exec"
+	// HealthCheckTCP opens a TCP connection to a port of the container
+	HealthCheckTCP HealthCheckType = "tcp"
+	// HealthCheckHTTP does an HTTP GET on a port of the container, the container
+	// is healthy if the response status code is lower than 400
+	HealthCheckHTTP HealthCheckType = "http"
+)
+
+// HealthCheck defines how to probe the health of a container
+type HealthCheck struct {
+	Type HealthCheckType
+	// Command to run inside the container for exec checks
+	Command string
+	// Port to probe for tcp and http checks
+	Port uint16
+	// Path of the http request for http checks
+	Path string
+	// Interval between two probes
+	Interval time.Duration
+	// Timeout of a single probe
+	Timeout time.Duration
+	// Retries is the number of consecutive failed probes after which
+	// the container is considered unhealthy and its task killed
+	Retries uint
+}
+
 //Container creation info
 type Container struct {
 	// Name of container
@@ -55,6 +130,66 @@ type Container struct {
 	Logs []logger.Logs
 	// StatsAggregator container metrics backend
 	StatsAggregator []stats.Aggregator
+	// RestartPolicy of the container entrypoint
+	RestartPolicy RestartPolicy
+	// HealthCheck of the container, nil means no health check
+	HealthCheck *HealthCheck
+	// State of the container, only set by Inspect
+	State ContainerState
+	// ExitCode of the last task of an exited container, only set by Inspect
+	ExitCode uint32
+	// Restarts is the number of times the container has been restarted by
+	// its restart policy, only set by Inspect
+	Restarts uint
+}
+
+// ContainerState is the state of a running container
+type ContainerState string
+
+const (
+	// ContainerRunning the container is running (and healthy if it has a health check)
+	ContainerRunning ContainerState = "running"
+	// ContainerRestarting the container task exited and is being restarted
+	ContainerRestarting ContainerState = "restarting"
+	// ContainerUnhealthy the container failed its health check
+	ContainerUnhealthy ContainerState = "unhealthy"
+	// ContainerExited the container task exited and won't be restarted
+	ContainerExited ContainerState = "exited"
+	// ContainerStopped the container has been stopped with Stop
+	ContainerStopped ContainerState = "stopped"
+	// ContainerPaused the container has been paused with Pause
+	ContainerPaused ContainerState = "paused"
+)
+
+// ContainerEvent is sent each time the state of a container changes
+type ContainerEvent struct {
+	Namespace string
+	Container ContainerID
+	State     ContainerState
+	// ExitCode of the task for restarting and exited states
+	ExitCode uint32
+	// Restarts is the number of times the container has been restarted
+	Restarts uint
+	// Message gives details about the state change
+	Message string
+}
+
+// ExecResult is the output of a command executed inside a container
+type ExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode uint32
+}
+
+// ContainerLog is a line of the output of a container
+type ContainerLog struct {
+	Namespace string
+	Container ContainerID
+	// Time the line was written, zero if unknown
+	Time time.Time
+	// Stream is either stdout or stderr, empty if unknown
+	Stream string
+	Line   string
 }
 
 // ContainerModule defines rpc interface to containerd
@@ -71,4 +206,38 @@ type ContainerModule interface {
 	// Inspect, return information about the container, given its container id
 	Inspect(ns string, id ContainerID) (Container, error)
 	Delete(ns string, id ContainerID) error
+
+	// Events streams the state changes of all the containers
+	// running on the node
+	Events(ctx context.Context) <-chan ContainerEvent
+
+	// Exec runs cmd inside the running container and returns its output
+	Exec(ns string, id ContainerID, cmd string) (ExecResult, error)
+	// Logs returns the output of the container written after since.
+	// Only the last tail lines are returned, unless tail is 0
+	Logs(ns string, id ContainerID, since time.Time, tail int) ([]ContainerLog, error)
+	// Attach starts sending the output of the container to the Output stream
+	Attach(ns string, id ContainerID) error
+	// Detach stops sending the output of a container attached with Attach
+	Detach(ns string, id ContainerID) error
+	// Output streams the output of the attached containers
+	Output(ctx context.Context) <-chan ContainerLog
+
+	// Stop stops the container task. The container and its root filesystem
+	// are kept so it can be started again with Start
+	Stop(ns string, id ContainerID) error
+	// Start starts again a container stopped with Stop
+	Start(ns string, id ContainerID) error
+	// Pause freezes all the processes of the container
+	Pause(ns string, id ContainerID) error
+	// Resume resumes a container paused with Pause
+	Resume(ns string, id ContainerID) error
+	// Signal sends signal to the container entrypoint
+	Signal(ns string, id ContainerID, signal int) error
+
+	// Snapshot creates a read-only snapshot of the read-write layer of the
+	// container root filesystem. A running container is paused while the
+	// snapshot is taken. Returns the name of the snapshot that can be
+	// exported or deleted with the flist module
+	Snapshot(ns string, id ContainerID) (string, error)
 }
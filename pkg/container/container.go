@@ -12,8 +12,6 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
-	"syscall"
-	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -24,6 +22,7 @@ import (
 	"github.com/containerd/containerd/oci"
 	"github.com/containerd/containerd/runtime/restart"
 	"github.com/google/shlex"
+	"github.com/threefoldtech/zbus"
 	"github.com/threefoldtech/zos/pkg"
 	"github.com/threefoldtech/zos/pkg/container/logger"
 	"github.com/threefoldtech/zos/pkg/container/stats"
@@ -59,18 +58,30 @@ var (
 type containerModule struct {
 	containerd string
 	root       string
+	client     zbus.Client
+	supervisor *supervisor
+	follower   *follower
 }
 
-// New return an new pkg.ContainerModule
-func New(root string, containerd string) pkg.ContainerModule {
+// New return an new pkg.ContainerModule. The module supervises the
+// containers it runs until ctx is done. client is used to reach the flist
+// module that manages the containers root filesystems
+func New(ctx context.Context, root string, containerd string, client zbus.Client) pkg.ContainerModule {
 	if len(containerd) == 0 {
 		containerd = containerdSock
 	}
 
-	return &containerModule{
+	c := &containerModule{
 		containerd: containerd,
 		root:       root,
+		client:     client,
+		supervisor: newSupervisor(ctx, containerd),
+		follower:   newFollower(ctx),
 	}
+
+	go c.supervisor.run()
+
+	return c
 }
 
 // Run creates and starts a container
@@ -124,6 +135,11 @@ func (c *containerModule) Run(ns string, data pkg.Container) (id pkg.ContainerID
 		WithCPUCount(data.CPU),
 	}
 
+	labels, err := containerLabels(data)
+	if err != nil {
+		return id, err
+	}
+
 	if data.WorkingDir != "" {
 		opts = append(opts, oci.WithProcessCwd(data.WorkingDir))
 	}
@@ -148,10 +164,10 @@ func (c *containerModule) Run(ns string, data pkg.Container) (id pkg.ContainerID
 		ctx,
 		data.Name,
 		containerd.WithNewSpec(opts...),
-		// this ensure that the container/task will be restarted automatically
-		// if it gets killed for whatever reason (mostly OOM killer)
-		restart.WithStatus(containerd.Running),
-		restart.WithBinaryLogURI(binaryLogsShim, nil),
+		// the supervisor restarts the container task according to
+		// the restart policy if it gets killed for whatever reason
+		// (mostly OOM killer) and runs its health check
+		containerd.WithContainerLabels(labels),
 	)
 	if err != nil {
 		return id, err
@@ -184,11 +200,18 @@ func (c *containerModule) Run(ns string, data pkg.Container) (id pkg.ContainerID
 		return id, err
 	}
 
+	// always keep a local copy of the output for Logs and Attach
+	var local logger.Logs
+	local, err = c.logsBackend(ns, container.ID())
+	if err != nil {
+		return id, err
+	}
+
 	// creating and serializing logs settings for external logger
 	confpath := path.Join(cfgs, fmt.Sprintf("%s-logs.json", container.ID()))
 	log.Info().Str("cfg", confpath).Msg("writing logs settings")
 
-	err = logger.Serialize(confpath, data.Logs)
+	err = logger.Serialize(confpath, append([]logger.Logs{local}, data.Logs...))
 	if err != nil {
 		log.Error().Err(err).Msg("could not write logs settings")
 		return id, err
@@ -227,11 +250,18 @@ func (c *containerModule) Run(ns string, data pkg.Container) (id pkg.ContainerID
 		}
 	}
 
+	// the container is tracked before its task starts, so the supervisor
+	// doesn't miss the exit event of a task that stops right away
+	c.supervisor.track(ns, container.ID())
+
 	// call start on the task to execute the redis server
-	if err := task.Start(ctx); err != nil {
+	if err = task.Start(ctx); err != nil {
+		c.supervisor.untrack(ns, container.ID())
 		return id, err
 	}
 
+	c.supervisor.probe(ns, container.ID())
+
 	return pkg.ContainerID(container.ID()), nil
 }
 
@@ -285,9 +315,51 @@ func (c *containerModule) Inspect(ns string, id pkg.ContainerID) (result pkg.Con
 		}
 	}
 
+	c.state(ctx, ns, container, &result)
 	return
 }
 
+// state sets the state of the container from the status of its task, and
+// its restart policy from its labels. The supervisor knows if a container that
+// isn't running is being restarted or if a running one is unhealthy. The
+// state is left empty if it's unknown
+func (c *containerModule) state(ctx context.Context, ns string, container containerd.Container, result *pkg.Container) {
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("container", container.ID()).Msg("failed to load container labels")
+		return
+	}
+
+	result.RestartPolicy, result.Restarts, _ = restartPolicy(labels)
+
+	status := containerd.Status{Status: containerd.Stopped}
+	if task, err := container.Task(ctx, nil); err == nil {
+		status, err = task.Status(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("container", container.ID()).Msg("failed to get container task status")
+			return
+		}
+	}
+
+	tracked, ok := c.supervisor.state(ns, container.ID())
+
+	switch {
+	case status.Status == containerd.Paused || status.Status == containerd.Pausing:
+		result.State = pkg.ContainerPaused
+	case status.Status == containerd.Running && ok && tracked == pkg.ContainerUnhealthy:
+		result.State = pkg.ContainerUnhealthy
+	case status.Status == containerd.Running:
+		result.State = pkg.ContainerRunning
+	case ok && tracked == pkg.ContainerRestarting:
+		result.State = pkg.ContainerRestarting
+	case labels[labelStopped] == "true":
+		result.State = pkg.ContainerStopped
+	default:
+		result.State = pkg.ContainerExited
+		result.ExitCode = status.ExitStatus
+	}
+}
+
 // List returns running containers IDs for a specific namespace
 func (c *containerModule) List(ns string) ([]pkg.ContainerID, error) {
 	client, err := containerd.New(c.containerd)
@@ -327,6 +399,10 @@ func (c *containerModule) Delete(ns string, id pkg.ContainerID) error {
 		return err
 	}
 
+	c.supervisor.untrack(ns, string(id))
+	c.follower.detach(ns, string(id), true)
+
+	// containers created before the supervisor are restarted by containerd
 	if err := container.Update(ctx, restart.WithNoRestarts); err != nil {
 		log.Warn().Err(err).Msg("failed to clear up restart task status, continuing anyways")
 	}
@@ -334,34 +410,26 @@ func (c *containerModule) Delete(ns string, id pkg.ContainerID) error {
 	task, err := container.Task(ctx, nil)
 	if err == nil {
 		// err == nil, there is a task running inside the container
-		exitC, err := task.Wait(ctx)
-		if err != nil {
+		if err := stopTask(ctx, task); err != nil {
 			return err
 		}
-		trials := 3
-	loop:
-		for {
-			signal := syscall.SIGTERM
-			if trials <= 0 {
-				signal = syscall.SIGKILL
-			}
-			_ = task.Kill(ctx, signal)
-			trials--
-			select {
-			case <-exitC:
-				break loop
-			case <-time.After(1 * time.Second):
-			}
-		}
+	}
 
-		if _, err := task.Delete(ctx); err != nil {
-			return err
+	logs := c.logsPath(ns, string(id))
+	for _, path := range []string{logs, logger.RotatedPath(logs)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warn().Err(err).Str("container", string(id)).Msg("failed to remove container logs")
 		}
 	}
 
 	return container.Delete(ctx)
 }
 
+// Events streams the state changes of the containers
+func (c *containerModule) Events(ctx context.Context) <-chan pkg.ContainerEvent {
+	return c.supervisor.subscribe(ctx)
+}
+
 func (c *containerModule) ensureNamespace(ctx context.Context, client *containerd.Client, namespace string) error {
 	service := client.NamespaceService()
 	namespaces, err := service.List(ctx)
@@ -0,0 +1,143 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/google/shlex"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// execTimeout is the maximum time a command started with Exec can run
+	execTimeout = time.Minute
+	// execMaxOutput is the maximum size of the output of a command
+	// returned by Exec, the rest is discarded
+	execMaxOutput = 1024 * 1024
+)
+
+// limitedBuffer is a buffer that silently discards
+// everything written after its limit is reached
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if left := b.limit - b.Len(); left < len(p) {
+		if left > 0 {
+			b.Buffer.Write(p[:left])
+		}
+		return len(p), nil
+	}
+
+	return b.Buffer.Write(p)
+}
+
+// Exec runs cmd inside the running container and returns its output
+func (c *containerModule) Exec(ns string, id pkg.ContainerID, cmd string) (result pkg.ExecResult, err error) {
+	args, err := shlex.Split(cmd)
+	if err != nil || len(args) == 0 {
+		return result, fmt.Errorf("invalid command '%s'", cmd)
+	}
+
+	client, err := containerd.New(c.containerd)
+	if err != nil {
+		return result, err
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(namespaces.WithNamespace(context.Background(), ns), execTimeout)
+	defer cancel()
+
+	container, err := client.LoadContainer(ctx, string(id))
+	if err != nil {
+		return result, err
+	}
+
+	stdout := &limitedBuffer{limit: execMaxOutput}
+	stderr := &limitedBuffer{limit: execMaxOutput}
+
+	log.Info().Str("namespace", ns).Str("container", string(id)).Strs("args", args).Msg("executing command in container")
+	result.ExitCode, err = execIn(ctx, container, args, stdout, stderr)
+	if err != nil {
+		return result, err
+	}
+
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+
+	return result, nil
+}
+
+// execIn runs a process with args inside the container task and waits for it
+// to exit or for ctx to be done. The output of the process is copied to stdout
+// and stderr which can be nil to discard it
+func execIn(ctx context.Context, container containerd.Container, args []string, stdout, stderr io.Writer) (uint32, error) {
+	if stdout == nil {
+		stdout = ioutil.Discard
+	}
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+
+	spec, err := container.Spec(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "container has no running task")
+	}
+
+	process := *spec.Process
+	process.Args = args
+	process.Terminal = false
+
+	execID := fmt.Sprintf("exec-%d", time.Now().UnixNano())
+	p, err := task.Exec(ctx, execID, &process, cio.NewCreator(cio.WithStreams(nil, stdout, stderr)))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create process")
+	}
+
+	defer func() {
+		// ctx might be done already
+		nsName, _ := namespaces.Namespace(ctx)
+		_, _ = p.Delete(namespaces.WithNamespace(context.Background(), nsName), containerd.WithProcessKill)
+	}()
+
+	exitC, err := p.Wait(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := p.Start(ctx); err != nil {
+		return 0, errors.Wrap(err, "failed to start process")
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, fmt.Errorf("command timed out")
+	case status := <-exitC:
+		code, _, err := status.Result()
+		if err != nil {
+			return 0, err
+		}
+
+		// make sure all the output is copied
+		p.IO().Wait()
+
+		return code, nil
+	}
+}
@@ -0,0 +1,130 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+
+	"github.com/containerd/containerd"
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/google/shlex"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pkg/errors"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+)
+
+// healthProbe runs a single health check probe against the container
+func healthProbe(ctx context.Context, container containerd.Container, check pkg.HealthCheck) error {
+	ctx, cancel := context.WithTimeout(ctx, check.Timeout)
+	defer cancel()
+
+	switch check.Type {
+	case pkg.HealthCheckExec:
+		return execProbe(ctx, container, check.Command)
+	case pkg.HealthCheckTCP, pkg.HealthCheckHTTP:
+		netns, err := containerNetNS(ctx, container)
+		if err != nil {
+			return err
+		}
+		defer netns.Close()
+
+		address := fmt.Sprintf("127.0.0.1:%d", check.Port)
+		if check.Type == pkg.HealthCheckTCP {
+			return tcpProbe(ctx, netns, address)
+		}
+		return httpProbe(ctx, netns, address, check.Path)
+	default:
+		return fmt.Errorf("unknown health check type '%s'", check.Type)
+	}
+}
+
+// execProbe runs command inside the container and fails if it doesn't exit with 0
+func execProbe(ctx context.Context, container containerd.Container, command string) error {
+	args, err := shlex.Split(command)
+	if err != nil || len(args) == 0 {
+		return fmt.Errorf("invalid health check command '%s'", command)
+	}
+
+	code, err := execIn(ctx, container, args, nil, nil)
+	if err != nil {
+		return errors.Wrap(err, "health check command failed")
+	}
+
+	if code != 0 {
+		return fmt.Errorf("health check command exited with code %d", code)
+	}
+
+	return nil
+}
+
+// containerNetNS returns the network namespace of the container
+func containerNetNS(ctx context.Context, container containerd.Container) (ns.NetNS, error) {
+	spec, err := container.Spec(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, linuxNS := range spec.Linux.Namespaces {
+		if linuxNS.Type == specs.NetworkNamespace {
+			return namespace.GetByName(filepath.Base(linuxNS.Path))
+		}
+	}
+
+	return nil, fmt.Errorf("container has no network namespace")
+}
+
+// dialIn opens a connection from inside the network namespace
+func dialIn(ctx context.Context, netns ns.NetNS, network, address string) (conn net.Conn, err error) {
+	err = netns.Do(func(_ ns.NetNS) error {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, network, address)
+		return err
+	})
+
+	return
+}
+
+func tcpProbe(ctx context.Context, netns ns.NetNS, address string) error {
+	conn, err := dialIn(ctx, netns, "tcp", address)
+	if err != nil {
+		return err
+	}
+
+	return conn.Close()
+}
+
+func httpProbe(ctx context.Context, netns ns.NetNS, address, path string) error {
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialIn(ctx, netns, network, addr)
+			},
+			DisableKeepAlives: true,
+		},
+	}
+
+	if path == "" || path[0] != '/' {
+		path = "/" + path
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", address, path), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("health check request failed with status %s", response.Status)
+	}
+
+	return nil
+}
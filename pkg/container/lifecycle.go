@@ -0,0 +1,237 @@
+package container
+
+import (
+	"context"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/cio"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// labelStopped is set on the containers stopped with Stop
+// so they are not restarted by the supervisor
+const labelStopped = "zos.stopped"
+
+// startTask creates and starts a new task for the container
+func startTask(ctx context.Context, container containerd.Container) (containerd.Task, error) {
+	uri, err := url.Parse("binary://" + binaryLogsShim)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := container.NewTask(ctx, cio.LogURI(uri))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create task")
+	}
+
+	if err := task.Start(ctx); err != nil {
+		_, _ = task.Delete(ctx, containerd.WithProcessKill)
+		return nil, errors.Wrap(err, "failed to start task")
+	}
+
+	return task, nil
+}
+
+// stopTask stops the task gracefully, it's killed if it doesn't
+// exit after a few SIGTERM, and then deleted
+func stopTask(ctx context.Context, task containerd.Task) error {
+	if status, err := task.Status(ctx); err == nil && status.Status == containerd.Paused {
+		// frozen processes don't handle signals
+		if err := task.Resume(ctx); err != nil {
+			return errors.Wrap(err, "failed to resume paused task")
+		}
+	}
+
+	exitC, err := task.Wait(ctx)
+	if err != nil {
+		return err
+	}
+
+	trials := 3
+loop:
+	for {
+		signal := syscall.SIGTERM
+		if trials <= 0 {
+			signal = syscall.SIGKILL
+		}
+		_ = task.Kill(ctx, signal)
+		trials--
+		select {
+		case <-exitC:
+			break loop
+		case <-time.After(1 * time.Second):
+		}
+	}
+
+	_, err = task.Delete(ctx)
+	return err
+}
+
+// load returns a containerd client and the container, the client
+// needs to be closed by the caller
+func (c *containerModule) load(ns string, id pkg.ContainerID) (context.Context, *containerd.Client, containerd.Container, error) {
+	client, err := containerd.New(c.containerd)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	ctx := namespaces.WithNamespace(context.Background(), ns)
+	container, err := client.LoadContainer(ctx, string(id))
+	if err != nil {
+		client.Close()
+		return nil, nil, nil, err
+	}
+
+	return ctx, client, container, nil
+}
+
+func (c *containerModule) event(ns string, id pkg.ContainerID, state pkg.ContainerState, msg string) {
+	c.supervisor.report(pkg.ContainerEvent{
+		Namespace: ns,
+		Container: id,
+		State:     state,
+		Message:   msg,
+	})
+}
+
+// Stop stops the container task. The container and its root filesystem
+// are kept so it can be started again with Start
+func (c *containerModule) Stop(ns string, id pkg.ContainerID) error {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// mark the container as stopped first so the supervisor
+	// doesn't restart it
+	if _, err := container.SetLabels(ctx, map[string]string{labelStopped: "true"}); err != nil {
+		return errors.Wrap(err, "failed to mark container as stopped")
+	}
+	c.supervisor.stopProbe(ns, string(id))
+
+	task, err := container.Task(ctx, nil)
+	if err == nil {
+		if err := stopTask(ctx, task); err != nil {
+			return errors.Wrap(err, "failed to stop container task")
+		}
+	}
+
+	log.Info().Str("namespace", ns).Str("container", string(id)).Msg("container stopped")
+	c.event(ns, id, pkg.ContainerStopped, "container stopped")
+
+	return nil
+}
+
+// Start starts again a container stopped with Stop
+func (c *containerModule) Start(ns string, id pkg.ContainerID) error {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if task, err := container.Task(ctx, nil); err == nil {
+		status, err := task.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		if status.Status != containerd.Stopped {
+			// already started
+			return nil
+		}
+
+		if _, err := task.Delete(ctx); err != nil {
+			return errors.Wrap(err, "failed to delete stopped task")
+		}
+	}
+
+	if _, err := container.SetLabels(ctx, map[string]string{labelStopped: "false"}); err != nil {
+		return errors.Wrap(err, "failed to mark container as started")
+	}
+
+	if _, err := startTask(ctx, container); err != nil {
+		if _, err := container.SetLabels(ctx, map[string]string{labelStopped: "true"}); err != nil {
+			log.Error().Err(err).Str("container", string(id)).Msg("failed to mark container as stopped")
+		}
+		return err
+	}
+
+	log.Info().Str("namespace", ns).Str("container", string(id)).Msg("container started")
+	c.supervisor.track(ns, string(id))
+	c.supervisor.probe(ns, string(id))
+
+	return nil
+}
+
+// Pause freezes all the processes of the container
+func (c *containerModule) Pause(ns string, id pkg.ContainerID) error {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "container is not running")
+	}
+
+	// a frozen container can't answer its health check
+	c.supervisor.stopProbe(ns, string(id))
+	if err := task.Pause(ctx); err != nil {
+		c.supervisor.probe(ns, string(id))
+		return errors.Wrap(err, "failed to pause container")
+	}
+
+	c.event(ns, id, pkg.ContainerPaused, "container paused")
+
+	return nil
+}
+
+// Resume resumes a container paused with Pause
+func (c *containerModule) Resume(ns string, id pkg.ContainerID) error {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "container is not running")
+	}
+
+	if err := task.Resume(ctx); err != nil {
+		return errors.Wrap(err, "failed to resume container")
+	}
+
+	c.event(ns, id, pkg.ContainerRunning, "container resumed")
+	c.supervisor.probe(ns, string(id))
+
+	return nil
+}
+
+// Signal sends signal to the container entrypoint
+func (c *containerModule) Signal(ns string, id pkg.ContainerID, signal int) error {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	task, err := container.Task(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "container is not running")
+	}
+
+	return task.Kill(ctx, syscall.Signal(signal))
+}
@@ -13,4 +13,8 @@ type LogsRedis struct {
 
 	// Stderr is the redis url for stderr (redis://host/channel)
 	Stderr string `json:"stderr"`
+
+	// Prefix is only used by the file backend, each line is
+	// prefixed with the time it was written and its stream
+	Prefix bool `json:"prefix,omitempty"`
 }
@@ -1,60 +1,263 @@
 package logger
 
 import (
+	"bytes"
+	"fmt"
 	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
 
 // FileType defines file logger type name
 const FileType = "file"
 
+const (
+	// StreamStdout is the name of the stdout stream in log files
+	StreamStdout = "stdout"
+	// StreamStderr is the name of the stderr stream in log files
+	StreamStderr = "stderr"
+
+	// FileMaxSize is the size after which a log file is rotated, the
+	// previous content is kept in a single backup file, see RotatedPath
+	FileMaxSize = 10 * 1024 * 1024
+	// lineMaxSize is the size after which a line that does not end
+	// is written as is
+	lineMaxSize = 64 * 1024
+)
+
+// RotatedPath returns the path of the backup of the log file at path
+func RotatedPath(path string) string {
+	return path + ".1"
+}
+
+// FileOption configures the file logger
+type FileOption func(*File)
+
+// WithPrefix prefixes each line with the time it was written and its stream
+func WithPrefix() FileOption {
+	return func(f *File) {
+		f.prefix = true
+	}
+}
+
 // File write stdout/stderr to files
 type File struct {
-	target *os.File
+	target *logFile
+	stream string
+	prefix bool
+
+	partial []byte
+}
+
+// logFile is a log file, shared by the stdout and stderr
+// writers when they write to the same file
+type logFile struct {
+	m    sync.Mutex
+	path string
+	file *os.File
+	size int64
+	refs int
 }
 
 // NewFile open file and prepare logs writing
-func NewFile(stdout string, stderr string) (io.Writer, io.Writer, error) {
+func NewFile(stdout string, stderr string, opts ...FileOption) (io.WriteCloser, io.WriteCloser, error) {
 	log.Debug().Str("stdout", stdout).Str("stderr", stderr).Msg("initializing localfile logging")
 
-	fo, err := os.Create(stdout)
+	fo, err := openLogFile(stdout)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// If stdout and stderr are the same, only one file is open
 	fe := fo
-
 	if stdout != stderr {
-		fe, err = os.Create(stderr)
+		fe, err = openLogFile(stderr)
 		if err != nil {
+			fo.close()
 			return nil, nil, err
 		}
 	}
 
+	fo.refs++
+	fe.refs++
+
 	fstdout := &File{
 		target: fo,
+		stream: StreamStdout,
 	}
 
 	fstderr := &File{
 		target: fe,
+		stream: StreamStderr,
+	}
+
+	for _, opt := range opts {
+		opt(fstdout)
+		opt(fstderr)
 	}
 
 	return fstdout, fstderr, nil
 }
 
-// Write forwards write to underlaying layer
-func (c *File) Write(data []byte) (int, error) {
-	n, err := c.target.Write(data)
+// openLogFile opens the log file for appending
+func openLogFile(path string) (*logFile, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
 	if err != nil {
-		log.Error().Err(err).Msg("log file write")
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &logFile{path: path, file: file, size: info.Size()}, nil
+}
+
+// write appends data to the file, the file is rotated first
+// if data makes it grow over FileMaxSize. l.m must be held
+func (l *logFile) write(data []byte) error {
+	if l.size > 0 && l.size+int64(len(data)) > FileMaxSize {
+		if err := l.rotate(); err != nil {
+			return errors.Wrap(err, "failed to rotate log file")
+		}
+	}
+
+	n, err := l.file.Write(data)
+	l.size += int64(n)
+	if err != nil {
+		return err
 	}
 
 	if n != len(data) {
 		log.Error().Int("expected", len(data)).Int("written", n).Msg("log file write not complete")
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
+
+// rotate moves the file to its backup, replacing the previous one,
+// and starts a new file. l.m must be held
+func (l *logFile) rotate() error {
+	if err := os.Rename(l.path, RotatedPath(l.path)); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file = file
+	l.size = 0
+	return nil
+}
+
+// close closes the file once all its writers are closed. l.m must be held
+func (l *logFile) close() error {
+	l.refs--
+	if l.refs > 0 {
+		return nil
+	}
+
+	return l.file.Close()
+}
+
+// Write forwards write to underlaying layer
+func (c *File) Write(data []byte) (int, error) {
+	c.target.m.Lock()
+	defer c.target.m.Unlock()
+
+	c.partial = append(c.partial, data...)
+
+	var buf bytes.Buffer
+	now := time.Now()
+	for {
+		index := bytes.IndexByte(c.partial, '\n')
+		if index < 0 {
+			break
+		}
+
+		c.format(&buf, now, c.partial[:index])
+		c.partial = c.partial[index+1:]
+	}
+
+	// a line can't grow forever in memory
+	if len(c.partial) >= lineMaxSize {
+		c.format(&buf, now, c.partial)
+		c.partial = nil
+	}
+
+	if buf.Len() == 0 {
+		return len(data), nil
+	}
+
+	if err := c.target.write(buf.Bytes()); err != nil {
+		log.Error().Err(err).Msg("log file write")
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// Close writes the line that is not terminated yet, if any, and closes the file
+func (c *File) Close() error {
+	c.target.m.Lock()
+	defer c.target.m.Unlock()
+
+	if len(c.partial) > 0 {
+		var buf bytes.Buffer
+		c.format(&buf, time.Now(), c.partial)
+		c.partial = nil
+
+		if err := c.target.write(buf.Bytes()); err != nil {
+			log.Error().Err(err).Msg("log file write")
+		}
+	}
+
+	return c.target.close()
+}
+
+func (c *File) format(buf *bytes.Buffer, t time.Time, line []byte) {
+	if !c.prefix {
+		buf.Write(line)
+		buf.WriteByte('\n')
+		return
+	}
+
+	buf.WriteString(FormatLine(t, c.stream, string(line)))
+}
+
+// FormatLine formats a log line the way it's written to the log files
+func FormatLine(t time.Time, stream, line string) string {
+	return fmt.Sprintf("%s %s %s\n", t.UTC().Format(time.RFC3339Nano), stream, line)
+}
+
+// ParseLine parses a line from a log file. Lines written by
+// older versions of the logger have no time or stream, ok is
+// false for them and line is returned as is
+func ParseLine(line string) (t time.Time, stream, msg string, ok bool) {
+	parts := strings.SplitN(line, " ", 3)
+	if len(parts) != 3 {
+		return t, "", line, false
+	}
+
+	if parts[1] != StreamStdout && parts[1] != StreamStderr {
+		return t, "", line, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return t, "", line, false
 	}
 
-	return n, err
+	return t, parts[1], parts[2], true
 }
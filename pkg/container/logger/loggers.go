@@ -2,10 +2,12 @@ package logger
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 
 	"github.com/containerd/containerd/cio"
+	"github.com/pkg/errors"
 )
 
 // Loggers keeps stdout and stderr backend list
@@ -54,6 +56,58 @@ func (c *Loggers) Add(stdout io.Writer, stderr io.Writer) {
 	c.stderrs = append(c.stderrs, stderr)
 }
 
+// Open opens the backends defined in logs, usually read with Deserialize,
+// and adds them on the list
+func (c *Loggers) Open(logs []Logs) error {
+	for _, l := range logs {
+		var (
+			stdout, stderr io.Writer
+			err            error
+		)
+
+		switch l.Type {
+		case RedisType:
+			stdout, stderr, err = NewRedis(l.Data.Stdout, l.Data.Stderr)
+		case FileType:
+			var opts []FileOption
+			if l.Data.Prefix {
+				opts = append(opts, WithPrefix())
+			}
+			stdout, stderr, err = NewFile(l.Data.Stdout, l.Data.Stderr, opts...)
+		case ConsoleType:
+			stdout, stderr, err = NewConsole()
+		default:
+			err = fmt.Errorf("unknown logs backend type '%s'", l.Type)
+		}
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to open %s logs backend", l.Type)
+		}
+
+		c.Add(stdout, stderr)
+	}
+
+	return nil
+}
+
+// Close closes the backends that need to, the file backends
+// write the lines that are not terminated yet
+func (c *Loggers) Close() error {
+	var result error
+	for _, w := range append(c.stdouts, c.stderrs...) {
+		closer, ok := w.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
 // Stdouts returns list of stdout availables
 func (c *Loggers) Stdouts() []io.Writer {
 	return c.stdouts
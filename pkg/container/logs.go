@@ -0,0 +1,344 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/container/logger"
+)
+
+const (
+	// followInterval is the interval at which the log files
+	// of the attached containers are checked for new lines
+	followInterval = 500 * time.Millisecond
+	// logsMaxLine is the maximum length of a log line
+	logsMaxLine = 1024 * 1024
+)
+
+func (c *containerModule) logsPath(ns, id string) string {
+	return filepath.Join(c.root, "logs", ns, id+".log")
+}
+
+// logsBackend returns the file logs backend that keeps the output of the container
+// so it can be read with Logs and Attach
+func (c *containerModule) logsBackend(ns, id string) (logger.Logs, error) {
+	path := c.logsPath(ns, id)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return logger.Logs{}, err
+	}
+
+	return logger.Logs{
+		Type: logger.FileType,
+		Data: logger.LogsRedis{
+			Stdout: path,
+			Stderr: path,
+			Prefix: true,
+		},
+	}, nil
+}
+
+// ensureContainer makes sure the container exists
+func (c *containerModule) ensureContainer(ns string, id pkg.ContainerID) error {
+	client, err := containerd.New(c.containerd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := namespaces.WithNamespace(context.Background(), ns)
+	_, err = client.LoadContainer(ctx, string(id))
+	return err
+}
+
+// Logs returns the output of the container written after since
+func (c *containerModule) Logs(ns string, id pkg.ContainerID, since time.Time, tail int) ([]pkg.ContainerLog, error) {
+	if err := c.ensureContainer(ns, id); err != nil {
+		return nil, err
+	}
+
+	logs, err := readLogs(c.logsPath(ns, string(id)), since, tail)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read container logs")
+	}
+
+	for i := range logs {
+		logs[i].Namespace = ns
+		logs[i].Container = id
+	}
+
+	return logs, nil
+}
+
+// Attach starts sending the output of the container to the Output stream
+func (c *containerModule) Attach(ns string, id pkg.ContainerID) error {
+	if err := c.ensureContainer(ns, id); err != nil {
+		return err
+	}
+
+	c.follower.attach(ns, string(id), c.logsPath(ns, string(id)))
+	return nil
+}
+
+// Detach stops sending the output of the container to the Output stream
+func (c *containerModule) Detach(ns string, id pkg.ContainerID) error {
+	c.follower.detach(ns, string(id), false)
+	return nil
+}
+
+// Output streams the output of the attached containers
+func (c *containerModule) Output(ctx context.Context) <-chan pkg.ContainerLog {
+	return c.follower.subscribe(ctx)
+}
+
+// parseLog parses a line of a container log file
+func parseLog(line string) pkg.ContainerLog {
+	t, stream, msg, _ := logger.ParseLine(line)
+	return pkg.ContainerLog{
+		Time:   t,
+		Stream: stream,
+		Line:   msg,
+	}
+}
+
+// readLogs reads the last tail lines written after since from the log file
+// and its rotated backup. Lines with unknown time are skipped if since is set
+func readLogs(path string, since time.Time, tail int) ([]pkg.ContainerLog, error) {
+	logs, err := readLogFile(logger.RotatedPath(path), since)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	current, err := readLogFile(path, since)
+	if err != nil {
+		return nil, err
+	}
+	logs = append(logs, current...)
+
+	if tail > 0 && len(logs) > tail {
+		logs = logs[len(logs)-tail:]
+	}
+
+	return logs, nil
+}
+
+func readLogFile(path string, since time.Time) ([]pkg.ContainerLog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var logs []pkg.ContainerLog
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), logsMaxLine)
+	for scanner.Scan() {
+		entry := parseLog(scanner.Text())
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+
+		logs = append(logs, entry)
+	}
+
+	return logs, scanner.Err()
+}
+
+type attachment struct {
+	refs   int
+	cancel context.CancelFunc
+}
+
+// follower sends the lines appended to the log files of the
+// attached containers to the Output stream subscribers
+type follower struct {
+	ctx context.Context
+
+	m           sync.Mutex
+	attached    map[string]*attachment
+	subscribers map[chan pkg.ContainerLog]struct{}
+}
+
+func newFollower(ctx context.Context) *follower {
+	return &follower{
+		ctx:         ctx,
+		attached:    make(map[string]*attachment),
+		subscribers: make(map[chan pkg.ContainerLog]struct{}),
+	}
+}
+
+// attach starts following the log file at path, a container can be
+// attached multiple times, it's followed until it's detached as many times
+func (f *follower) attach(ns, id, path string) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	key := containerKey(ns, id)
+	if a, ok := f.attached[key]; ok {
+		a.refs++
+		return
+	}
+
+	ctx, cancel := context.WithCancel(f.ctx)
+	f.attached[key] = &attachment{refs: 1, cancel: cancel}
+
+	go f.follow(ctx, ns, id, path)
+}
+
+// detach stops following the container logs once it's detached as many times
+// as it was attached, or right away if all is set
+func (f *follower) detach(ns, id string, all bool) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	key := containerKey(ns, id)
+	a, ok := f.attached[key]
+	if !ok {
+		return
+	}
+
+	a.refs--
+	if a.refs > 0 && !all {
+		return
+	}
+
+	a.cancel()
+	delete(f.attached, key)
+}
+
+// subscribe returns a channel that receives all the lines of the attached
+// containers until ctx is done
+func (f *follower) subscribe(ctx context.Context) <-chan pkg.ContainerLog {
+	ch := make(chan pkg.ContainerLog, eventsBuffer)
+
+	f.m.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		f.m.Lock()
+		delete(f.subscribers, ch)
+		f.m.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (f *follower) send(entry pkg.ContainerLog) {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	for ch := range f.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			log.Warn().Str("container", string(entry.Container)).Msg("output subscriber is too slow, dropping line")
+		}
+	}
+}
+
+// follow sends the lines appended to the log file until ctx is done
+func (f *follower) follow(ctx context.Context, ns, id, path string) {
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	var (
+		file    *os.File
+		offset  int64
+		partial []byte
+	)
+
+	// only the lines written after the container is attached are sent
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if file == nil {
+			var err error
+			if file, err = os.Open(path); err != nil {
+				// the container didn't write anything yet
+				file = nil
+				continue
+			}
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to stat container log file")
+			continue
+		}
+
+		size := info.Size()
+		if size == offset {
+			// the file is rotated once it's full, the rest of
+			// the lines are written to a new file at path
+			if latest, err := os.Stat(path); err == nil && !os.SameFile(info, latest) {
+				file.Close()
+				file = nil
+				offset = 0
+				partial = nil
+				continue
+			}
+		}
+
+		if size < offset {
+			// file has been truncated
+			offset = 0
+			partial = nil
+		}
+
+		if size == offset {
+			continue
+		}
+
+		buf := make([]byte, size-offset)
+		n, err := file.ReadAt(buf, offset)
+		if n == 0 && err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to read container log file")
+			continue
+		}
+		offset += int64(n)
+
+		partial = append(partial, buf[:n]...)
+		for {
+			index := bytes.IndexByte(partial, '\n')
+			if index < 0 {
+				break
+			}
+
+			entry := parseLog(string(partial[:index]))
+			entry.Namespace = ns
+			entry.Container = pkg.ContainerID(id)
+			f.send(entry)
+
+			partial = partial[index+1:]
+		}
+	}
+}
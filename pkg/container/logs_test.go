@@ -0,0 +1,199 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/container/logger"
+)
+
+func TestReadLogs(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "container.log")
+	require.NoError(ioutil.WriteFile(path, []byte("line from an old logger\n"), 0640))
+
+	stdout, stderr, err := logger.NewFile(path, path, logger.WithPrefix())
+	require.NoError(err)
+
+	start := time.Now()
+	_, err = fmt.Fprint(stdout, "hello\nwor")
+	require.NoError(err)
+	_, err = fmt.Fprint(stderr, "oops\n")
+	require.NoError(err)
+	_, err = fmt.Fprint(stdout, "ld\n")
+	require.NoError(err)
+
+	logs, err := readLogs(path, time.Time{}, 0)
+	require.NoError(err)
+	require.Len(logs, 4)
+	assert.Equal(pkg.ContainerLog{Line: "line from an old logger"}, logs[0])
+	assert.Equal(logger.StreamStdout, logs[1].Stream)
+	assert.Equal("hello", logs[1].Line)
+	assert.Equal(logger.StreamStderr, logs[2].Stream)
+	assert.Equal("oops", logs[2].Line)
+	assert.Equal("world", logs[3].Line)
+	assert.False(logs[3].Time.Before(start.Truncate(time.Second)))
+
+	logs, err = readLogs(path, start.Add(-time.Second), 0)
+	require.NoError(err)
+	assert.Len(logs, 3, "lines with unknown time are skipped")
+
+	logs, err = readLogs(path, time.Time{}, 2)
+	require.NoError(err)
+	require.Len(logs, 2)
+	assert.Equal("oops", logs[0].Line)
+	assert.Equal("world", logs[1].Line)
+
+	// the line that does not end is written on close
+	_, err = fmt.Fprint(stdout, "bye")
+	require.NoError(err)
+	require.NoError(stdout.Close())
+	require.NoError(stderr.Close())
+
+	logs, err = readLogs(path, time.Time{}, 1)
+	require.NoError(err)
+	require.Len(logs, 1)
+	assert.Equal("bye", logs[0].Line)
+}
+
+func TestLogsBackend(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	c := &containerModule{root: root}
+	backend, err := c.logsBackend("ns", "container")
+	require.NoError(err)
+
+	// the settings go through the config file read by shim-logs
+	config := filepath.Join(root, "container-logs.json")
+	require.NoError(logger.Serialize(config, []logger.Logs{backend}))
+	logs, err := logger.Deserialize(config)
+	require.NoError(err)
+
+	loggers := logger.NewLoggers()
+	require.NoError(loggers.Open(logs))
+
+	_, err = fmt.Fprint(loggers.Stdouts()[0], "hello\n")
+	require.NoError(err)
+	_, err = fmt.Fprint(loggers.Stderrs()[0], "oops")
+	require.NoError(err)
+	require.NoError(loggers.Close())
+
+	lines, err := readLogs(c.logsPath("ns", "container"), time.Time{}, 0)
+	require.NoError(err)
+	require.Len(lines, 2)
+	require.Equal(logger.StreamStdout, lines[0].Stream)
+	require.Equal("hello", lines[0].Line)
+	require.Equal(logger.StreamStderr, lines[1].Stream)
+	require.Equal("oops", lines[1].Line)
+
+	require.Error(loggers.Open([]logger.Logs{{Type: "unknown"}}))
+}
+
+func TestReadLogsRotated(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "container.log")
+	stdout, stderr, err := logger.NewFile(path, path)
+	require.NoError(err)
+	defer stderr.Close()
+	defer stdout.Close()
+
+	line := strings.Repeat("x", 1023) + "\n"
+	lines := 2*logger.FileMaxSize/len(line) + 1
+	for i := 0; i < lines; i++ {
+		_, err = fmt.Fprint(stdout, line)
+		require.NoError(err)
+	}
+
+	// the log files are capped, only the last rotation is kept
+	for _, file := range []string{path, logger.RotatedPath(path)} {
+		info, err := os.Stat(file)
+		require.NoError(err)
+		require.True(info.Size() <= logger.FileMaxSize)
+	}
+
+	logs, err := readLogs(path, time.Time{}, 0)
+	require.NoError(err)
+	require.Equal(logger.FileMaxSize/len(line)+1, len(logs))
+
+	// without prefix, the lines are written as is
+	require.Equal(pkg.ContainerLog{Line: strings.Repeat("x", 1023)}, logs[0])
+}
+
+func TestFollower(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "container.log")
+	require.NoError(ioutil.WriteFile(path, []byte(logger.FormatLine(time.Now(), logger.StreamStdout, "before")), 0640))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	f := newFollower(ctx)
+	output := f.subscribe(ctx)
+	f.attach("ns1", "c1", path)
+	f.attach("ns1", "c1", path)
+
+	// wait for the follower to start
+	time.Sleep(2 * followInterval)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(err)
+	defer file.Close()
+
+	_, err = file.WriteString(logger.FormatLine(time.Now(), logger.StreamStdout, "after"))
+	require.NoError(err)
+
+	select {
+	case entry := <-output:
+		require.Equal("after", entry.Line, "only lines written after attach are sent")
+		require.Equal(pkg.ContainerID("c1"), entry.Container)
+		require.Equal("ns1", entry.Namespace)
+	case <-time.After(5 * followInterval):
+		require.Fail("line not received")
+	}
+
+	// the lines written after a rotation are sent
+	require.NoError(os.Rename(path, logger.RotatedPath(path)))
+	require.NoError(ioutil.WriteFile(path, []byte(logger.FormatLine(time.Now(), logger.StreamStdout, "rotated")), 0640))
+
+	select {
+	case entry := <-output:
+		require.Equal("rotated", entry.Line)
+	case <-time.After(5 * followInterval):
+		require.Fail("line not received after rotation")
+	}
+
+	f.detach("ns1", "c1", false)
+	require.Len(f.attached, 1, "container still attached once")
+
+	f.detach("ns1", "c1", false)
+	require.Len(f.attached, 0)
+}
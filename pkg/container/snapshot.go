@@ -0,0 +1,104 @@
+package container
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/containerd/containerd"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+// Snapshot creates a read-only snapshot of the read-write layer of the
+// container root filesystem. A running container is paused while the
+// snapshot is taken so its content is consistent
+func (c *containerModule) Snapshot(ns string, id pkg.ContainerID) (string, error) {
+	ctx, client, container, err := c.load(ns, id)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	spec, err := container.Spec(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if spec.Root == nil || spec.Root.Path == "" {
+		return "", ErrEmptyRootFS
+	}
+
+	// the root filesystem of interactive containers is the corex one,
+	// their flist is mounted in /sandbox
+	rootFS := spec.Root.Path
+	if rootFS == "/usr/lib/corex" {
+		rootFS = ""
+		for _, mount := range spec.Mounts {
+			if mount.Destination == "/sandbox" {
+				rootFS = mount.Source
+				break
+			}
+		}
+	}
+
+	if rootFS == "" {
+		return "", ErrEmptyRootFS
+	}
+
+	flister := stubs.NewFlisterStub(c.client)
+	name, err := mountName(flister, rootFS)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to find root filesystem mount of container %s", id)
+	}
+
+	if task, err := container.Task(ctx, nil); err == nil {
+		status, err := task.Status(ctx)
+		if err != nil {
+			return "", err
+		}
+
+		if status.Status == containerd.Running {
+			c.supervisor.stopProbe(ns, string(id))
+			if err := task.Pause(ctx); err != nil {
+				c.supervisor.probe(ns, string(id))
+				return "", errors.Wrap(err, "failed to pause container")
+			}
+
+			defer func() {
+				if err := task.Resume(ctx); err != nil {
+					log.Error().Err(err).Str("container", string(id)).Msg("failed to resume container after snapshot")
+					return
+				}
+				c.supervisor.probe(ns, string(id))
+			}()
+		}
+	}
+
+	snapshot, err := flister.Snapshot(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to snapshot container %s", id)
+	}
+
+	log.Info().Str("namespace", ns).Str("container", string(id)).Str("snapshot", snapshot).Msg("container snapshot created")
+
+	return snapshot, nil
+}
+
+// mountName returns the name of the flist mounted at path
+func mountName(flister pkg.Flister, path string) (string, error) {
+	mounts, err := flister.List()
+	if err != nil {
+		return "", err
+	}
+
+	for _, mount := range mounts {
+		if filepath.Clean(mount.Path) == filepath.Clean(path) {
+			return mount.Name, nil
+		}
+	}
+
+	return "", fmt.Errorf("no flist mounted at %s", path)
+}
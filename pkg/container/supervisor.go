@@ -0,0 +1,612 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/containerd/containerd"
+	apievents "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/namespaces"
+	"github.com/containerd/typeurl"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	labelRestartPolicy = "zos.restart.policy"
+	labelRestartMax    = "zos.restart.max-retries"
+	labelRestartCount  = "zos.restart.count"
+	labelHealthCheck   = "zos.healthcheck"
+)
+
+const (
+	restartMinDelay = time.Second
+	restartMaxDelay = 5 * time.Minute
+	// restartStableDelay is the time a restarted container without
+	// health check needs to stay up to be considered running again
+	restartStableDelay = time.Minute
+
+	healthDefaultInterval = 30 * time.Second
+	healthDefaultTimeout  = 10 * time.Second
+	healthDefaultRetries  = 3
+
+	eventsBuffer = 64
+)
+
+// containerLabels returns the labels used by the supervisor to apply the
+// restart policy and the health check of the container
+func containerLabels(data pkg.Container) (map[string]string, error) {
+	policy := data.RestartPolicy.Policy
+	if policy == "" {
+		policy = pkg.RestartAlways
+	}
+
+	switch policy {
+	case pkg.RestartAlways, pkg.RestartOnFailure, pkg.RestartNever:
+	default:
+		return nil, fmt.Errorf("invalid restart policy '%s'", policy)
+	}
+
+	labels := map[string]string{
+		labelRestartPolicy: string(policy),
+		labelRestartMax:    strconv.FormatUint(uint64(data.RestartPolicy.MaxRetries), 10),
+		labelRestartCount:  "0",
+	}
+
+	if data.HealthCheck != nil {
+		switch data.HealthCheck.Type {
+		case pkg.HealthCheckExec:
+			if data.HealthCheck.Command == "" {
+				return nil, fmt.Errorf("exec health check requires a command")
+			}
+		case pkg.HealthCheckTCP, pkg.HealthCheckHTTP:
+			if data.HealthCheck.Port == 0 {
+				return nil, fmt.Errorf("%s health check requires a port", data.HealthCheck.Type)
+			}
+		default:
+			return nil, fmt.Errorf("invalid health check type '%s'", data.HealthCheck.Type)
+		}
+
+		check, err := json.Marshal(data.HealthCheck)
+		if err != nil {
+			return nil, err
+		}
+		labels[labelHealthCheck] = string(check)
+	}
+
+	return labels, nil
+}
+
+// restartPolicy loads the restart policy from the container labels
+// it returns false if the container is not managed by the supervisor
+func restartPolicy(labels map[string]string) (policy pkg.RestartPolicy, restarts uint, ok bool) {
+	value, ok := labels[labelRestartPolicy]
+	if !ok {
+		return policy, 0, false
+	}
+
+	policy.Policy = pkg.RestartPolicyType(value)
+	if max, err := strconv.ParseUint(labels[labelRestartMax], 10, 32); err == nil {
+		policy.MaxRetries = uint(max)
+	}
+	if count, err := strconv.ParseUint(labels[labelRestartCount], 10, 32); err == nil {
+		restarts = uint(count)
+	}
+
+	return policy, restarts, true
+}
+
+// healthCheck loads the health check from the container labels
+func healthCheck(labels map[string]string) (*pkg.HealthCheck, error) {
+	value, ok := labels[labelHealthCheck]
+	if !ok {
+		return nil, nil
+	}
+
+	var check pkg.HealthCheck
+	if err := json.Unmarshal([]byte(value), &check); err != nil {
+		return nil, errors.Wrap(err, "invalid health check label")
+	}
+
+	if check.Interval <= 0 {
+		check.Interval = healthDefaultInterval
+	}
+	if check.Timeout <= 0 {
+		check.Timeout = healthDefaultTimeout
+	}
+	if check.Retries == 0 {
+		check.Retries = healthDefaultRetries
+	}
+
+	return &check, nil
+}
+
+// restartDelay returns the time to wait before restarting a container
+// that already has been restarted restarts times
+func restartDelay(restarts uint) time.Duration {
+	if restarts > 16 {
+		return restartMaxDelay
+	}
+
+	delay := restartMinDelay << restarts
+	if delay > restartMaxDelay {
+		return restartMaxDelay
+	}
+
+	return delay
+}
+
+func containerKey(ns, id string) string {
+	return fmt.Sprintf("%s/%s", ns, id)
+}
+
+// supervisor applies the restart policies and runs the health checks of all
+// the containers created by the module, and broadcasts their state changes
+type supervisor struct {
+	ctx        context.Context
+	containerd string
+
+	m           sync.Mutex
+	states      map[string]pkg.ContainerState
+	probes      map[string]context.CancelFunc
+	subscribers map[chan pkg.ContainerEvent]struct{}
+}
+
+func newSupervisor(ctx context.Context, containerd string) *supervisor {
+	return &supervisor{
+		ctx:         ctx,
+		containerd:  containerd,
+		states:      make(map[string]pkg.ContainerState),
+		probes:      make(map[string]context.CancelFunc),
+		subscribers: make(map[chan pkg.ContainerEvent]struct{}),
+	}
+}
+
+// track starts supervising a container
+func (s *supervisor) track(ns, id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.states[containerKey(ns, id)] = pkg.ContainerRunning
+}
+
+// untrack stops supervising a container, this needs to be called
+// before the container task is stopped so it's not restarted
+func (s *supervisor) untrack(ns, id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := containerKey(ns, id)
+	delete(s.states, key)
+	if cancel, ok := s.probes[key]; ok {
+		cancel()
+		delete(s.probes, key)
+	}
+}
+
+func (s *supervisor) tracked(ns, id string) bool {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	_, ok := s.states[containerKey(ns, id)]
+	return ok
+}
+
+// state returns the last known state of a tracked container
+func (s *supervisor) state(ns, id string) (pkg.ContainerState, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	state, ok := s.states[containerKey(ns, id)]
+	return state, ok
+}
+
+// report broadcasts the state of the container if it changed
+func (s *supervisor) report(event pkg.ContainerEvent) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := containerKey(event.Namespace, string(event.Container))
+	current, ok := s.states[key]
+	if !ok || current == event.State {
+		return
+	}
+	s.states[key] = event.State
+
+	log.Info().
+		Str("namespace", event.Namespace).
+		Str("container", string(event.Container)).
+		Str("state", string(event.State)).
+		Msg(event.Message)
+
+	for ch := range s.subscribers {
+		select {
+		case ch <- event:
+		default:
+			log.Warn().Str("container", string(event.Container)).Msg("events subscriber is too slow, dropping event")
+		}
+	}
+}
+
+// subscribe returns a channel that receives all the containers state changes
+// until ctx is done
+func (s *supervisor) subscribe(ctx context.Context) <-chan pkg.ContainerEvent {
+	ch := make(chan pkg.ContainerEvent, eventsBuffer)
+
+	s.m.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		s.m.Lock()
+		delete(s.subscribers, ch)
+		s.m.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// run watches the containerd task exit events until the supervisor context is done
+func (s *supervisor) run() {
+	for {
+		if err := s.watch(); err != nil {
+			log.Error().Err(err).Msg("failed to watch containerd events")
+		}
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (s *supervisor) watch() error {
+	client, err := containerd.New(s.containerd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	events, errs := client.Subscribe(s.ctx, `topic=="/tasks/exit"`)
+
+	// containers might have exited while we were not watching
+	if err := s.recover(client); err != nil {
+		log.Error().Err(err).Msg("failed to recover containers supervision")
+	}
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case envelope := <-events:
+			event, err := typeurl.UnmarshalAny(envelope.Event)
+			if err != nil {
+				log.Error().Err(err).Msg("failed to decode containerd event")
+				continue
+			}
+
+			exit, ok := event.(*apievents.TaskExit)
+			if !ok || exit.ID != exit.ContainerID {
+				// only the exit of the container init process matters
+				// not the ones of the exec processes (like health checks)
+				continue
+			}
+
+			s.exited(client, envelope.Namespace, exit.ContainerID, exit.Pid, exit.ExitStatus)
+		}
+	}
+}
+
+// recover starts supervising all the containers managed by the module
+func (s *supervisor) recover(client *containerd.Client) error {
+	nss, err := client.NamespaceService().List(s.ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, ns := range nss {
+		ctx := namespaces.WithNamespace(s.ctx, ns)
+		containers, err := client.Containers(ctx)
+		if err != nil {
+			log.Error().Err(err).Str("namespace", ns).Msg("failed to list containers")
+			continue
+		}
+
+		for _, container := range containers {
+			labels, err := container.Labels(ctx)
+			if err != nil {
+				continue
+			}
+
+			if _, _, ok := restartPolicy(labels); !ok {
+				continue
+			}
+
+			id := container.ID()
+			if !s.tracked(ns, id) {
+				s.track(ns, id)
+			}
+
+			if labels[labelStopped] == "true" {
+				s.report(pkg.ContainerEvent{
+					Namespace: ns,
+					Container: pkg.ContainerID(id),
+					State:     pkg.ContainerStopped,
+					Message:   "container stopped",
+				})
+				continue
+			}
+
+			task, err := container.Task(ctx, nil)
+			if err != nil {
+				s.exited(client, ns, id, 0, 0)
+				continue
+			}
+
+			status, err := task.Status(ctx)
+			if err != nil {
+				continue
+			}
+
+			if status.Status == containerd.Stopped {
+				s.exited(client, ns, id, task.Pid(), status.ExitStatus)
+			} else {
+				s.probe(ns, id)
+			}
+		}
+	}
+
+	return nil
+}
+
+// exited applies the restart policy of a container whose task exited
+func (s *supervisor) exited(client *containerd.Client, ns, id string, pid, code uint32) {
+	if !s.tracked(ns, id) {
+		// container is being deleted
+		return
+	}
+
+	ctx := namespaces.WithNamespace(s.ctx, ns)
+	container, err := client.LoadContainer(ctx, id)
+	if err != nil {
+		return
+	}
+
+	if task, err := container.Task(ctx, nil); err == nil && task.Pid() != pid {
+		if status, err := task.Status(ctx); err == nil && status.Status == containerd.Running {
+			// event of a previous task of the container
+			return
+		}
+	}
+
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("container", id).Msg("failed to load container labels")
+		return
+	}
+
+	policy, restarts, ok := restartPolicy(labels)
+	if !ok || labels[labelStopped] == "true" {
+		return
+	}
+
+	s.stopProbe(ns, id)
+
+	event := pkg.ContainerEvent{
+		Namespace: ns,
+		Container: pkg.ContainerID(id),
+		ExitCode:  code,
+		Restarts:  restarts,
+	}
+
+	if !policy.ShouldRestart(code, restarts) {
+		event.State = pkg.ContainerExited
+		event.Message = fmt.Sprintf("container exited with code %d after %d restarts", code, restarts)
+		s.report(event)
+		return
+	}
+
+	event.State = pkg.ContainerRestarting
+	event.Message = fmt.Sprintf("container exited with code %d, restarting", code)
+	s.report(event)
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(restartDelay(restarts)):
+		}
+
+		if err := s.restart(ns, id, restarts+1); err != nil {
+			log.Error().Err(err).Str("container", id).Msg("failed to restart container")
+			event.State = pkg.ContainerExited
+			event.Message = fmt.Sprintf("failed to restart container: %s", err)
+			s.report(event)
+		}
+	}()
+}
+
+// restart starts a new task for the container
+func (s *supervisor) restart(ns, id string, restarts uint) error {
+	if !s.tracked(ns, id) {
+		return nil
+	}
+
+	client, err := containerd.New(s.containerd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	ctx := namespaces.WithNamespace(s.ctx, ns)
+	container, err := client.LoadContainer(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if labels, err := container.Labels(ctx); err == nil && labels[labelStopped] == "true" {
+		// stopped while waiting to be restarted
+		return nil
+	}
+
+	if task, err := container.Task(ctx, nil); err == nil {
+		if _, err := task.Delete(ctx, containerd.WithProcessKill); err != nil {
+			return errors.Wrap(err, "failed to delete exited task")
+		}
+	}
+
+	task, err := startTask(ctx, container)
+	if err != nil {
+		return err
+	}
+
+	labels, err := container.SetLabels(ctx, map[string]string{
+		labelRestartCount: strconv.FormatUint(uint64(restarts), 10),
+	})
+	if err != nil {
+		log.Error().Err(err).Str("container", id).Msg("failed to update container restart count")
+	}
+
+	log.Info().Str("container", id).Uint("restarts", restarts).Msg("container restarted")
+
+	if _, ok := labels[labelHealthCheck]; ok {
+		// the container is reported running by the health check
+		s.probe(ns, id)
+		return nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case <-time.After(restartStableDelay):
+	}
+
+	status, err := task.Status(ctx)
+	if err != nil || status.Status != containerd.Running {
+		// the exit event takes care of it
+		return nil
+	}
+
+	s.report(pkg.ContainerEvent{
+		Namespace: ns,
+		Container: pkg.ContainerID(id),
+		State:     pkg.ContainerRunning,
+		Restarts:  restarts,
+		Message:   "container is running again",
+	})
+
+	return nil
+}
+
+// probe starts the health check of the container if it has one
+func (s *supervisor) probe(ns, id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := containerKey(ns, id)
+	if cancel, ok := s.probes[key]; ok {
+		cancel()
+	}
+
+	ctx, cancel := context.WithCancel(namespaces.WithNamespace(s.ctx, ns))
+	s.probes[key] = cancel
+
+	go func() {
+		if err := s.healthLoop(ctx, ns, id); err != nil {
+			log.Error().Err(err).Str("container", id).Msg("failed to run container health check")
+		}
+	}()
+}
+
+func (s *supervisor) stopProbe(ns, id string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	key := containerKey(ns, id)
+	if cancel, ok := s.probes[key]; ok {
+		cancel()
+		delete(s.probes, key)
+	}
+}
+
+// healthLoop probes the container until ctx is done. The container task is killed
+// once it fails check.Retries consecutive probes so the restart policy is applied
+func (s *supervisor) healthLoop(ctx context.Context, ns, id string) error {
+	client, err := containerd.New(s.containerd)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	container, err := client.LoadContainer(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	labels, err := container.Labels(ctx)
+	if err != nil {
+		return err
+	}
+
+	check, err := healthCheck(labels)
+	if err != nil || check == nil {
+		return err
+	}
+
+	_, restarts, _ := restartPolicy(labels)
+	event := pkg.ContainerEvent{
+		Namespace: ns,
+		Container: pkg.ContainerID(id),
+		Restarts:  restarts,
+	}
+
+	ticker := time.NewTicker(check.Interval)
+	defer ticker.Stop()
+
+	var failures uint
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		err := healthProbe(ctx, container, *check)
+		if err == nil {
+			failures = 0
+			event.State = pkg.ContainerRunning
+			event.Message = "container is healthy"
+			s.report(event)
+			continue
+		}
+
+		failures++
+		log.Debug().Err(err).Str("container", id).Uint("failures", failures).Msg("container health check failed")
+		if failures < check.Retries {
+			continue
+		}
+
+		event.State = pkg.ContainerUnhealthy
+		event.Message = fmt.Sprintf("container is unhealthy: %s", err)
+		s.report(event)
+
+		task, err := container.Task(ctx, nil)
+		if err != nil {
+			return nil
+		}
+
+		return task.Kill(ctx, syscall.SIGKILL)
+	}
+}
@@ -0,0 +1,122 @@
+package container
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestContainerLabels(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	labels, err := containerLabels(pkg.Container{})
+	require.NoError(err)
+
+	policy, restarts, ok := restartPolicy(labels)
+	require.True(ok)
+	assert.Equal(pkg.RestartAlways, policy.Policy)
+	assert.Equal(uint(0), policy.MaxRetries)
+	assert.Equal(uint(0), restarts)
+
+	check, err := healthCheck(labels)
+	require.NoError(err)
+	assert.Nil(check)
+
+	labels, err = containerLabels(pkg.Container{
+		RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartOnFailure, MaxRetries: 3},
+		HealthCheck:   &pkg.HealthCheck{Type: pkg.HealthCheckHTTP, Port: 8080, Path: "/health"},
+	})
+	require.NoError(err)
+
+	policy, _, ok = restartPolicy(labels)
+	require.True(ok)
+	assert.Equal(pkg.RestartPolicy{Policy: pkg.RestartOnFailure, MaxRetries: 3}, policy)
+
+	check, err = healthCheck(labels)
+	require.NoError(err)
+	assert.Equal(&pkg.HealthCheck{
+		Type:     pkg.HealthCheckHTTP,
+		Port:     8080,
+		Path:     "/health",
+		Interval: healthDefaultInterval,
+		Timeout:  healthDefaultTimeout,
+		Retries:  healthDefaultRetries,
+	}, check)
+
+	_, err = containerLabels(pkg.Container{RestartPolicy: pkg.RestartPolicy{Policy: "sometimes"}})
+	assert.Error(err)
+
+	_, err = containerLabels(pkg.Container{HealthCheck: &pkg.HealthCheck{Type: pkg.HealthCheckExec}})
+	assert.Error(err)
+
+	_, _, ok = restartPolicy(map[string]string{})
+	assert.False(ok, "containers without policy are not supervised")
+}
+
+func TestShouldRestart(t *testing.T) {
+	cases := []struct {
+		policy   pkg.RestartPolicy
+		code     uint32
+		restarts uint
+		restart  bool
+	}{
+		{pkg.RestartPolicy{Policy: pkg.RestartAlways}, 0, 100, true},
+		{pkg.RestartPolicy{Policy: pkg.RestartAlways, MaxRetries: 2}, 0, 2, false},
+		{pkg.RestartPolicy{Policy: pkg.RestartOnFailure}, 0, 0, false},
+		{pkg.RestartPolicy{Policy: pkg.RestartOnFailure}, 1, 0, true},
+		{pkg.RestartPolicy{Policy: pkg.RestartOnFailure, MaxRetries: 2}, 1, 1, true},
+		{pkg.RestartPolicy{Policy: pkg.RestartNever}, 1, 0, false},
+	}
+
+	for _, c := range cases {
+		assert.Equal(t, c.restart, c.policy.ShouldRestart(c.code, c.restarts), "%+v", c)
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	assert.Equal(t, restartMinDelay, restartDelay(0))
+	assert.Equal(t, 4*restartMinDelay, restartDelay(2))
+	assert.Equal(t, restartMaxDelay, restartDelay(20))
+	assert.Equal(t, restartMaxDelay, restartDelay(100))
+}
+
+func TestSupervisorReport(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s := newSupervisor(ctx, "")
+	events := s.subscribe(ctx)
+
+	s.track("ns1", "c1")
+
+	event := pkg.ContainerEvent{Namespace: "ns1", Container: "c1", State: pkg.ContainerRunning}
+	s.report(event)
+	require.Len(events, 0, "state did not change")
+
+	event.State = pkg.ContainerRestarting
+	s.report(event)
+	s.report(event)
+	require.Len(events, 1)
+	require.Equal(pkg.ContainerRestarting, (<-events).State)
+
+	// untracked containers are not reported
+	s.untrack("ns1", "c1")
+	event.State = pkg.ContainerExited
+	s.report(event)
+	require.Len(events, 0)
+
+	cancel()
+	select {
+	case _, ok := <-events:
+		require.False(ok)
+	case <-time.After(time.Second):
+		require.Fail("events channel not closed")
+	}
+}
@@ -1,8 +1,11 @@
 package environment
 
 import (
+	"net/url"
 	"os"
+	"path"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/threefoldtech/zos/pkg"
@@ -23,8 +26,22 @@ type Environment struct {
 
 	FarmerID pkg.FarmID
 	Orphan   bool
+
+	// KubernetesFlists are the flists kubernetes vms can be deployed from.
+	// An entry ending with a / allows all the flists under that path
+	KubernetesFlists []string
+
+	// UpgradePublisher is the hex encoded ed25519 key that signs the flists
+	// of the node upgrades. The upgrades of the test and production networks
+	// are always verified, empty means they are not verified on dev
+	UpgradePublisher string
 }
 
+// UpgradePublisher is the hex encoded ed25519 key that signs the upgrade
+// flists of the test and production networks. It is set during the build
+// of the module
+var UpgradePublisher string
+
 // RunningMode type
 type RunningMode string
 
@@ -60,6 +77,9 @@ var (
 		BcdbURL:     "https://explorer.devnet.grid.tf/explorer",
 		FlistURL:    "zdb://hub.grid.tf:9900",
 		BinRepo:     "tf-zos-bins.dev",
+		KubernetesFlists: []string{
+			"https://hub.grid.tf/",
+		},
 	}
 
 	envTest = Environment{
@@ -67,6 +87,9 @@ var (
 		BcdbURL:     "https://explorer.testnet.grid.tf/explorer",
 		FlistURL:    "zdb://hub.grid.tf:9900",
 		BinRepo:     "tf-zos-bins.test",
+		KubernetesFlists: []string{
+			"https://hub.grid.tf/tf-official-apps/",
+		},
 	}
 
 	// same as testnet for now. will be updated the day of the launch of production network
@@ -75,9 +98,38 @@ var (
 		BcdbURL:     "https://explorer.grid.tf/explorer",
 		FlistURL:    "zdb://hub.grid.tf:9900",
 		BinRepo:     "tf-zos-bins",
+		KubernetesFlists: []string{
+			"https://hub.grid.tf/tf-official-apps/",
+		},
 	}
 )
 
+// UpgradeVerified checks if the upgrade flists of the node must be
+// signed by the upgrade publisher
+func (e Environment) UpgradeVerified() bool {
+	return e.RunningMode != RunningDev
+}
+
+// KubernetesFlistAllowed checks if a kubernetes vm can be deployed
+// from the flist at u
+func (e Environment) KubernetesFlistAllowed(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Path == "" || path.Clean(parsed.Path) != parsed.Path {
+		// refuse paths that could escape an allowed prefix
+		return false
+	}
+
+	for _, allowed := range e.KubernetesFlists {
+		if strings.HasSuffix(allowed, "/") && strings.HasPrefix(u, allowed) {
+			return true
+		} else if u == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Get return the running environment of the node
 func Get() (Environment, error) {
 	params := kernel.GetParams()
@@ -105,6 +157,10 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		env = envProd
 	}
 
+	if env.RunningMode != RunningDev {
+		env.UpgradePublisher = UpgradePublisher
+	}
+
 	if RunningMode(runmode[0]) == RunningDev {
 		//allow override of the bcdb url in dev mode
 		bcdb, found := params.Get("bcdb")
@@ -155,5 +211,14 @@ func getEnvironmentFromParams(params kernel.Params) (Environment, error) {
 		env.BinRepo = e
 	}
 
+	if e := os.Getenv("ZOS_K8S_FLISTS"); e != "" {
+		env.KubernetesFlists = strings.Split(e, ",")
+	}
+
+	// the upgrade publisher of the test and production networks is built in
+	if e := os.Getenv("ZOS_UPGRADE_PUBLISHER"); e != "" && env.RunningMode == RunningDev {
+		env.UpgradePublisher = e
+	}
+
 	return env, nil
 }
@@ -59,3 +59,41 @@ func TestEnvironmentOverride(t *testing.T) {
 
 	assert.Equal(t, value.BcdbURL, "localhost:1234")
 }
+
+func TestUpgradePublisher(t *testing.T) {
+	UpgradePublisher = "publisher"
+	defer func() { UpgradePublisher = "" }()
+
+	os.Setenv("ZOS_UPGRADE_PUBLISHER", "override")
+	defer os.Unsetenv("ZOS_UPGRADE_PUBLISHER")
+
+	value, err := getEnvironmentFromParams(kernel.Params{"runmode": {"prod"}})
+	require.NoError(t, err)
+	assert.True(t, value.UpgradeVerified())
+	assert.Equal(t, "publisher", value.UpgradePublisher)
+
+	value, err = getEnvironmentFromParams(kernel.Params{"runmode": {"test"}})
+	require.NoError(t, err)
+	assert.True(t, value.UpgradeVerified())
+	assert.Equal(t, "publisher", value.UpgradePublisher)
+
+	value, err = getEnvironmentFromParams(kernel.Params{"runmode": {"dev"}})
+	require.NoError(t, err)
+	assert.False(t, value.UpgradeVerified())
+	assert.Equal(t, "override", value.UpgradePublisher)
+}
+
+func TestKubernetesFlistAllowed(t *testing.T) {
+	env := Environment{
+		KubernetesFlists: []string{
+			"https://hub.grid.tf/tf-official-apps/",
+			"https://hub.grid.tf/user/k3os.flist",
+		},
+	}
+
+	assert.True(t, env.KubernetesFlistAllowed("https://hub.grid.tf/tf-official-apps/k3os.flist"))
+	assert.True(t, env.KubernetesFlistAllowed("https://hub.grid.tf/user/k3os.flist"))
+	assert.False(t, env.KubernetesFlistAllowed("https://hub.grid.tf/user/other.flist"))
+	assert.False(t, env.KubernetesFlistAllowed("https://hub.grid.tf/tf-official-apps/../user/other.flist"))
+	assert.False(t, env.KubernetesFlistAllowed("https://example.com/tf-official-apps/k3os.flist"))
+}
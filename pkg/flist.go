@@ -18,6 +18,55 @@ var (
 	}
 )
 
+// FlistCacheUsage is the disk usage of the cache of the flist module
+type FlistCacheUsage struct {
+	// Budget is the size limit of the cache in bytes, 0 means no limit
+	Budget uint64 `json:"budget"`
+	// Flists is the size of the downloaded flists in bytes
+	Flists uint64 `json:"flists"`
+	// FlistsCount is the number of downloaded flists
+	FlistsCount uint64 `json:"flists_count"`
+	// Mounted is the number of downloaded flists backing active mounts
+	Mounted uint64 `json:"mounted"`
+	// Images is the size of the downloaded image layers in bytes
+	Images uint64 `json:"images"`
+	// Chunks is the size of the chunk cache shared by the mounts in bytes
+	Chunks uint64 `json:"chunks"`
+}
+
+// FlistMountState is the health of a mount of the flist module
+type FlistMountState string
+
+const (
+	// FlistMountHealthy is a mount served as expected
+	FlistMountHealthy FlistMountState = "healthy"
+	// FlistMountFailed is a mount whose 0-fs daemon stopped and could not be
+	// started again, the flist module keeps trying
+	FlistMountFailed FlistMountState = "failed"
+)
+
+// FlistMount is a mount of the flist module
+type FlistMount struct {
+	// Name of the mount, as given to NamedMount
+	Name string `json:"name"`
+	// Path where the flist is mounted
+	Path string `json:"path"`
+	// URL of the flist, or the image for mounts of MountImage
+	URL string `json:"url"`
+	// Hash is the md5 of the flist, empty for images
+	Hash string `json:"hash"`
+	// ReadOnly is true if the mount has no read-write layer
+	ReadOnly bool `json:"read_only"`
+	// State is the health of the mount
+	State FlistMountState `json:"state"`
+	// Restarts is the number of times the 0-fs daemon was started again.
+	// The workloads running on the mount don't see a mount that is
+	// started again, they must be restarted
+	Restarts int `json:"restarts"`
+	// Error is the reason of a failed mount
+	Error string `json:"error,omitempty"`
+}
+
 // MountOptions struct
 type MountOptions struct {
 	// ReadOnly
@@ -26,6 +75,16 @@ type MountOptions struct {
 	Limit uint64
 	// Type of disk to use
 	Type DeviceType
+	// Checksum is the hex encoded sha256 of the flist. The flist
+	// is not mounted if it doesn't match, empty skips the check
+	Checksum string
+	// PublicKey is the hex encoded ed25519 key of the publisher of the
+	// flist. If set, the flist is only mounted if it is signed by this key
+	PublicKey string
+	// Signature is the hex encoded ed25519 signature of the sha256 of the
+	// flist by PublicKey. If empty, it is downloaded from the flist url
+	// with the .sig extension
+	Signature string
 }
 
 //Flister is the interface for the flist module
@@ -53,6 +112,53 @@ type Flister interface {
 	// HashFromRootPath returns flist hash from a running g8ufs mounted with NamedMount
 	HashFromRootPath(name string) (string, error)
 
+	// List returns all the mounts of the module with their health. The 0-fs
+	// daemons that stop are started again by the module, the workloads using
+	// such a mount must be restarted to see it again
+	List() ([]FlistMount, error)
+
 	// FlistHash returns md5 of flist if available (requesting the hub)
 	FlistHash(url string) (string, error)
+
+	// Snapshot creates a read-only snapshot of the read-write layer of the
+	// flist mounted with NamedMount under name. The caller is responsible to
+	// make sure nothing writes to the mount while the snapshot is taken.
+	// Returns the name of the snapshot
+	Snapshot(name string) (string, error)
+
+	// DeleteSnapshot deletes a snapshot created with Snapshot
+	DeleteSnapshot(snapshot string) error
+
+	// DeleteSnapshots deletes all the snapshots created with Snapshot of the
+	// flist mounted under name. It must be called before the mount is removed
+	DeleteSnapshots(name string) error
+
+	// Export writes the content of the snapshot as a tar archive to the file at path
+	Export(snapshot string, path string) error
+
+	// MountImage pulls the OCI image from its registry and mounts its root
+	// filesystem under name, like NamedMount does with an flist. The layers
+	// of the image are unpacked in a read-write subvolume of the size and
+	// disk type of opts. The mount is removed with NamedUmount or Umount.
+	// Returns the path in the filesystem where the image is mounted
+	MountImage(name string, image string, opts MountOptions) (path string, err error)
+
+	// Prefetch downloads the flist located at url and fills the chunk cache
+	// with the content of its files, fetched from the 0-db located at storage,
+	// so the next mounts of the flist start fast. If the flist has a .prefetch
+	// file at its root listing paths, one per line, only those are prefetched
+	Prefetch(url string, storage string) error
+
+	// CacheUsage returns the disk usage of the flists, images and chunks cache
+	CacheUsage() (FlistCacheUsage, error)
+
+	// Prune removes the downloaded flists that don't back an active mount
+	// and the downloaded image layers, and evicts the least recently used
+	// chunks until the cache fits its budget. Returns the number of bytes freed
+	Prune() (uint64, error)
+
+	// Import creates the read-write layer of a future NamedMount under name
+	// from the tar archive at path, usually created with Export. The size and
+	// disk type of the layer are taken from opts
+	Import(name string, path string, opts MountOptions) error
 }
@@ -0,0 +1,260 @@
+package flist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// startTimeout is how long a 0-fs daemon has to serve its mount
+	startTimeout = 5 * time.Second
+	// stopTimeout is how long a 0-fs daemon has to exit once unmounted
+	stopTimeout = 2 * time.Second
+)
+
+// mount is a mount of the module, as tracked by the supervisor
+type mount struct {
+	Name   string `json:"name"`
+	Target string `json:"target"`
+	URL    string `json:"url"`
+	// Image is true for the mounts of MountImage, the root filesystem
+	// of the image is bind mounted from Backend
+	Image bool `json:"image,omitempty"`
+	// Flist is the path of the downloaded flist
+	Flist   string `json:"flist,omitempty"`
+	Storage string `json:"storage,omitempty"`
+	// Backend is the read-write layer of the mount, empty for read-only mounts
+	Backend  string `json:"backend,omitempty"`
+	ReadOnly bool   `json:"read_only"`
+	// PID is the pid of the 0-fs daemon serving the mount
+	PID      int `json:"pid,omitempty"`
+	Restarts int `json:"restarts"`
+	// Error is the reason the mount could not be started again
+	Error string `json:"error,omitempty"`
+	// Failures is the number of failed attempts to start the mount again
+	// since it stopped, the next attempt is not made before Retry
+	Failures int       `json:"failures,omitempty"`
+	Retry    time.Time `json:"retry,omitempty"`
+}
+
+// backend serves the flists on their mountpoint
+type backend interface {
+	// Start serves the flist of m on its target, and returns once the
+	// flist is mounted. The pid of the daemon is set on m
+	Start(m *mount) error
+	// Stop unmounts the target of m and stops the daemon serving it
+	Stop(m mount) error
+	// Check returns an error if the flist of m is not served anymore
+	Check(m mount) error
+}
+
+// g8ufs is the backend running a 0-fs daemon per mount
+type g8ufs struct {
+	commander commander
+	cache     string
+	log       string
+
+	// mounted and unmount are replaced by the tests, where nothing is mounted
+	mounted func(path string) (bool, error)
+	unmount func(path string) error
+}
+
+func newG8ufs(commander commander, cache, log string) *g8ufs {
+	return &g8ufs{
+		commander: commander,
+		cache:     cache,
+		log:       log,
+		mounted:   isMounted,
+		unmount:   lazyUnmount,
+	}
+}
+
+func (g *g8ufs) logPath(name string) string {
+	return filepath.Join(g.log, name) + ".log"
+}
+
+// Start implements the backend interface
+func (g *g8ufs) Start(m *mount) error {
+	var args []string
+	if m.ReadOnly {
+		args = append(args, "-ro")
+	} else {
+		args = append(args, "-backend", m.Backend)
+	}
+
+	args = append(args,
+		"-cache", g.cache,
+		"-meta", m.Flist,
+		"-storage-url", m.Storage,
+		"-log", g.logPath(m.Name),
+		m.Target,
+	)
+
+	log.Info().Strs("args", args).Msg("starting 0-fs daemon")
+	cmd := g.commander.Command("g8ufs", args...)
+	// the daemon must survive a restart of the module
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "failed to start 0-fs daemon")
+	}
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), startTimeout)
+	defer cancel()
+
+	for {
+		mounted, err := g.mounted(m.Target)
+		if err != nil {
+			log.Debug().Err(err).Str("path", m.Target).Msg("failed to check mount")
+		} else if mounted {
+			m.PID = cmd.Process.Pid
+			return nil
+		}
+
+		select {
+		case err := <-exited:
+			return errors.Wrapf(err, "0-fs daemon exited before mounting, check %s", g.logPath(m.Name))
+		case <-ctx.Done():
+			_ = cmd.Process.Kill()
+			return fmt.Errorf("0-fs daemon did not mount %s in time", m.Target)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Stop implements the backend interface
+func (g *g8ufs) Stop(m mount) error {
+	if err := g.unmount(m.Target); err != nil {
+		log.Error().Err(err).Str("path", m.Target).Msg("fail to umount flist")
+	}
+
+	// 0-fs exits once its mount is gone
+	if m.PID != 0 {
+		if err := waitExit(stopTimeout, m.PID); err != nil {
+			log.Warn().Int("pid", m.PID).Msg("0-fs daemon did not stop, killing it")
+			if err := syscall.Kill(m.PID, syscall.SIGKILL); err != nil && err != syscall.ESRCH {
+				return errors.Wrapf(err, "failed to kill 0-fs daemon %d", m.PID)
+			}
+		}
+	}
+
+	if err := os.Remove(g.logPath(m.Name)); err != nil && !os.IsNotExist(err) {
+		log.Error().Err(err).Msg("failed to remove 0-fs daemon log")
+	}
+
+	return nil
+}
+
+// Check implements the backend interface
+func (g *g8ufs) Check(m mount) error {
+	if !running(m.PID) {
+		return fmt.Errorf("0-fs daemon %d is not running", m.PID)
+	}
+
+	mounted, err := g.mounted(m.Target)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check mount %s", m.Target)
+	} else if !mounted {
+		return fmt.Errorf("flist is not mounted at %s", m.Target)
+	}
+
+	return nil
+}
+
+// running checks if the process pid is alive
+func running(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// waitExit waits for at most timeout for the process pid to exit
+func waitExit(timeout time.Duration, pid int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	for running(pid) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return nil
+}
+
+// lazyUnmount detaches the filesystem mounted at path, a mount whose
+// daemon crashed can't be unmounted otherwise
+func lazyUnmount(path string) error {
+	return syscall.Unmount(path, syscall.MNT_DETACH)
+}
+
+// isMounted checks if a filesystem is mounted at path
+func isMounted(path string) (bool, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+
+	file, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// the mount point is the 5th field, see proc(5)
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		if unescapeMountPath(fields[4]) == path {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for a space)
+// of a path of the mountinfo file
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, `\`) {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if c, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
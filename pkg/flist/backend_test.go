@@ -0,0 +1,86 @@
+package flist
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestG8ufs(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	target := filepath.Join(root, "mountpoint")
+	require.NoError(os.Mkdir(target, 0755))
+
+	cmder := &testCommander{T: t}
+	g := newG8ufs(cmder, filepath.Join(root, "cache"), root)
+	fakeMounts(g)
+
+	m := mount{
+		Name:     "test",
+		Target:   target,
+		Flist:    filepath.Join(root, "flist"),
+		Storage:  "zdb://hub.grid.tf:9900",
+		ReadOnly: true,
+	}
+
+	require.NoError(g.Start(&m))
+	require.True(running(m.PID))
+	require.NoError(g.Check(m))
+
+	require.Equal(map[string]string{
+		"ro":          "",
+		"cache":       filepath.Join(root, "cache"),
+		"meta":        m.Flist,
+		"storage-url": m.Storage,
+		"log":         filepath.Join(root, "test.log"),
+	}, cmder.m)
+
+	// the daemon exits once unmounted
+	require.NoError(g.Stop(m))
+	require.False(running(m.PID))
+	require.Error(g.Check(m))
+
+	// a crashed daemon is detected
+	require.NoError(g.Start(&m))
+	require.NoError(syscall.Kill(m.PID, syscall.SIGKILL))
+	require.Eventually(func() bool {
+		return g.Check(m) != nil
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(g.Stop(m))
+
+	// a daemon that exits without mounting fails to start
+	g.commander = cmd(func(name string, arg ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", "exit 1")
+	})
+	require.Error(g.Start(&m))
+}
+
+func TestIsMounted(t *testing.T) {
+	require := require.New(t)
+
+	mounted, err := isMounted("/")
+	require.NoError(err)
+	require.True(mounted)
+
+	dir, err := ioutil.TempDir("", "flist_mount")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	mounted, err = isMounted(dir)
+	require.NoError(err)
+	require.False(mounted)
+
+	require.Equal("/mnt/my flist", unescapeMountPath(`/mnt/my\040flist`))
+	require.Equal(`/mnt/a\b`, unescapeMountPath(`/mnt/a\b`))
+}
@@ -1,9 +1,7 @@
 package flist
 
 import (
-	"bufio"
 	"bytes"
-	"context"
 	"crypto/md5"
 	"crypto/rand"
 	"fmt"
@@ -12,11 +10,12 @@ import (
 	"net/http"
 	"os"
 	"os/exec"
-	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
-	"time"
 
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
@@ -52,12 +51,24 @@ type flistModule struct {
 	mountpoint string
 	pid        string
 	log        string
+	images     string
 
-	storage   pkg.VolumeAllocater
-	commander commander
+	storage pkg.VolumeAllocater
+	// mounts supervises the 0-fs daemons serving the mounts
+	mounts *supervisor
+
+	// budget is the size limit of the cache in bytes
+	budget uint64
+	// gcLock prevents the garbage collection from evicting
+	// flists while they are being mounted
+	gcLock sync.RWMutex
+
+	// prefetching are the urls of the flists being prefetched
+	prefetching  map[string]bool
+	prefetchLock sync.Mutex
 }
 
-func newFlister(root string, storage pkg.VolumeAllocater, commander commander) pkg.Flister {
+func newFlister(root string, storage pkg.VolumeAllocater, commander commander, budget uint64) pkg.Flister {
 	if root == "" {
 		root = defaultRoot
 	}
@@ -72,7 +83,7 @@ func newFlister(root string, storage pkg.VolumeAllocater, commander commander) p
 	}
 
 	// prepare directory layout for the module
-	for _, path := range []string{"flist", "cache", "mountpoint", "pid", "log"} {
+	for _, path := range []string{"flist", "cache", "mountpoint", "pid", "log", "images"} {
 		p := filepath.Join(root, path)
 		if err := os.MkdirAll(p, 0755); err != nil {
 			panic(err)
@@ -83,17 +94,28 @@ func newFlister(root string, storage pkg.VolumeAllocater, commander commander) p
 		}
 	}
 
-	return &flistModule{
+	f := &flistModule{
 		root:       root,
 		flist:      filepath.Join(root, "flist"),
 		cache:      filepath.Join(root, "cache"),
 		mountpoint: filepath.Join(root, "mountpoint"),
 		pid:        filepath.Join(root, "pid"),
 		log:        filepath.Join(root, "log"),
+		images:     filepath.Join(root, "images"),
 
-		storage:   storage,
-		commander: commander,
+		storage:     storage,
+		budget:      budget,
+		prefetching: make(map[string]bool),
 	}
+
+	mounts, err := newSupervisor(filepath.Join(root, "mounts.json"), newG8ufs(commander, f.cache, f.log))
+	if err != nil {
+		panic(err)
+	}
+	f.mounts = mounts
+	f.migrate()
+
+	return f
 }
 
 type options []string
@@ -108,13 +130,31 @@ func (o options) Find(k string) int {
 	return -1
 }
 
-// New creates a new flistModule
-func New(root string, storage pkg.VolumeAllocater) pkg.Flister {
-	return newFlister(root, storage, cmd(exec.Command))
+// Value returns the value following the option k
+func (o options) Value(k string) string {
+	i := o.Find(k)
+	if i < 0 || i+1 >= len(o) {
+		return ""
+	}
+
+	return o[i+1]
+}
+
+// New creates a new flistModule. The least recently used files of the cache
+// are evicted when it grows over budget bytes, 0 means no limit
+func New(root string, storage pkg.VolumeAllocater, budget uint64) pkg.Flister {
+	f := newFlister(root, storage, cmd(exec.Command), budget).(*flistModule)
+	if budget != 0 {
+		go f.gcLoop()
+	}
+	go f.mounts.watch(superviseInterval)
+
+	return f
 }
 
 // NamedMount implements the Flister.NamedMount interface
 func (f *flistModule) NamedMount(name, url, storage string, opts pkg.MountOptions) (string, error) {
+	defer f.collect()
 	return f.mount(name, url, storage, opts)
 }
 
@@ -124,6 +164,8 @@ func (f *flistModule) Mount(url, storage string, opts pkg.MountOptions) (string,
 	if err != nil {
 		return "", errors.Wrap(err, "failed to generate random id for the mount")
 	}
+
+	defer f.collect()
 	return f.mount(rnd, url, storage, opts)
 }
 
@@ -136,6 +178,9 @@ func (f *flistModule) mountpath(name string) (string, error) {
 	return mountpath, nil
 }
 
+// md5Regex matches a hex encoded md5 as published by the hub
+var md5Regex = regexp.MustCompile(`^[a-f0-9]{32}$`)
+
 // ErrAlreadyMounted is returned when checking if a path has already
 // something mounted on it
 var ErrAlreadyMounted = errors.New("path is already mounted")
@@ -153,7 +198,9 @@ func (f *flistModule) valid(path string) error {
 		return fmt.Errorf("not a directory: %s", path)
 	}
 
-	if err := exec.Command("mountpoint", path).Run(); err == nil {
+	if mounted, err := isMounted(path); err != nil {
+		return errors.Wrapf(err, "failed to check mountpoint: %s", path)
+	} else if mounted {
 		return ErrAlreadyMounted
 	}
 
@@ -178,13 +225,31 @@ func (f *flistModule) mount(name, url, storage string, opts pkg.MountOptions) (s
 		storage = env.FlistURL
 	}
 
+	// the flist cannot be evicted until 0-fs uses it
+	f.gcLock.RLock()
+	defer f.gcLock.RUnlock()
+
 	flistPath, err := f.downloadFlist(url)
 	if err != nil {
 		sublog.Err(err).Msg("fail to download flist")
 		return "", err
 	}
 
-	var args []string
+	// the flist is checked before anything is created for the mount
+	if err := f.verifyFlist(url, flistPath, opts); err != nil {
+		sublog.Err(err).Msg("fail to verify flist")
+		return "", err
+	}
+
+	m := mount{
+		Name:     name,
+		Target:   mountpoint,
+		URL:      url,
+		Flist:    flistPath,
+		Storage:  storage,
+		ReadOnly: opts.ReadOnly,
+	}
+
 	if !opts.ReadOnly {
 		sublog.Info().Msgf("check if subvolume %s already exists", name)
 		// check if the filesystem doesn't already exists
@@ -203,9 +268,7 @@ func (f *flistModule) mount(name, url, storage string, opts pkg.MountOptions) (s
 			}
 		}
 
-		args = append(args, "-backend", path)
-	} else {
-		args = append(args, "-ro")
+		m.Backend = path
 	}
 
 	err = f.valid(mountpoint)
@@ -222,53 +285,24 @@ func (f *flistModule) mount(name, url, storage string, opts pkg.MountOptions) (s
 	if err := os.MkdirAll(mountpoint, 0755); err != nil {
 		return "", err
 	}
-	pidPath := filepath.Join(f.pid, name) + ".pid"
-	logPath := filepath.Join(f.log, name) + ".log"
-
-	args = append(args,
-		"-cache", f.cache,
-		"-meta", flistPath,
-		"-storage-url", storage,
-		"-daemon",
-		"-pid", pidPath,
-		"-log", logPath,
-		mountpoint,
-	)
-	sublog.Info().Strs("args", args).Msg("starting 0-fs daemon")
-	cmd := f.commander.Command("g8ufs", args...)
-
-	if out, err := cmd.CombinedOutput(); err != nil {
-		sublog.Err(err).Str("out", string(out)).Msg("fail to start 0-fs daemon")
-		return "", err
-	}
 
-	// wait for the daemon to be ready
-	// we check the pid file is created
-	if err := waitPidFile(time.Second*5, pidPath, true); err != nil {
-		sublog.Error().Err(err).Msg("pid file of 0-fs daemon not created")
-		return "", err
-	}
-	// and scan the logs after "mount ready"
-	if err := waitMountedLog(time.Second*5, logPath); err != nil {
-		sublog.Error().Err(err).Msg("0-fs daemon did not start properly")
+	// the supervisor starts the 0-fs daemon again if it stops
+	if err := f.mounts.Start(m); err != nil {
+		sublog.Err(err).Msg("fail to start 0-fs daemon")
 		return "", err
 	}
 
 	return mountpoint, nil
 }
 
-func (f *flistModule) getMountOptions(pidPath string) (options, error) {
-	pid, err := ioutil.ReadFile(pidPath)
+// daemonOptions returns the command line of the process pid
+func daemonOptions(pid int) (options, error) {
+	cmdline, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "cmdline"))
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to open pid file: %s", pidPath)
+		return nil, errors.Wrapf(err, "failed to read process (%d) cmdline", pid)
 	}
 
-	cmdline, err := ioutil.ReadFile(path.Join("/proc", string(pid), "cmdline"))
-	if err != nil {
-		return nil, errors.Wrapf(err, "failed to read mount (%s) cmdline", pidPath)
-	}
-
-	parts := bytes.Split(cmdline, []byte{0})
+	parts := bytes.Split(bytes.TrimRight(cmdline, "\x00"), []byte{0})
 
 	result := make(options, 0, len(parts))
 	for _, part := range parts {
@@ -278,24 +312,108 @@ func (f *flistModule) getMountOptions(pidPath string) (options, error) {
 	return result, nil
 }
 
-func (f *flistModule) HashFromRootPath(name string) (string, error) {
-	base := filepath.Base(name)
-	pidPath := filepath.Join(f.pid, base) + ".pid"
+// migrate tracks the mounts of the 0-fs daemons started by the previous
+// versions of the module, which only kept their pid in a pid file
+func (f *flistModule) migrate() {
+	infos, err := ioutil.ReadDir(f.pid)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list pid files")
+		return
+	}
 
-	opts, err := f.getMountOptions(pidPath)
+	for _, info := range infos {
+		if !strings.HasSuffix(info.Name(), ".pid") {
+			continue
+		}
+
+		pidPath := filepath.Join(f.pid, info.Name())
+		if err := f.migrateDaemon(pidPath); err != nil {
+			log.Error().Err(err).Str("path", pidPath).Msg("failed to migrate 0-fs daemon")
+		}
+
+		if err := os.Remove(pidPath); err != nil {
+			log.Error().Err(err).Msg("failed to remove pid file")
+		}
+	}
+}
+
+func (f *flistModule) migrateDaemon(pidPath string) error {
+	data, err := ioutil.ReadFile(pidPath)
 	if err != nil {
-		return "", err
+		return err
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return errors.Wrap(err, "invalid pid file")
+	}
+
+	opts, err := daemonOptions(pid)
+	if os.IsNotExist(errors.Cause(err)) || len(opts) == 0 {
+		// the daemon is not running anymore
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(pidPath), ".pid")
+	m := mount{
+		Name:     name,
+		Target:   opts[len(opts)-1],
+		Flist:    opts.Value("-meta"),
+		Storage:  opts.Value("-storage-url"),
+		Backend:  opts.Value("-backend"),
+		ReadOnly: opts.Find("-ro") >= 0,
+		PID:      pid,
+	}
+
+	if m.Target != filepath.Join(f.mountpoint, name) {
+		return fmt.Errorf("process %d is not the 0-fs daemon of mount %s", pid, name)
 	}
 
-	for _, opt := range opts {
-		// if option start with the flist meta path
-		if strings.HasPrefix(opt, f.flist) {
-			// extracting hash (dirname) from argument
-			return filepath.Base(opt), nil
+	log.Info().Str("name", name).Int("pid", pid).Msg("tracking mount of previous 0-fs daemon")
+	f.mounts.Track(m)
+	return nil
+}
+
+// HashFromRootPath implements the Flister.HashFromRootPath interface
+func (f *flistModule) HashFromRootPath(name string) (string, error) {
+	m, ok := f.mounts.Get(filepath.Base(name))
+	if !ok || m.Flist == "" {
+		return "", fmt.Errorf("could not find rootfs hash name")
+	}
+
+	return filepath.Base(m.Flist), nil
+}
+
+// List implements the Flister.List interface
+func (f *flistModule) List() ([]pkg.FlistMount, error) {
+	mounts := f.mounts.List()
+
+	result := make([]pkg.FlistMount, 0, len(mounts))
+	for _, m := range mounts {
+		mnt := pkg.FlistMount{
+			Name:     m.Name,
+			Path:     m.Target,
+			URL:      m.URL,
+			ReadOnly: m.ReadOnly,
+			State:    pkg.FlistMountHealthy,
+			Restarts: m.Restarts,
+			Error:    m.Error,
+		}
+
+		if !m.Image {
+			mnt.Hash = filepath.Base(m.Flist)
 		}
+
+		if m.Error != "" {
+			mnt.State = pkg.FlistMountFailed
+		}
+
+		result = append(result, mnt)
 	}
 
-	return "", fmt.Errorf("could not find rootfs hash name")
+	return result, nil
 }
 
 // NamedUmount implements the Flister.NamedUmount interface
@@ -308,10 +426,12 @@ func (f *flistModule) Umount(path string) error {
 	log.Info().Str("path", path).Msg("request unmount flist")
 
 	info, err := os.Stat(path)
-	if err != nil {
+	if errors.Is(err, syscall.ENOTCONN) {
+		// the 0-fs daemon of the mount crashed
+		log.Warn().Str("path", path).Msg("mount is not served anymore")
+	} else if err != nil {
 		return err
-	}
-	if !info.IsDir() {
+	} else if !info.IsDir() {
 		return fmt.Errorf("specified path is not a directory")
 	}
 
@@ -320,37 +440,30 @@ func (f *flistModule) Umount(path string) error {
 	}
 
 	_, name := filepath.Split(path)
-	pidPath := filepath.Join(f.pid, name) + ".pid"
 
-	opts, err := f.getMountOptions(pidPath)
+	m, tracked, err := f.mounts.Stop(name)
 	if err != nil {
-		log.Error().Err(err).Msg("failed to read mount options")
-
-		//we don't return an error in case the file is gone somehow
-		//but the mount is still there. So better to fail on next call
-		//than leaking
-	}
-
-	if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil {
-		log.Error().Err(err).Str("path", path).Msg("fail to umount flist")
-	}
-
-	if err := waitPidFile(time.Second*2, pidPath, false); err != nil {
 		log.Error().Err(err).Str("path", path).Msg("0-fs daemon did not stop properly")
 		return err
 	}
 
-	// clean up working dirs
-	logPath := filepath.Join(f.log, name) + ".log"
-	for _, path := range []string{logPath, path} {
-		if err := os.RemoveAll(path); err != nil {
-			log.Error().Err(err).Msg("fail to remove %s")
-			return err
+	if !tracked || m.Image {
+		// there is no 0-fs daemon behind the mounts of images
+		log.Debug().Str("path", path).Bool("tracked", tracked).Msg("no 0-fs daemon for mount")
+		if err := syscall.Unmount(path, syscall.MNT_DETACH); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("fail to umount flist")
 		}
 	}
 
-	// clean up subvolume should be done only for RW mounts.
-	if opts.Find("-ro") >= 0 {
+	// clean up working dirs
+	if err := os.RemoveAll(path); err != nil {
+		log.Error().Err(err).Msgf("fail to remove %s", path)
+		return err
+	}
+
+	// clean up subvolume should be done only for RW mounts. The
+	// subvolume of a mount that is not tracked is not known
+	if !tracked || m.ReadOnly {
 		return nil
 	}
 
@@ -391,14 +504,18 @@ func (f *flistModule) FlistHash(url string) (string, error) {
 func (f *flistModule) downloadFlist(url string) (string, error) {
 	// first check if the md5 of the flist is available
 	hash, err := f.FlistHash(url)
-	if err == nil {
-		flistPath := filepath.Join(f.flist, strings.TrimSpace(string(hash)))
+	if err == nil && !md5Regex.MatchString(hash) {
+		log.Warn().Str("url", url).Str("hash", hash).Msg("ignore invalid flist md5")
+		hash = ""
+	} else if err == nil {
+		flistPath := filepath.Join(f.flist, hash)
 		_, err = os.Stat(flistPath)
 		if err != nil && !os.IsNotExist(err) {
 			return "", err
 		}
 		if err == nil {
 			log.Info().Str("url", url).Msg("flist already in cache")
+			touch(flistPath)
 			// flist is already present locally, just return its path
 			return flistPath, nil
 		}
@@ -416,7 +533,18 @@ func (f *flistModule) downloadFlist(url string) (string, error) {
 		return "", fmt.Errorf("fail to download flist: %v", resp.Status)
 	}
 
-	return f.saveFlist(resp.Body)
+	path, err := f.saveFlist(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	// the md5 published by the hub only guards against a corrupted download,
+	// use the checksum or signature of the mount options to trust a flist
+	if len(hash) != 0 && filepath.Base(path) != hash {
+		return "", fmt.Errorf("downloaded flist %s doesn't match its md5 %s", url, hash)
+	}
+
+	return path, nil
 }
 
 // saveFlist save the flist contained in r
@@ -456,79 +584,4 @@ func random() (string, error) {
 	return fmt.Sprintf("%x", b), err
 }
 
-// waitPidFile wait for a file pointed by path to be created or deleted
-// for at most timeout duration
-// is exists is true, it waits for the file to exists
-// else it waits for the file to be deleted
-func waitPidFile(timeout time.Duration, path string, exists bool) error {
-	const delay = time.Millisecond * 100
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			_, err := os.Stat(path)
-			// check exist but the file is not there yet,
-			// or check NOT exist but it is still there
-			// we try again
-			if (exists && os.IsNotExist(err)) || (!exists && err == nil) {
-				time.Sleep(delay)
-			} else if err != nil && !os.IsNotExist(err) {
-				//another error that is NOT IsNotExist.
-				return err
-			} else {
-				return nil
-			}
-		}
-	}
-}
-
-func waitMountedLog(timeout time.Duration, logfile string) error {
-	const target = "mount ready"
-	const delay = time.Millisecond * 500
-
-	f, err := os.Open(logfile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	br := bufio.NewReader(f)
-
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	defer cancel()
-
-	// this goroutine looks for "mount ready"
-	// in the logs of the 0-fs
-	cErr := make(chan error)
-	go func(ctx context.Context, r io.Reader, cErr chan<- error) {
-		for {
-			select {
-			case <-ctx.Done():
-				// ensure we don't leak the goroutine
-				cErr <- ctx.Err()
-			default:
-				line, err := br.ReadString('\n')
-				if err != nil {
-					time.Sleep(delay)
-					continue
-				}
-
-				if !strings.Contains(line, target) {
-					time.Sleep(delay)
-					continue
-				}
-				// found
-				cErr <- nil
-				return
-			}
-		}
-	}(ctx, br, cErr)
-
-	return <-cErr
-}
-
 var _ pkg.Flister = (*flistModule)(nil)
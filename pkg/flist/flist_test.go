@@ -2,7 +2,6 @@ package flist
 
 import (
 	"bytes"
-	"context"
 	"io/ioutil"
 	"os"
 	"os/exec"
@@ -10,7 +9,6 @@ import (
 	"strings"
 	"testing"
 	"text/template"
-	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,9 +17,11 @@ import (
 )
 
 var (
+	// templ emulates a 0-fs daemon, it marks its target as mounted
+	// and runs until the mark is removed
 	templ = template.Must(template.New("script").Parse(`
-echo $PPID > {{.pid}}
-echo "mount ready" > {{.log}}
+touch {{.target}}/.mounted
+while [ -e {{.target}}/.mounted ]; do sleep 0.1; done
 `))
 )
 
@@ -42,12 +42,24 @@ func (s *StorageMock) ReleaseFilesystem(name string) error {
 	return args.Error(0)
 }
 
+// ResizeFilesystem resizes filesystem mock
+func (s *StorageMock) ResizeFilesystem(name string, size uint64) error {
+	args := s.Called(name, size)
+	return args.Error(0)
+}
+
 // Path implements the pkg.StorageModules interfaces
 func (s *StorageMock) Path(name string) (path string, err error) {
 	args := s.Called(name)
 	return args.String(0), args.Error(1)
 }
 
+// SnapshotFilesystem snapshots filesystem mock
+func (s *StorageMock) SnapshotFilesystem(name string, snapshot string) (string, error) {
+	args := s.Called(name, snapshot)
+	return args.String(0), args.Error(1)
+}
+
 type testCommander struct {
 	*testing.T
 	m map[string]string
@@ -83,11 +95,12 @@ func (t *testCommander) Command(name string, args ...string) *exec.Cmd {
 		t.Fatal("invalid command name, expected 'g8ufs'")
 	}
 
-	m, _ := t.args(args...)
+	_, r := t.args(args...)
+	require.Len(t.T, r, 1, "expected the mount target")
+
 	var script bytes.Buffer
 	err := templ.Execute(&script, map[string]string{
-		"pid": m["pid"],
-		"log": m["log"],
+		"target": r[0],
 	})
 
 	require.NoError(t.T, err)
@@ -95,6 +108,21 @@ func (t *testCommander) Command(name string, args ...string) *exec.Cmd {
 	return exec.Command("sh", "-c", script.String())
 }
 
+// fakeMounts makes the backend check the mark of the testCommander
+// daemons instead of the actual mounts
+func fakeMounts(g *g8ufs) {
+	g.mounted = func(path string) (bool, error) {
+		_, err := os.Stat(filepath.Join(path, ".mounted"))
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	g.unmount = func(path string) error {
+		return os.Remove(filepath.Join(path, ".mounted"))
+	}
+}
+
 func TestCommander(t *testing.T) {
 	cmder := testCommander{T: t}
 
@@ -122,7 +150,8 @@ func TestMountUnmount(t *testing.T) {
 
 	defer os.RemoveAll(root)
 
-	flister := newFlister(root, strg, cmder)
+	flister := newFlister(root, strg, cmder, 0)
+	fakeMounts(flister.(*flistModule).mounts.backend.(*g8ufs))
 
 	strg.On("Path", mock.Anything).Return("/my/backend", nil)
 
@@ -132,8 +161,6 @@ func TestMountUnmount(t *testing.T) {
 	mnt, err := flister.Mount("https://hub.grid.tf/thabet/redis.flist", "", pkg.DefaultMountOptions)
 	require.NoError(t, err)
 
-	// Trick flister into thinking that 0-fs has exited
-	os.Remove(cmder.m["pid"])
 	strg.On("ReleaseFilesystem", filepath.Base(mnt)).Return(nil)
 
 	err = flister.Umount(mnt)
@@ -151,7 +178,8 @@ func TestIsolation(t *testing.T) {
 
 	defer os.RemoveAll(root)
 
-	flister := newFlister(root, strg, cmder)
+	flister := newFlister(root, strg, cmder, 0)
+	fakeMounts(flister.(*flistModule).mounts.backend.(*g8ufs))
 
 	strg.On("Path", mock.Anything).Return("/my/backend", nil)
 
@@ -171,13 +199,13 @@ func TestIsolation(t *testing.T) {
 
 	// the 2 mounts since they are exactly the same flist
 	// should have same meta, and of course same cache
-	// but a different backend and pid
+	// but a different backend and log
 	require.Equal(args1["cache"], args2["cache"])
 	require.Equal(args1["meta"], args2["meta"])
 	//TODO: the backend url is return by the storage mock, this is why this
 	// is failing.
 	// require.NotEqual(args1["backend"], args2["backend"])
-	require.NotEqual(args1["pid"], args2["pid"])
+	require.NotEqual(args1["log"], args2["log"])
 }
 
 func TestDownloadFlist(t *testing.T) {
@@ -191,7 +219,7 @@ func TestDownloadFlist(t *testing.T) {
 
 	//defer os.RemoveAll(root)
 
-	x := newFlister(root, strg, cmder)
+	x := newFlister(root, strg, cmder, 0)
 
 	f, ok := x.(*flistModule)
 	require.True(ok)
@@ -212,47 +240,3 @@ func TestDownloadFlist(t *testing.T) {
 	require.NoError(err)
 	assert.Equal(info1.ModTime(), info2.ModTime())
 }
-
-func TestWaitPIDFileExists(t *testing.T) {
-	require := require.New(t)
-	const testFile = "/tmp/wait.exists.test"
-	os.Remove(testFile)
-
-	out := make(chan error)
-	go func(out chan<- error) {
-		out <- waitPidFile(2*time.Second, testFile, true)
-	}(out)
-
-	os.Create(testFile)
-	err := <-out
-	require.NoError(err)
-}
-
-func TestWaitPIDFileDeleted(t *testing.T) {
-	require := require.New(t)
-	const testFile = "/tmp/wait.deleted.test"
-	os.Create(testFile)
-
-	out := make(chan error)
-	go func(out chan<- error) {
-		out <- waitPidFile(2*time.Second, testFile, false)
-	}(out)
-
-	os.Remove(testFile)
-	err := <-out
-	require.NoError(err)
-}
-
-func TestWaitPIDFileTimeout(t *testing.T) {
-	require := require.New(t)
-	const testFile = "/tmp/wait.deleted.test"
-	os.Create(testFile)
-
-	out := make(chan error)
-	go func(out chan<- error) {
-		out <- waitPidFile(1*time.Second, testFile, false)
-	}(out)
-
-	err := <-out
-	require.Equal(context.DeadlineExceeded, err)
-}
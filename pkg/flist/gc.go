@@ -0,0 +1,239 @@
+package flist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// DefaultCacheBudget is the default size budget of the cache in bytes
+	DefaultCacheBudget = 5 * 1024 * mib
+
+	// gcInterval is the interval of the budget enforcement, the chunk
+	// cache grows while the mounts are used
+	gcInterval = time.Hour
+)
+
+// cacheEntry is a file of the cache that can be evicted
+type cacheEntry struct {
+	path    string
+	size    uint64
+	lastUse time.Time
+	// flist is true for the downloaded flists and image layers, which
+	// are only needed to mount, false for chunks
+	flist bool
+}
+
+// cacheEntries lists the files of the cache, the flists that
+// back an active mount are not returned
+func (f *flistModule) cacheEntries() (entries []cacheEntry, usage pkg.FlistCacheUsage, err error) {
+	mounted := f.mountedFlists()
+
+	infos, err := ioutil.ReadDir(f.flist)
+	if err != nil {
+		return nil, usage, errors.Wrap(err, "failed to list downloaded flists")
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		path := filepath.Join(f.flist, info.Name())
+		usage.Flists += uint64(info.Size())
+		usage.FlistsCount++
+
+		if mounted[path] {
+			usage.Mounted++
+			continue
+		}
+
+		// the atime of a flist is updated each time it is mounted
+		entries = append(entries, cacheEntry{
+			path:    path,
+			size:    uint64(info.Size()),
+			lastUse: lastAccess(info),
+			flist:   true,
+		})
+	}
+
+	infos, err = ioutil.ReadDir(f.images)
+	if err != nil {
+		return nil, usage, errors.Wrap(err, "failed to list downloaded image layers")
+	}
+
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		usage.Images += uint64(info.Size())
+		entries = append(entries, cacheEntry{
+			path:    filepath.Join(f.images, info.Name()),
+			size:    uint64(info.Size()),
+			lastUse: lastAccess(info),
+			flist:   true,
+		})
+	}
+
+	err = filepath.Walk(f.cache, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// evicted by 0-fs in the meantime
+				return nil
+			}
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		usage.Chunks += uint64(info.Size())
+		entries = append(entries, cacheEntry{
+			path:    path,
+			size:    uint64(info.Size()),
+			lastUse: lastAccess(info),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, usage, errors.Wrap(err, "failed to list cached chunks")
+	}
+
+	usage.Budget = f.budget
+
+	return entries, usage, nil
+}
+
+// mountedFlists returns the path of the flists used by the mounts
+func (f *flistModule) mountedFlists() map[string]bool {
+	mounted := make(map[string]bool)
+	for _, m := range f.mounts.List() {
+		if m.Flist != "" {
+			mounted[m.Flist] = true
+		}
+	}
+
+	return mounted
+}
+
+// lastAccess returns the last time the file was read or written
+func lastAccess(info os.FileInfo) time.Time {
+	t := info.ModTime()
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return t
+	}
+
+	atime := time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+	if atime.After(t) {
+		return atime
+	}
+
+	return t
+}
+
+// CacheUsage implements the Flister.CacheUsage interface
+func (f *flistModule) CacheUsage() (pkg.FlistCacheUsage, error) {
+	f.gcLock.RLock()
+	defer f.gcLock.RUnlock()
+
+	_, usage, err := f.cacheEntries()
+	return usage, err
+}
+
+// Prune implements the Flister.Prune interface
+func (f *flistModule) Prune() (uint64, error) {
+	log.Info().Msg("prune flist cache")
+	return f.gc(true)
+}
+
+// gc evicts the least recently used files of the cache until it fits the
+// budget. If all is true, all the image layers and the flists that don't back
+// a mount are evicted regardless of the budget
+func (f *flistModule) gc(all bool) (uint64, error) {
+	f.gcLock.Lock()
+	defer f.gcLock.Unlock()
+
+	entries, usage, err := f.cacheEntries()
+	if err != nil {
+		return 0, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].lastUse.Before(entries[j].lastUse)
+	})
+
+	size := usage.Flists + usage.Images + usage.Chunks
+	var freed uint64
+	for _, entry := range entries {
+		overBudget := f.budget != 0 && size > f.budget
+		if !overBudget && !(all && entry.flist) {
+			continue
+		}
+
+		if err := os.Remove(entry.path); err != nil && !os.IsNotExist(err) {
+			log.Error().Err(err).Str("path", entry.path).Msg("failed to evict file from flist cache")
+			continue
+		}
+
+		size -= entry.size
+		freed += entry.size
+	}
+
+	if freed > 0 {
+		log.Info().
+			Uint64("freed", freed).
+			Uint64("size", size).
+			Uint64("budget", f.budget).
+			Msg("flist cache garbage collected")
+	}
+
+	return freed, nil
+}
+
+// collect enforces the budget of the cache in the background
+func (f *flistModule) collect() {
+	if f.budget == 0 {
+		return
+	}
+
+	go func() {
+		if _, err := f.gc(false); err != nil {
+			log.Error().Err(err).Msg("failed to garbage collect flist cache")
+		}
+	}()
+}
+
+// gcLoop enforces the budget of the cache periodically
+func (f *flistModule) gcLoop() {
+	for range time.Tick(gcInterval) {
+		if _, err := f.gc(false); err != nil {
+			log.Error().Err(err).Msg("failed to garbage collect flist cache")
+		}
+	}
+}
+
+// touch marks the flist or layer at path as used by updating its access time
+func touch(path string) {
+	info, err := os.Stat(path)
+	if err == nil {
+		err = os.Chtimes(path, time.Now(), info.ModTime())
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("path", path).Msg("failed to update access time")
+	}
+}
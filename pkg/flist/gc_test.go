@@ -0,0 +1,78 @@
+package flist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeCacheFile creates a file of size bytes at path, last used at t
+func writeCacheFile(t *testing.T, path string, size int, at time.Time) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, ioutil.WriteFile(path, make([]byte, size), 0644))
+	require.NoError(t, os.Chtimes(path, at, at))
+}
+
+func TestCacheGC(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	f := newFlister(root, &StorageMock{}, &testCommander{T: t}, 900).(*flistModule)
+
+	now := time.Now()
+	mounted := filepath.Join(f.flist, "mounted")
+	old := filepath.Join(f.flist, "old")
+	recent := filepath.Join(f.flist, "recent")
+	oldChunk := filepath.Join(f.cache, "aa", "old")
+	recentChunk := filepath.Join(f.cache, "bb", "recent")
+
+	writeCacheFile(t, mounted, 100, now.Add(-4*time.Hour))
+	writeCacheFile(t, old, 300, now.Add(-3*time.Hour))
+	writeCacheFile(t, oldChunk, 400, now.Add(-2*time.Hour))
+	writeCacheFile(t, recent, 200, now.Add(-time.Hour))
+	writeCacheFile(t, recentChunk, 300, now)
+
+	// a mount using the mounted flist
+	f.mounts.Track(mount{Name: "mount", Flist: mounted})
+
+	usage, err := f.CacheUsage()
+	require.NoError(err)
+	require.EqualValues(900, usage.Budget)
+	require.EqualValues(600, usage.Flists)
+	require.EqualValues(3, usage.FlistsCount)
+	require.EqualValues(1, usage.Mounted)
+	require.EqualValues(700, usage.Chunks)
+
+	// the least recently used files are evicted
+	// until the cache fits the budget
+	freed, err := f.gc(false)
+	require.NoError(err)
+	require.EqualValues(700, freed)
+
+	for _, path := range []string{old, oldChunk} {
+		_, err := os.Stat(path)
+		require.True(os.IsNotExist(err), path)
+	}
+
+	for _, path := range []string{mounted, recent, recentChunk} {
+		_, err := os.Stat(path)
+		require.NoError(err, path)
+	}
+
+	// prune evicts all the flists not mounted
+	freed, err = f.Prune()
+	require.NoError(err)
+	require.EqualValues(200, freed)
+
+	_, err = os.Stat(recent)
+	require.True(os.IsNotExist(err))
+	_, err = os.Stat(mounted)
+	require.NoError(err)
+}
@@ -0,0 +1,377 @@
+package flist
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// whiteoutPrefix marks a file of a lower layer deleted by a layer
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque marks a directory whose content in the lower layers
+	// is hidden by a layer
+	whiteoutOpaque = ".wh..wh..opq"
+
+	// maxSymlinks is the number of symlinks followed when resolving a
+	// path inside an image before giving up
+	maxSymlinks = 255
+)
+
+// MountImage implements the Flister.MountImage interface
+func (f *flistModule) MountImage(name, image string, opts pkg.MountOptions) (string, error) {
+	sublog := log.With().Str("name", name).Str("image", image).Logger()
+	sublog.Info().Msg("request to mount image")
+
+	mountpoint, err := f.mountpath(name)
+	if err != nil {
+		return "", err
+	}
+
+	err = f.valid(mountpoint)
+	if errors.Is(err, ErrAlreadyMounted) {
+		sublog.Info().Msgf("image is already mounted at %s, nothing more to do", mountpoint)
+		return mountpoint, nil
+	}
+
+	if err != nil {
+		return "", errors.Wrap(err, "invalid mount point")
+	}
+
+	// the image is only unpacked once, the subvolume is then the
+	// read-write root filesystem of the container
+	root, err := f.storage.Path(name)
+	if err != nil {
+		if opts.ReadOnly || opts.Limit == 0 || len(opts.Type) == 0 {
+			return "", fmt.Errorf("invalid mount option, missing disk type and/or size")
+		}
+
+		defer f.collect()
+		if root, err = f.unpackImage(name, image, opts); err != nil {
+			sublog.Err(err).Msg("fail to unpack image")
+			return "", err
+		}
+	}
+
+	if err := os.MkdirAll(mountpoint, 0755); err != nil {
+		return "", err
+	}
+
+	if err := syscall.Mount(root, mountpoint, "", syscall.MS_BIND, ""); err != nil {
+		return "", errors.Wrapf(err, "failed to mount image root filesystem at %s", mountpoint)
+	}
+
+	f.mounts.Track(mount{
+		Name:    name,
+		Target:  mountpoint,
+		URL:     image,
+		Image:   true,
+		Backend: root,
+	})
+
+	return mountpoint, nil
+}
+
+// unpackImage pulls image and unpacks its layers in a new subvolume called
+// name. Returns the path of the subvolume
+func (f *flistModule) unpackImage(name, image string, opts pkg.MountOptions) (string, error) {
+	ref, err := parseImageRef(image)
+	if err != nil {
+		return "", err
+	}
+
+	// the layers cannot be evicted until they are unpacked
+	f.gcLock.RLock()
+	defer f.gcLock.RUnlock()
+
+	reg := newRegistry(ref)
+	m, err := reg.resolve()
+	if err != nil {
+		return "", err
+	}
+
+	if len(m.Layers) == 0 {
+		return "", fmt.Errorf("image %s has no layers", ref)
+	}
+
+	// all the layers are downloaded before the subvolume is created
+	// so a registry error doesn't leave anything behind
+	layers := make([]string, 0, len(m.Layers))
+	for _, layer := range m.Layers {
+		if !supportedLayers[layer.MediaType] {
+			return "", fmt.Errorf("unsupported layer media type '%s' of image %s", layer.MediaType, ref)
+		}
+
+		path, err := f.downloadBlob(reg, layer.Digest)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to download layer %s of image %s", layer.Digest, ref)
+		}
+
+		layers = append(layers, path)
+	}
+
+	root, err := f.storage.CreateFilesystem(name, opts.Limit*mib, opts.Type)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create subvolume for image")
+	}
+
+	for i, layer := range layers {
+		if err := unpackLayer(layer, root); err != nil {
+			if err := f.storage.ReleaseFilesystem(name); err != nil {
+				log.Error().Err(err).Str("name", name).Msg("failed to clean up image subvolume")
+			}
+			return "", errors.Wrapf(err, "failed to unpack layer %s of image %s", m.Layers[i].Digest, ref)
+		}
+	}
+
+	log.Info().Str("image", ref.String()).Str("name", name).Int("layers", len(layers)).Msg("image unpacked")
+	return root, nil
+}
+
+// downloadBlob downloads the blob with digest from the registry into the
+// images cache, unless it is already there. Returns the path of the blob
+func (f *flistModule) downloadBlob(reg *registry, digest string) (string, error) {
+	sum, err := digestHex(digest)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(f.images, sum)
+	if _, err := os.Stat(path); err == nil {
+		touch(path)
+		return path, nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	resp, err := reg.get("blobs/" + digest)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	tmp, err := ioutil.TempFile(f.images, "*_blob_temp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		return "", err
+	}
+
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return "", fmt.Errorf("blob doesn't match its digest %s", digest)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// unpackLayer extracts the layer at path, plain or gzip compressed,
+// on top of the directory root
+func unpackLayer(path, root string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = bufio.NewReader(file)
+	magic, err := r.(*bufio.Reader).Peek(2)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	return applyLayer(r, root)
+}
+
+// applyLayer extracts the layer tar stream read from r on top of the
+// directory root. The whiteouts of the layer delete the files of the
+// lower layers
+func applyLayer(r io.Reader, root string) error {
+	root = filepath.Clean(root)
+	tr := tar.NewReader(r)
+
+	// the paths of this layer, an opaque whiteout only
+	// hides the content of the lower layers
+	extracted := make(map[string]bool)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		name := filepath.Clean("/" + hdr.Name)
+		if name == "/" {
+			continue
+		}
+
+		target, err := layerPath(root, name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid layer entry '%s'", hdr.Name)
+		}
+
+		dir, base := filepath.Split(target)
+		if base == whiteoutOpaque {
+			if err := removeLower(filepath.Clean(dir), extracted); err != nil {
+				return errors.Wrapf(err, "failed to apply opaque whiteout '%s'", hdr.Name)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			deleted := strings.TrimPrefix(base, whiteoutPrefix)
+			if deleted == "" || deleted == "." || deleted == ".." {
+				return fmt.Errorf("invalid whiteout '%s'", hdr.Name)
+			}
+
+			if err := os.RemoveAll(filepath.Join(dir, deleted)); err != nil {
+				return errors.Wrapf(err, "failed to apply whiteout '%s'", hdr.Name)
+			}
+			continue
+		}
+
+		var source string
+		if hdr.Typeflag == tar.TypeLink {
+			if source, err = layerPath(root, hdr.Linkname); err != nil {
+				return errors.Wrapf(err, "invalid layer link '%s'", hdr.Linkname)
+			}
+		}
+
+		// an entry replaces the one of the lower layers, except
+		// directories which are merged
+		if info, err := os.Lstat(target); err == nil {
+			if !info.IsDir() || hdr.Typeflag != tar.TypeDir {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		if err := extractEntry(tr, hdr, target, source); err != nil {
+			return err
+		}
+
+		// the parents of the entry are part of the layer too
+		for path := target; path != root; path = filepath.Dir(path) {
+			extracted[path] = true
+		}
+	}
+}
+
+// removeLower removes the content of dir that was not extracted from
+// the current layer
+func removeLower(dir string, extracted map[string]bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if path == dir || extracted[path] {
+			return nil
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return filepath.SkipDir
+		}
+
+		return nil
+	})
+}
+
+// layerPath returns the path of the entry name of a layer extracted in root.
+// The symlinks of the parent directories are resolved as if root was the
+// root of the filesystem, so the image can't write outside of it. The
+// entry itself is not resolved since it replaces whatever exists there
+func layerPath(root, name string) (string, error) {
+	name = filepath.Clean("/" + name)
+	if name == "/" {
+		return "", fmt.Errorf("invalid path '%s'", name)
+	}
+
+	resolved := "/"
+	parts := strings.Split(filepath.Dir(name), "/")
+	links := 0
+	for len(parts) > 0 {
+		part := parts[0]
+		parts = parts[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) || (err == nil && info.Mode()&os.ModeSymlink == 0) {
+			resolved = next
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", fmt.Errorf("too many levels of symbolic links in '%s'", name)
+		}
+
+		link, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+
+		if filepath.IsAbs(link) {
+			resolved = "/"
+		}
+		parts = append(strings.Split(link, "/"), parts...)
+	}
+
+	return filepath.Join(root, resolved, filepath.Base(name)), nil
+}
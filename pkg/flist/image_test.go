@@ -0,0 +1,267 @@
+package flist
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+type layerEntry struct {
+	name     string
+	typeflag byte
+	content  string
+	link     string
+}
+
+// buildLayer returns a gzip compressed layer with entries
+func buildLayer(t *testing.T, entries ...layerEntry) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.name,
+			Typeflag: entry.typeflag,
+			Linkname: entry.link,
+			Mode:     0644,
+			Size:     int64(len(entry.content)),
+			Uid:      os.Getuid(),
+			Gid:      os.Getgid(),
+		}
+		if entry.typeflag == tar.TypeDir {
+			hdr.Mode = 0755
+		}
+
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write([]byte(entry.content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func digestOf(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// testRegistry is a registry stand-in serving a single image as
+// an index, that requires a bearer token
+func testRegistry(t *testing.T, layers ...[]byte) *httptest.Server {
+	blobs := make(map[string][]byte)
+	var descriptors []descriptor
+	for _, layer := range layers {
+		blobs[digestOf(layer)] = layer
+		descriptors = append(descriptors, descriptor{
+			MediaType: mediaTypeOCILayerGzip,
+			Digest:    digestOf(layer),
+			Size:      int64(len(layer)),
+		})
+	}
+
+	image, err := json.Marshal(manifest{MediaType: mediaTypeOCIManifest, Layers: descriptors})
+	require.NoError(t, err)
+
+	index, err := json.Marshal(manifest{
+		MediaType: mediaTypeOCIIndex,
+		Manifests: []descriptor{
+			{MediaType: mediaTypeOCIManifest, Digest: digestOf([]byte("other")), Platform: &platform{OS: "windows", Architecture: runtime.GOARCH}},
+			{MediaType: mediaTypeOCIManifest, Digest: digestOf(image), Platform: &platform{OS: "linux", Architecture: runtime.GOARCH}},
+		},
+	})
+	require.NoError(t, err)
+
+	manifests := map[string][]byte{
+		"v1":            index,
+		digestOf(image): image,
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:test/app:pull" {
+				http.Error(w, "invalid scope", http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var data []byte
+		if ref := strings.TrimPrefix(r.URL.Path, "/v2/test/app/manifests/"); ref != r.URL.Path {
+			data = manifests[ref]
+		} else if digest := strings.TrimPrefix(r.URL.Path, "/v2/test/app/blobs/"); digest != r.URL.Path {
+			data = blobs[digest]
+		}
+
+		if data == nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+
+	return server
+}
+
+func TestParseImageRef(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+
+	cases := []struct {
+		image string
+		ref   imageRef
+	}{
+		{"redis", imageRef{defaultRegistry, "library/redis", "latest"}},
+		{"redis:6", imageRef{defaultRegistry, "library/redis", "6"}},
+		{"docker.io/user/app:1.0", imageRef{defaultRegistry, "user/app", "1.0"}},
+		{"localhost:5000/app", imageRef{"localhost:5000", "app", "latest"}},
+		{"ghcr.io/org/team/app:v1@" + digest, imageRef{"ghcr.io", "org/team/app", digest}},
+		{"user/app@" + digest, imageRef{defaultRegistry, "user/app", digest}},
+	}
+
+	for _, c := range cases {
+		ref, err := parseImageRef(c.image)
+		require.NoError(t, err, c.image)
+		require.Equal(t, c.ref, ref, c.image)
+	}
+
+	for _, image := range []string{"", "app@sha256:abc", "User/App", "localhost:5000/../app"} {
+		_, err := parseImageRef(image)
+		require.Error(t, err, image)
+	}
+}
+
+func TestApplyLayer(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "image_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	outside, err := ioutil.TempDir("", "image_outside")
+	require.NoError(err)
+	defer os.RemoveAll(outside)
+
+	base := buildLayer(t,
+		layerEntry{name: "etc/", typeflag: tar.TypeDir},
+		layerEntry{name: "etc/config", typeflag: tar.TypeReg, content: "base"},
+		layerEntry{name: "etc/removed", typeflag: tar.TypeReg, content: "base"},
+		layerEntry{name: "opt/", typeflag: tar.TypeDir},
+		layerEntry{name: "opt/lib/", typeflag: tar.TypeDir},
+		layerEntry{name: "opt/lib/old", typeflag: tar.TypeReg, content: "base"},
+		layerEntry{name: "bin", typeflag: tar.TypeSymlink, link: "/usr/bin"},
+		layerEntry{name: "escape", typeflag: tar.TypeSymlink, link: outside},
+	)
+
+	upper := buildLayer(t,
+		layerEntry{name: "etc/config", typeflag: tar.TypeReg, content: "upper"},
+		layerEntry{name: "etc/.wh.removed", typeflag: tar.TypeReg},
+		layerEntry{name: "opt/lib/new", typeflag: tar.TypeReg, content: "upper"},
+		layerEntry{name: "opt/.wh..wh..opq", typeflag: tar.TypeReg},
+		layerEntry{name: "bin/sh", typeflag: tar.TypeReg, content: "shell"},
+		layerEntry{name: "escape/file", typeflag: tar.TypeReg, content: "escaped"},
+		layerEntry{name: "etc/hosts", typeflag: tar.TypeLink, link: "/etc/config"},
+	)
+
+	for _, layer := range [][]byte{base, upper} {
+		require.NoError(applyLayer(gzipReader(t, layer), root))
+	}
+
+	read := func(path string) string {
+		data, err := ioutil.ReadFile(filepath.Join(root, path))
+		require.NoError(err, path)
+		return string(data)
+	}
+
+	require.Equal("upper", read("etc/config"))
+	require.Equal("upper", read("etc/hosts"))
+	require.Equal("upper", read("opt/lib/new"))
+
+	// symlinks are resolved inside the image
+	require.Equal("shell", read("usr/bin/sh"))
+	require.Equal("escaped", read(filepath.Join(outside, "file")))
+	_, err = os.Stat(filepath.Join(outside, "file"))
+	require.True(os.IsNotExist(err))
+
+	for _, path := range []string{"etc/removed", "etc/.wh.removed", "opt/lib/old", "opt/.wh..wh..opq"} {
+		_, err := os.Lstat(filepath.Join(root, path))
+		require.True(os.IsNotExist(err), path)
+	}
+}
+
+func gzipReader(t *testing.T, data []byte) *gzip.Reader {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	return r
+}
+
+func TestUnpackImage(t *testing.T) {
+	require := require.New(t)
+
+	base := buildLayer(t,
+		layerEntry{name: "bin/", typeflag: tar.TypeDir},
+		layerEntry{name: "bin/app", typeflag: tar.TypeReg, content: "v1"},
+	)
+	upper := buildLayer(t,
+		layerEntry{name: "bin/app", typeflag: tar.TypeReg, content: "v2"},
+	)
+
+	server := testRegistry(t, base, upper)
+	defer server.Close()
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	subvolume := filepath.Join(root, "subvolume")
+	require.NoError(os.Mkdir(subvolume, 0755))
+
+	strg := &StorageMock{}
+	strg.On("CreateFilesystem", "app", uint64(256*mib), pkg.SSDDevice).
+		Return(subvolume, nil)
+
+	f := newFlister(filepath.Join(root, "module"), strg, &testCommander{T: t}, 0).(*flistModule)
+
+	image := strings.TrimPrefix(server.URL, "http://") + "/test/app:v1"
+	path, err := f.unpackImage("app", image, pkg.DefaultMountOptions)
+	require.NoError(err)
+	require.Equal(subvolume, path)
+
+	data, err := ioutil.ReadFile(filepath.Join(subvolume, "bin", "app"))
+	require.NoError(err)
+	require.Equal("v2", string(data))
+
+	// the layers are kept in the cache
+	usage, err := f.CacheUsage()
+	require.NoError(err)
+	require.EqualValues(len(base)+len(upper), usage.Images)
+
+	freed, err := f.Prune()
+	require.NoError(err)
+	require.EqualValues(len(base)+len(upper), freed)
+
+	_, err = f.unpackImage("app", strings.TrimSuffix(image, ":v1")+":v2", pkg.DefaultMountOptions)
+	require.Error(err)
+}
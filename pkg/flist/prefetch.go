@@ -0,0 +1,180 @@
+package flist
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// prefetchManifest is the file at the root of a flist listing the files
+	// to prefetch, one path per line. Without it, all the files are prefetched
+	prefetchManifest = ".prefetch"
+	// prefetchShare is the fraction of the cache budget a prefetch can fill,
+	// so a single flist doesn't evict the chunks of the running workloads
+	prefetchShare = 4
+)
+
+// errPrefetchLimit stops the prefetch once the limit is reached
+var errPrefetchLimit = errors.New("prefetch limit reached")
+
+// Prefetch implements the Flister.Prefetch interface
+func (f *flistModule) Prefetch(url, storage string) error {
+	sublog := log.With().Str("url", url).Logger()
+
+	f.prefetchLock.Lock()
+	if f.prefetching[url] {
+		f.prefetchLock.Unlock()
+		sublog.Debug().Msg("flist is already being prefetched")
+		return nil
+	}
+	f.prefetching[url] = true
+	f.prefetchLock.Unlock()
+
+	defer func() {
+		f.prefetchLock.Lock()
+		delete(f.prefetching, url)
+		f.prefetchLock.Unlock()
+	}()
+
+	// the chunks are fetched through a temporary read-only mount,
+	// that shares its cache with all the other mounts
+	mnt, err := f.Mount(url, storage, pkg.ReadOnlyMountOptions)
+	if err != nil {
+		return errors.Wrapf(err, "failed to mount flist %s", url)
+	}
+
+	defer func() {
+		if err := f.Umount(mnt); err != nil {
+			sublog.Error().Err(err).Str("path", mnt).Msg("failed to unmount prefetched flist")
+		}
+	}()
+
+	paths, err := prefetchPaths(mnt)
+	if err != nil {
+		return err
+	}
+
+	read, err := readFiles(mnt, paths, prefetchLimit(f.budget))
+	if err != nil {
+		return errors.Wrapf(err, "failed to prefetch flist %s", url)
+	}
+
+	sublog.Info().Uint64("size", read).Int("paths", len(paths)).Msg("flist prefetched")
+	return nil
+}
+
+// prefetchLimit returns the number of bytes a prefetch can read with the
+// cache budget, 0 means no limit
+func prefetchLimit(budget uint64) uint64 {
+	if budget == 0 {
+		return 0
+	}
+
+	limit := budget / prefetchShare
+	if limit == 0 {
+		// keep a tiny budget from disabling the limit
+		limit = 1
+	}
+
+	return limit
+}
+
+// prefetchPaths returns the paths listed in the prefetch manifest of
+// the flist mounted at root, or the root itself if it has none
+func prefetchPaths(root string) ([]string, error) {
+	file, err := os.Open(filepath.Join(root, prefetchManifest))
+	if os.IsNotExist(err) {
+		return []string{"/"}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to open prefetch manifest")
+	}
+	defer file.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		paths = append(paths, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read prefetch manifest")
+	}
+
+	return paths, nil
+}
+
+// readFiles reads all the regular files under the paths of root, so 0-fs
+// fetches their chunks. It stops once limit bytes are read, 0 means no limit.
+// Returns the number of bytes read
+func readFiles(root string, paths []string, limit uint64) (uint64, error) {
+	var read uint64
+	for _, target := range paths {
+		// the paths are always inside root, and symlinks are not followed
+		target = filepath.Join(root, filepath.Clean("/"+target))
+
+		err := filepath.Walk(target, func(path string, info os.FileInfo, err error) error {
+			if os.IsNotExist(err) {
+				log.Warn().Str("path", path).Msg("prefetched file not found")
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+
+			n, err := readFile(path, limit, read)
+			read += n
+			if err != nil {
+				return err
+			}
+
+			if limit != 0 && read >= limit {
+				return errPrefetchLimit
+			}
+
+			return nil
+		})
+
+		if err == errPrefetchLimit {
+			log.Info().Uint64("limit", limit).Msg("prefetch stopped at the cache budget")
+			return read, nil
+		} else if err != nil {
+			return read, err
+		}
+	}
+
+	return read, nil
+}
+
+// readFile reads the file at path, up to limit-read bytes if limit is set
+func readFile(path string, limit, read uint64) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if limit != 0 {
+		r = io.LimitReader(file, int64(limit-read))
+	}
+
+	n, err := io.Copy(ioutil.Discard, r)
+	return uint64(n), err
+}
@@ -0,0 +1,60 @@
+package flist
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetchFiles(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "prefetch_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	for path, size := range map[string]int{
+		"bin/app":         100,
+		"lib/libapp.so":   200,
+		"share/doc/notes": 300,
+	} {
+		path = filepath.Join(root, path)
+		require.NoError(os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(ioutil.WriteFile(path, make([]byte, size), 0644))
+	}
+	require.NoError(os.Symlink("/etc", filepath.Join(root, "etc")))
+
+	// without manifest, the whole flist is read
+	paths, err := prefetchPaths(root)
+	require.NoError(err)
+	require.Equal([]string{"/"}, paths)
+
+	read, err := readFiles(root, paths, 0)
+	require.NoError(err)
+	require.EqualValues(600, read)
+
+	// reading stops at the limit
+	read, err = readFiles(root, paths, 250)
+	require.NoError(err)
+	require.EqualValues(250, read)
+
+	// a prefetch only fills a part of the cache budget
+	require.EqualValues(250, prefetchLimit(1000))
+	require.EqualValues(1, prefetchLimit(3))
+	require.EqualValues(0, prefetchLimit(0))
+
+	manifest := "# files needed to start\nbin/app\n\n/lib\n../../etc\nmissing\n"
+	require.NoError(ioutil.WriteFile(filepath.Join(root, prefetchManifest), []byte(manifest), 0644))
+
+	paths, err = prefetchPaths(root)
+	require.NoError(err)
+	require.Equal([]string{"bin/app", "/lib", "../../etc", "missing"}, paths)
+
+	// the paths can't escape the flist, and symlinks are not followed
+	read, err = readFiles(root, paths, 0)
+	require.NoError(err)
+	require.EqualValues(300, read)
+}
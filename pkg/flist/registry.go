@@ -0,0 +1,349 @@
+package flist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	mediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+
+	mediaTypeOCILayer        = "application/vnd.oci.image.layer.v1.tar"
+	mediaTypeOCILayerGzip    = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeDockerLayer     = "application/vnd.docker.image.rootfs.diff.tar"
+	mediaTypeDockerLayerGzip = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+
+	// defaultRegistry is the registry of the images that don't name one
+	defaultRegistry = "registry-1.docker.io"
+	defaultTag      = "latest"
+
+	maxManifestSize = 4 * mib
+)
+
+var (
+	// supportedLayers are the media types of the layers that can be unpacked
+	supportedLayers = map[string]bool{
+		mediaTypeOCILayer:        true,
+		mediaTypeOCILayerGzip:    true,
+		mediaTypeDockerLayer:     true,
+		mediaTypeDockerLayerGzip: true,
+	}
+
+	digestRegex    = regexp.MustCompile(`^sha256:([a-f0-9]{64})$`)
+	challengeRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// imageRef is a parsed image reference like docker.io/library/redis:6
+type imageRef struct {
+	Registry   string
+	Repository string
+	// Reference is either a tag or a digest
+	Reference string
+}
+
+func (r imageRef) String() string {
+	sep := ":"
+	if strings.HasPrefix(r.Reference, "sha256:") {
+		sep = "@"
+	}
+
+	return fmt.Sprintf("%s/%s%s%s", r.Registry, r.Repository, sep, r.Reference)
+}
+
+// parseImageRef parses an image reference the way docker does. The registry
+// defaults to the docker hub, and the tag to latest
+func parseImageRef(image string) (imageRef, error) {
+	var ref imageRef
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Reference = name[i+1:]
+		name = name[:i]
+		if !digestRegex.MatchString(ref.Reference) {
+			return ref, fmt.Errorf("invalid image digest '%s'", ref.Reference)
+		}
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if ref.Reference == "" {
+			ref.Reference = name[i+1:]
+		}
+		name = name[:i]
+	}
+
+	if ref.Reference == "" {
+		ref.Reference = defaultTag
+	}
+
+	parts := strings.SplitN(name, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, name = parts[0], parts[1]
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." || strings.ToLower(part) != part {
+			return ref, fmt.Errorf("invalid image name '%s'", image)
+		}
+	}
+
+	if ref.Registry == "" || ref.Registry == "docker.io" {
+		ref.Registry = defaultRegistry
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	}
+
+	ref.Repository = name
+	return ref, nil
+}
+
+// digestHex returns the hex encoded hash of a sha256 digest
+func digestHex(digest string) (string, error) {
+	m := digestRegex.FindStringSubmatch(digest)
+	if m == nil {
+		return "", fmt.Errorf("unsupported digest '%s'", digest)
+	}
+
+	return m[1], nil
+}
+
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// descriptor points to a manifest or a blob of an image
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *platform `json:"platform,omitempty"`
+}
+
+// manifest is an image manifest, or an index of the manifests of the image
+// for each platform
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Layers    []descriptor `json:"layers"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+// registry is a client of the distribution API of an image registry
+type registry struct {
+	ref    imageRef
+	client *http.Client
+	// token is the bearer token used once the registry asked for one
+	token string
+}
+
+func newRegistry(ref imageRef) *registry {
+	return &registry{
+		ref:    ref,
+		client: &http.Client{Timeout: 30 * time.Minute},
+	}
+}
+
+// url returns the url of path in the repository of the image. Plain http is
+// only used with a registry running on the node itself
+func (r *registry) url(path string) string {
+	scheme := "https"
+	host, _, err := net.SplitHostPort(r.ref.Registry)
+	if err != nil {
+		host = r.ref.Registry
+	}
+
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/v2/%s/%s", scheme, r.ref.Registry, r.ref.Repository, path)
+}
+
+func (r *registry) do(path string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, r.url(path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accept) > 0 {
+		req.Header.Set("Accept", strings.Join(accept, ", "))
+	}
+
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	return r.client.Do(req)
+}
+
+// get requests path from the registry, authenticating anonymously
+// if the registry requires it
+func (r *registry) get(path string, accept ...string) (*http.Response, error) {
+	resp, err := r.do(path, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		resp.Body.Close()
+		if err := r.authenticate(resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, errors.Wrapf(err, "failed to authenticate to registry %s", r.ref.Registry)
+		}
+
+		if resp, err = r.do(path, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to get %s of image %s: %s", path, r.ref, resp.Status)
+	}
+
+	return resp, nil
+}
+
+// authenticate gets an anonymous pull token from the realm of the bearer challenge
+func (r *registry) authenticate(challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("unsupported authentication challenge '%s'", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, m := range challengeRegex.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return fmt.Errorf("invalid authentication realm '%s'", params["realm"])
+	}
+
+	query := realm.Query()
+	if service, ok := params["service"]; ok {
+		query.Set("service", service)
+	}
+
+	scope, ok := params["scope"]
+	if !ok {
+		scope = fmt.Sprintf("repository:%s:pull", r.ref.Repository)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	resp, err := r.client.Get(realm.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get token: %s", resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return errors.Wrap(err, "failed to decode token")
+	}
+
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+
+	if r.token == "" {
+		return fmt.Errorf("registry returned an empty token")
+	}
+
+	return nil
+}
+
+// manifest gets the manifest or index of the image with reference
+func (r *registry) manifest(reference string) (manifest, error) {
+	var m manifest
+	resp, err := r.get("manifests/"+reference,
+		mediaTypeOCIManifest, mediaTypeOCIIndex,
+		mediaTypeDockerManifest, mediaTypeDockerManifestList,
+	)
+	if err != nil {
+		return m, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return m, errors.Wrap(err, "failed to read image manifest")
+	}
+
+	if expected, err := digestHex(reference); err == nil {
+		sum := sha256.Sum256(data)
+		if hex.EncodeToString(sum[:]) != expected {
+			return m, fmt.Errorf("manifest of image %s doesn't match its digest", r.ref)
+		}
+	}
+
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, errors.Wrapf(err, "failed to decode manifest of image %s", r.ref)
+	}
+
+	if m.MediaType == "" {
+		m.MediaType = resp.Header.Get("Content-Type")
+	}
+
+	return m, nil
+}
+
+// resolve gets the manifest of the image for the platform of the node
+func (r *registry) resolve() (manifest, error) {
+	m, err := r.manifest(r.ref.Reference)
+	if err != nil {
+		return m, err
+	}
+
+	if m.MediaType != mediaTypeOCIIndex && m.MediaType != mediaTypeDockerManifestList {
+		return m, nil
+	}
+
+	for _, desc := range m.Manifests {
+		if desc.Platform == nil || desc.Platform.OS != "linux" || desc.Platform.Architecture != runtime.GOARCH {
+			continue
+		}
+
+		if _, err := digestHex(desc.Digest); err != nil {
+			return m, err
+		}
+
+		m, err = r.manifest(desc.Digest)
+		if err != nil {
+			return m, err
+		}
+
+		if len(m.Manifests) != 0 {
+			return m, fmt.Errorf("nested image index of image %s is not supported", r.ref)
+		}
+
+		return m, nil
+	}
+
+	return m, fmt.Errorf("image %s has no linux/%s manifest", r.ref, runtime.GOARCH)
+}
@@ -0,0 +1,350 @@
+package flist
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// snapshotPrefix is the prefix of the names of the snapshots of the mount with name
+func snapshotPrefix(name string) string {
+	return fmt.Sprintf("%s-snapshot-", name)
+}
+
+// snapshotName returns the name of a new snapshot of the mount with name
+func snapshotName(name string, t time.Time) string {
+	return fmt.Sprintf("%s%d", snapshotPrefix(name), t.Unix())
+}
+
+// Snapshot implements the Flister.Snapshot interface
+func (f *flistModule) Snapshot(name string) (string, error) {
+	sublog := log.With().Str("name", name).Logger()
+
+	if _, err := f.storage.Path(name); err != nil {
+		return "", errors.Wrapf(err, "mount %s has no read-write layer", name)
+	}
+
+	// flush whatever 0-fs wrote to the read-write layer
+	// so the snapshot is consistent
+	syscall.Sync()
+
+	snapshot := snapshotName(name, time.Now())
+	sublog.Info().Str("snapshot", snapshot).Msg("snapshot read-write layer")
+
+	if _, err := f.storage.SnapshotFilesystem(name, snapshot); err != nil {
+		return "", errors.Wrapf(err, "failed to snapshot read-write layer of mount %s", name)
+	}
+
+	return snapshot, nil
+}
+
+// DeleteSnapshot implements the Flister.DeleteSnapshot interface
+func (f *flistModule) DeleteSnapshot(snapshot string) error {
+	if _, err := f.storage.Path(snapshot); err != nil {
+		return err
+	}
+
+	return f.storage.ReleaseFilesystem(snapshot)
+}
+
+// DeleteSnapshots implements the Flister.DeleteSnapshots interface
+func (f *flistModule) DeleteSnapshots(name string) error {
+	backend, err := f.storage.Path(name)
+	if err != nil {
+		// only the read-write layer of a mount can be snapshotted
+		// and its snapshots are in the same pool
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(filepath.Dir(backend))
+	if err != nil {
+		return errors.Wrapf(err, "failed to list snapshots of mount %s", name)
+	}
+
+	for _, info := range infos {
+		if !isSnapshotOf(name, info.Name()) {
+			continue
+		}
+
+		log.Info().Str("name", name).Str("snapshot", info.Name()).Msg("delete snapshot")
+		if err := f.storage.ReleaseFilesystem(info.Name()); err != nil {
+			return errors.Wrapf(err, "failed to delete snapshot %s", info.Name())
+		}
+	}
+
+	return nil
+}
+
+// isSnapshotOf checks if snapshot is a name returned by snapshotName for
+// the mount with name
+func isSnapshotOf(name, snapshot string) bool {
+	prefix := snapshotPrefix(name)
+	if !strings.HasPrefix(snapshot, prefix) {
+		return false
+	}
+
+	_, err := strconv.ParseInt(strings.TrimPrefix(snapshot, prefix), 10, 64)
+	return err == nil
+}
+
+// Export implements the Flister.Export interface
+func (f *flistModule) Export(snapshot, path string) error {
+	root, err := f.storage.Path(snapshot)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to create archive")
+	}
+
+	if err := exportArchive(root, file); err != nil {
+		file.Close()
+		os.Remove(path)
+		return errors.Wrapf(err, "failed to export snapshot %s", snapshot)
+	}
+
+	return file.Close()
+}
+
+// Import implements the Flister.Import interface
+func (f *flistModule) Import(name, path string, opts pkg.MountOptions) error {
+	if _, err := f.storage.Path(name); err == nil {
+		return fmt.Errorf("read-write layer %s already exists", name)
+	}
+
+	if opts.Limit == 0 || len(opts.Type) == 0 {
+		return fmt.Errorf("invalid mount option, missing disk type and/or size")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open archive")
+	}
+	defer file.Close()
+
+	root, err := f.storage.CreateFilesystem(name, opts.Limit*mib, opts.Type)
+	if err != nil {
+		return errors.Wrap(err, "failed to create read-write subvolume for 0-fs")
+	}
+
+	if err := importArchive(file, root); err != nil {
+		if err := f.storage.ReleaseFilesystem(name); err != nil {
+			log.Error().Err(err).Str("name", name).Msg("failed to clean up read-write subvolume")
+		}
+		return errors.Wrapf(err, "failed to import archive in %s", name)
+	}
+
+	return nil
+}
+
+// exportArchive writes the content of the directory root as a tar
+// stream to w. Ownership, permissions and links are preserved
+func exportArchive(root string, w io.Writer) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." || info.Mode()&os.ModeSocket != 0 {
+			return nil
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create header for %s", rel)
+		}
+
+		hdr.Name = filepath.ToSlash(rel)
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// importArchive extracts the tar stream read from r into the directory root
+func importArchive(r io.Reader, root string) error {
+	root = filepath.Clean(root)
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		if !inside(root, hdr.Name) {
+			return fmt.Errorf("invalid archive entry '%s'", hdr.Name)
+		}
+
+		// the symlinks extracted from the archive are resolved inside
+		// root, so an entry can't be written through them
+		target, err := layerPath(root, hdr.Name)
+		if err != nil {
+			return errors.Wrapf(err, "invalid archive entry '%s'", hdr.Name)
+		}
+
+		var source string
+		if hdr.Typeflag == tar.TypeLink {
+			if !inside(root, hdr.Linkname) {
+				return fmt.Errorf("invalid archive link '%s'", hdr.Linkname)
+			}
+
+			if source, err = layerPath(root, hdr.Linkname); err != nil {
+				return errors.Wrapf(err, "invalid archive link '%s'", hdr.Linkname)
+			}
+		}
+
+		// an entry replaces what is already at its path, except directories
+		// which are merged, so it's never written through a symlink
+		if info, err := os.Lstat(target); err == nil {
+			if !info.IsDir() || hdr.Typeflag != tar.TypeDir {
+				if err := os.RemoveAll(target); err != nil {
+					return err
+				}
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+
+		if err := extractEntry(tr, hdr, target, source); err != nil {
+			return err
+		}
+	}
+}
+
+// inside checks that the archive path name is under root
+func inside(root, name string) bool {
+	return strings.HasPrefix(filepath.Join(root, name), root+string(filepath.Separator))
+}
+
+// extractEntry creates the archive entry hdr, with its content read from r,
+// at target. source is the path of the file target links to for hard links
+func extractEntry(r io.Reader, hdr *tar.Header, target, source string) error {
+	var err error
+	mode := hdr.FileInfo().Mode()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		err = os.MkdirAll(target, mode.Perm())
+	case tar.TypeReg, tar.TypeRegA:
+		err = extractFile(r, target, mode)
+	case tar.TypeSymlink:
+		err = os.Symlink(hdr.Linkname, target)
+	case tar.TypeLink:
+		err = os.Link(source, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		err = extractDevice(hdr, target)
+	default:
+		log.Warn().Str("name", hdr.Name).Msgf("skip unsupported archive entry type '%c'", hdr.Typeflag)
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to extract '%s'", hdr.Name)
+	}
+
+	if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+		return errors.Wrapf(err, "failed to set owner of '%s'", hdr.Name)
+	}
+
+	if hdr.Typeflag == tar.TypeSymlink || hdr.Typeflag == tar.TypeLink {
+		return nil
+	}
+
+	// chown clears the setuid and setgid bits, so the mode is set again after it
+	if err := os.Chmod(target, mode); err != nil {
+		return errors.Wrapf(err, "failed to set mode of '%s'", hdr.Name)
+	}
+
+	if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+		return errors.Wrapf(err, "failed to set times of '%s'", hdr.Name)
+	}
+
+	return nil
+}
+
+func extractFile(r io.Reader, target string, mode os.FileMode) error {
+	// the target is never followed if it's a symlink
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY|unix.O_NOFOLLOW, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func extractDevice(hdr *tar.Header, target string) error {
+	mode := uint32(hdr.Mode & 07777)
+	switch hdr.Typeflag {
+	case tar.TypeChar:
+		mode |= unix.S_IFCHR
+	case tar.TypeBlock:
+		mode |= unix.S_IFBLK
+	case tar.TypeFifo:
+		mode |= unix.S_IFIFO
+	}
+
+	return unix.Mknod(target, mode, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor))))
+}
@@ -0,0 +1,293 @@
+package flist
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestArchive(t *testing.T) {
+	require := require.New(t)
+
+	source, err := ioutil.TempDir("", "archive_source")
+	require.NoError(err)
+	defer os.RemoveAll(source)
+
+	require.NoError(os.MkdirAll(filepath.Join(source, "etc", "app"), 0700))
+	require.NoError(ioutil.WriteFile(filepath.Join(source, "etc", "app", "config"), []byte("key = value"), 0640))
+	require.NoError(ioutil.WriteFile(filepath.Join(source, "run.sh"), []byte("#!/bin/sh"), 0755))
+	require.NoError(os.Symlink("etc/app/config", filepath.Join(source, "config")))
+
+	var buf bytes.Buffer
+	require.NoError(exportArchive(source, &buf))
+
+	target, err := ioutil.TempDir("", "archive_target")
+	require.NoError(err)
+	defer os.RemoveAll(target)
+
+	require.NoError(importArchive(&buf, target))
+
+	data, err := ioutil.ReadFile(filepath.Join(target, "etc", "app", "config"))
+	require.NoError(err)
+	require.Equal("key = value", string(data))
+
+	for path, mode := range map[string]os.FileMode{
+		"etc/app":        os.ModeDir | 0700,
+		"etc/app/config": 0640,
+		"run.sh":         0755,
+	} {
+		info, err := os.Stat(filepath.Join(target, path))
+		require.NoError(err)
+		require.Equal(mode, info.Mode(), path)
+	}
+
+	link, err := os.Readlink(filepath.Join(target, "config"))
+	require.NoError(err)
+	require.Equal("etc/app/config", link)
+}
+
+func TestImportArchiveInvalid(t *testing.T) {
+	require := require.New(t)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "../escape",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+	}))
+	require.NoError(tw.Close())
+
+	target, err := ioutil.TempDir("", "archive_target")
+	require.NoError(err)
+	defer os.RemoveAll(target)
+
+	err = importArchive(&buf, target)
+	require.Error(err)
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(target), "escape"))
+	require.True(os.IsNotExist(err))
+}
+
+func TestImportArchiveSymlink(t *testing.T) {
+	require := require.New(t)
+
+	outside, err := ioutil.TempDir("", "archive_outside")
+	require.NoError(err)
+	defer os.RemoveAll(outside)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "evil",
+		Typeflag: tar.TypeSymlink,
+		Linkname: outside,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "evil/passwd",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     4,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	_, err = tw.Write([]byte("root"))
+	require.NoError(err)
+	require.NoError(tw.Close())
+
+	target, err := ioutil.TempDir("", "archive_target")
+	require.NoError(err)
+	defer os.RemoveAll(target)
+
+	require.NoError(importArchive(&buf, target))
+
+	// the entry is written where the symlink points inside the volume
+	_, err = os.Stat(filepath.Join(outside, "passwd"))
+	require.True(os.IsNotExist(err))
+
+	data, err := ioutil.ReadFile(filepath.Join(target, outside, "passwd"))
+	require.NoError(err)
+	require.Equal("root", string(data))
+}
+
+func TestImportArchiveReplaceSymlink(t *testing.T) {
+	require := require.New(t)
+
+	outside, err := ioutil.TempDir("", "archive_outside")
+	require.NoError(err)
+	defer os.RemoveAll(outside)
+
+	host := filepath.Join(outside, "shadow")
+	require.NoError(ioutil.WriteFile(host, []byte("host"), 0644))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "x",
+		Typeflag: tar.TypeSymlink,
+		Linkname: host,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "x",
+		Typeflag: tar.TypeReg,
+		Mode:     0644,
+		Size:     4,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	_, err = tw.Write([]byte("evil"))
+	require.NoError(err)
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "d",
+		Typeflag: tar.TypeSymlink,
+		Linkname: outside,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	require.NoError(tw.WriteHeader(&tar.Header{
+		Name:     "d",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+		Uid:      os.Getuid(),
+		Gid:      os.Getgid(),
+	}))
+	require.NoError(tw.Close())
+
+	target, err := ioutil.TempDir("", "archive_target")
+	require.NoError(err)
+	defer os.RemoveAll(target)
+
+	require.NoError(importArchive(&buf, target))
+
+	// the file replaced the symlink instead of writing through it
+	data, err := ioutil.ReadFile(host)
+	require.NoError(err)
+	require.Equal("host", string(data))
+
+	info, err := os.Lstat(filepath.Join(target, "x"))
+	require.NoError(err)
+	require.True(info.Mode().IsRegular())
+
+	data, err = ioutil.ReadFile(filepath.Join(target, "x"))
+	require.NoError(err)
+	require.Equal("evil", string(data))
+
+	// and so did the directory, the mode of the link target is untouched
+	info, err = os.Stat(outside)
+	require.NoError(err)
+	require.Equal(os.FileMode(0700), info.Mode().Perm())
+
+	info, err = os.Lstat(filepath.Join(target, "d"))
+	require.NoError(err)
+	require.True(info.IsDir())
+}
+
+func TestSnapshot(t *testing.T) {
+	require := require.New(t)
+
+	strg := &StorageMock{}
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	flister := newFlister(root, strg, &testCommander{T: t}, 0)
+
+	strg.On("Path", "mount").Return("/my/backend", nil)
+	strg.On("SnapshotFilesystem", "mount", mock.Anything).Return("/my/snapshot", nil)
+
+	snapshot, err := flister.Snapshot("mount")
+	require.NoError(err)
+	require.Contains(snapshot, "mount-snapshot-")
+	strg.AssertCalled(t, "SnapshotFilesystem", "mount", snapshot)
+
+	// mounts without read-write layer cannot be snapshotted
+	strg.On("Path", "readonly").Return("", os.ErrNotExist)
+	_, err = flister.Snapshot("readonly")
+	require.Error(err)
+}
+
+func TestDeleteSnapshots(t *testing.T) {
+	require := require.New(t)
+
+	strg := &StorageMock{}
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	flister := newFlister(root, strg, &testCommander{T: t}, 0)
+
+	pool := filepath.Join(root, "pool")
+	for _, name := range []string{
+		"mount",
+		"mount-snapshot-1594900000",
+		"mount-snapshot-1594900100",
+		"mount-update",
+		"mount-update-snapshot-1594900000",
+		"mount-snapshot-data",
+	} {
+		require.NoError(os.MkdirAll(filepath.Join(pool, name), 0755))
+	}
+
+	strg.On("Path", "mount").Return(filepath.Join(pool, "mount"), nil)
+	strg.On("ReleaseFilesystem", mock.Anything).Return(nil)
+
+	require.NoError(flister.DeleteSnapshots("mount"))
+	strg.AssertNumberOfCalls(t, "ReleaseFilesystem", 2)
+	strg.AssertCalled(t, "ReleaseFilesystem", "mount-snapshot-1594900000")
+	strg.AssertCalled(t, "ReleaseFilesystem", "mount-snapshot-1594900100")
+
+	// mounts without read-write layer have no snapshots
+	strg.On("Path", "readonly").Return("", os.ErrNotExist)
+	require.NoError(flister.DeleteSnapshots("readonly"))
+	strg.AssertNumberOfCalls(t, "ReleaseFilesystem", 2)
+}
+
+func TestImport(t *testing.T) {
+	require := require.New(t)
+
+	strg := &StorageMock{}
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	flister := newFlister(root, strg, &testCommander{T: t}, 0)
+
+	source := filepath.Join(root, "source")
+	require.NoError(os.MkdirAll(source, 0755))
+	require.NoError(ioutil.WriteFile(filepath.Join(source, "data"), []byte("data"), 0644))
+
+	backend := filepath.Join(root, "backend")
+	require.NoError(os.MkdirAll(backend, 0755))
+
+	strg.On("Path", "snapshot").Return(source, nil)
+	strg.On("Path", "existing").Return(backend, nil)
+	strg.On("Path", "new").Return("", os.ErrNotExist)
+	strg.On("CreateFilesystem", "new", uint64(256*mib), pkg.SSDDevice).Return(backend, nil)
+
+	archive := filepath.Join(root, "archive.tar")
+	require.NoError(flister.Export("snapshot", archive))
+
+	err = flister.Import("existing", archive, pkg.DefaultMountOptions)
+	require.Error(err)
+
+	err = flister.Import("new", archive, pkg.DefaultMountOptions)
+	require.NoError(err)
+
+	data, err := ioutil.ReadFile(filepath.Join(backend, "data"))
+	require.NoError(err)
+	require.Equal("data", string(data))
+}
@@ -0,0 +1,337 @@
+package flist
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// superviseInterval is the interval of the health check of the mounts
+	superviseInterval = 10 * time.Second
+	// maxRestartBackoff is the longest wait between two attempts
+	// to start again a mount that failed to start
+	maxRestartBackoff = 10 * time.Minute
+
+	bootIDPath = "/proc/sys/kernel/random/boot_id"
+)
+
+// mountsState is the content of the state file of the supervisor
+type mountsState struct {
+	// Boot is the boot id of the node when the state was written,
+	// the mounts of a previous boot are all gone
+	Boot   string  `json:"boot"`
+	Mounts []mount `json:"mounts"`
+}
+
+// supervisor tracks the mounts of the module in a state file, so they are
+// known after a restart of the module, and starts again the mounts whose
+// daemon stopped
+type supervisor struct {
+	path    string
+	boot    string
+	backend backend
+	now     func() time.Time
+
+	// m guards mounts and ops, it is never held while the backend serves
+	// a mount. The operations on a mount are serialized by its lock in ops
+	mounts map[string]mount
+	ops    map[string]*opLock
+	m      sync.Mutex
+}
+
+// opLock serializes the operations on a mount
+type opLock struct {
+	sync.Mutex
+	refs int
+}
+
+func newSupervisor(path string, backend backend) (*supervisor, error) {
+	boot, err := ioutil.ReadFile(bootIDPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read boot id")
+	}
+
+	s := &supervisor{
+		path:    path,
+		boot:    strings.TrimSpace(string(boot)),
+		backend: backend,
+		now:     time.Now,
+		mounts:  make(map[string]mount),
+		ops:     make(map[string]*opLock),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *supervisor) load() error {
+	data, err := ioutil.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to read mounts state")
+	}
+
+	var state mountsState
+	if err := json.Unmarshal(data, &state); err != nil {
+		// the mounts are still there, they are just not supervised anymore
+		log.Error().Err(err).Str("path", s.path).Msg("invalid mounts state, ignoring it")
+		return nil
+	}
+
+	if state.Boot != s.boot {
+		log.Info().Msg("node rebooted since the mounts state was written, ignoring it")
+		return nil
+	}
+
+	for _, m := range state.Mounts {
+		s.mounts[m.Name] = m
+	}
+
+	return nil
+}
+
+// save writes the state file, s.m must be held
+func (s *supervisor) save() error {
+	state := mountsState{
+		Boot:   s.boot,
+		Mounts: s.list(),
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	// the state is replaced at once, so a crash never leaves half of it
+	tmp := s.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write mounts state")
+	}
+
+	return os.Rename(tmp, s.path)
+}
+
+// persist saves the state file, s.m must be held. The mounts are still
+// supervised if the state can't be written, until the module restarts
+func (s *supervisor) persist() {
+	if err := s.save(); err != nil {
+		log.Error().Err(err).Msg("failed to save mounts state")
+	}
+}
+
+// lock locks the operations on the mount name, the returned
+// function unlocks them
+func (s *supervisor) lock(name string) func() {
+	s.m.Lock()
+	l, ok := s.ops[name]
+	if !ok {
+		l = &opLock{}
+		s.ops[name] = l
+	}
+	l.refs++
+	s.m.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+
+		s.m.Lock()
+		defer s.m.Unlock()
+		l.refs--
+		if l.refs == 0 {
+			delete(s.ops, name)
+		}
+	}
+}
+
+// set tracks m in place of the mount of the same name
+func (s *supervisor) set(m mount) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.mounts[m.Name] = m
+	s.persist()
+}
+
+// Start serves m with the backend, and tracks it
+func (s *supervisor) Start(m mount) error {
+	defer s.lock(m.Name)()
+
+	if old, ok := s.Get(m.Name); ok {
+		// the daemon of a mount that is not served anymore
+		// is replaced instead of waiting for the next check
+		if err := s.stop(old); err != nil {
+			log.Error().Err(err).Str("name", m.Name).Msg("failed to stop previous mount")
+		}
+		s.remove(m.Name)
+	}
+
+	if err := s.backend.Start(&m); err != nil {
+		return err
+	}
+
+	s.set(m)
+	return nil
+}
+
+// Track tracks m, which is already mounted
+func (s *supervisor) Track(m mount) {
+	defer s.lock(m.Name)()
+
+	s.set(m)
+}
+
+// Stop stops the mount name and stops tracking it. The mount is still
+// tracked if it fails to stop. Returns the mount, and false if it was not tracked
+func (s *supervisor) Stop(name string) (mount, bool, error) {
+	defer s.lock(name)()
+
+	m, ok := s.Get(name)
+	if !ok {
+		return m, false, nil
+	}
+
+	if err := s.stop(m); err != nil {
+		return m, true, err
+	}
+
+	s.remove(name)
+	return m, true, nil
+}
+
+// remove stops tracking the mount name
+func (s *supervisor) remove(name string) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.mounts, name)
+	s.persist()
+}
+
+// stop stops serving m, the mounts of images are not served by the backend
+func (s *supervisor) stop(m mount) error {
+	if m.Image {
+		return nil
+	}
+
+	return s.backend.Stop(m)
+}
+
+// Get returns the mount name, and false if it is not tracked
+func (s *supervisor) Get(name string) (mount, bool) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	m, ok := s.mounts[name]
+	return m, ok
+}
+
+// List returns the tracked mounts
+func (s *supervisor) List() []mount {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.list()
+}
+
+func (s *supervisor) list() []mount {
+	mounts := make([]mount, 0, len(s.mounts))
+	for _, m := range s.mounts {
+		mounts = append(mounts, m)
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		return mounts[i].Name < mounts[j].Name
+	})
+
+	return mounts
+}
+
+// check starts again the mounts that are not served anymore
+func (s *supervisor) check() {
+	for _, m := range s.List() {
+		if m.Image {
+			continue
+		}
+
+		s.checkMount(m.Name)
+	}
+}
+
+// checkMount starts again the mount name if it is not served anymore. A mount
+// that fails to start is retried with an exponential backoff
+func (s *supervisor) checkMount(name string) {
+	defer s.lock(name)()
+
+	// the mount may have changed since the check started
+	m, ok := s.Get(name)
+	if !ok {
+		return
+	}
+
+	if m.Error != "" {
+		if s.now().Before(m.Retry) {
+			return
+		}
+	} else {
+		err := s.backend.Check(m)
+		if err == nil {
+			return
+		}
+
+		log.Warn().Err(err).Str("name", name).Msg("mount is not served anymore, mounting it again")
+		if err := s.backend.Stop(m); err != nil {
+			log.Error().Err(err).Str("name", name).Msg("failed to stop mount")
+		}
+	}
+
+	m.PID = 0
+	if err := s.backend.Start(&m); err != nil {
+		m.Failures++
+		m.Retry = s.now().Add(restartBackoff(m.Failures))
+		log.Error().Err(err).Str("name", name).Time("retry", m.Retry).Msg("failed to mount flist again")
+		m.Error = err.Error()
+	} else {
+		log.Info().Str("name", name).Int("pid", m.PID).Msg("flist mounted again")
+		m.Error = ""
+		m.Failures = 0
+		m.Retry = time.Time{}
+		m.Restarts++
+	}
+
+	s.set(m)
+}
+
+// restartBackoff returns how long to wait before starting again
+// a mount that failed to start failures times in a row
+func restartBackoff(failures int) time.Duration {
+	backoff := superviseInterval
+	for i := 1; i < failures && backoff < maxRestartBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxRestartBackoff {
+		backoff = maxRestartBackoff
+	}
+
+	return backoff
+}
+
+// watch checks the mounts every interval
+func (s *supervisor) watch(interval time.Duration) {
+	for range time.Tick(interval) {
+		s.check()
+	}
+}
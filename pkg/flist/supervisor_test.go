@@ -0,0 +1,215 @@
+package flist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// testBackend serves the mounts in memory
+type testBackend struct {
+	pid      int
+	running  map[string]int
+	fail     bool
+	failStop bool
+}
+
+func (b *testBackend) Start(m *mount) error {
+	if b.fail {
+		return fmt.Errorf("failed to start")
+	}
+
+	b.pid++
+	m.PID = b.pid
+	b.running[m.Name] = m.PID
+	return nil
+}
+
+func (b *testBackend) Stop(m mount) error {
+	if b.failStop {
+		return fmt.Errorf("failed to stop")
+	}
+	delete(b.running, m.Name)
+	return nil
+}
+
+func (b *testBackend) Check(m mount) error {
+	if b.running[m.Name] != m.PID {
+		return fmt.Errorf("not running")
+	}
+	return nil
+}
+
+func TestSupervisor(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "mounts.json")
+	backend := &testBackend{running: make(map[string]int)}
+	s, err := newSupervisor(path, backend)
+	require.NoError(err)
+
+	require.NoError(s.Start(mount{Name: "a"}))
+	require.NoError(s.Start(mount{Name: "b"}))
+	s.Track(mount{Name: "image", Image: true})
+
+	// crashed mounts are started again
+	delete(backend.running, "a")
+	s.check()
+
+	a, ok := s.Get("a")
+	require.True(ok)
+	require.Equal(1, a.Restarts)
+	require.Equal(backend.running["a"], a.PID)
+
+	// failed mounts are retried with a backoff
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	delete(backend.running, "b")
+	backend.fail = true
+	s.check()
+
+	b, _ := s.Get("b")
+	require.Equal(0, b.Restarts)
+	require.Equal(1, b.Failures)
+	require.NotEmpty(b.Error)
+
+	now = now.Add(superviseInterval)
+	s.check()
+
+	b, _ = s.Get("b")
+	require.Equal(2, b.Failures)
+	require.Equal(now.Add(2*superviseInterval), b.Retry)
+
+	backend.fail = false
+	now = now.Add(superviseInterval)
+	s.check()
+
+	b, _ = s.Get("b")
+	require.Equal(0, b.Restarts, "the mount is not started again before the backoff")
+
+	now = now.Add(superviseInterval)
+	s.check()
+
+	b, _ = s.Get("b")
+	require.Equal(1, b.Restarts)
+	require.Equal(0, b.Failures)
+	require.Empty(b.Error)
+
+	require.Equal(superviseInterval, restartBackoff(1))
+	require.Equal(4*superviseInterval, restartBackoff(3))
+	require.Equal(maxRestartBackoff, restartBackoff(100))
+
+	// the mounts are known after a restart
+	reloaded, err := newSupervisor(path, backend)
+	require.NoError(err)
+	require.Equal(s.List(), reloaded.List())
+
+	// a mount that fails to stop is still tracked
+	backend.failStop = true
+	_, ok, err = s.Stop("a")
+	require.Error(err)
+	require.True(ok)
+
+	_, ok = s.Get("a")
+	require.True(ok)
+
+	backend.failStop = false
+	m, ok, err := s.Stop("a")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal("a", m.Name)
+	require.NotContains(backend.running, "a")
+
+	_, ok, err = s.Stop("a")
+	require.NoError(err)
+	require.False(ok)
+
+	_, ok, err = s.Stop("image")
+	require.NoError(err)
+	require.True(ok)
+
+	// the mounts of a previous boot are all gone
+	data, err := ioutil.ReadFile(path)
+	require.NoError(err)
+
+	var state mountsState
+	require.NoError(json.Unmarshal(data, &state))
+	require.Len(state.Mounts, 1)
+
+	state.Boot = "previous"
+	data, err = json.Marshal(state)
+	require.NoError(err)
+	require.NoError(ioutil.WriteFile(path, data, 0644))
+
+	reloaded, err = newSupervisor(path, backend)
+	require.NoError(err)
+	require.Empty(reloaded.List())
+}
+
+func TestMigrate(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	f := newFlister(root, &StorageMock{}, &testCommander{T: t}, 0).(*flistModule)
+
+	// a 0-fs daemon started by a previous version of the module
+	flist := filepath.Join(f.flist, "0123456789abcdef0123456789abcdef")
+	target := filepath.Join(f.mountpoint, "legacy")
+	daemon := exec.Command("sh", "-c", "sleep 10", "g8ufs",
+		"-ro", "-cache", f.cache, "-meta", flist, "-storage-url", "zdb://hub.grid.tf:9900",
+		"-daemon", "-pid", filepath.Join(f.pid, "legacy.pid"), target,
+	)
+	require.NoError(daemon.Start())
+	defer daemon.Process.Kill()
+
+	// and one that is not running anymore
+	stopped := exec.Command("true")
+	require.NoError(stopped.Run())
+
+	for name, pid := range map[string]int{"legacy": daemon.Process.Pid, "stopped": stopped.Process.Pid} {
+		path := filepath.Join(f.pid, name+".pid")
+		require.NoError(ioutil.WriteFile(path, []byte(strconv.Itoa(pid)), 0644))
+	}
+
+	f.migrate()
+
+	mounts, err := f.List()
+	require.NoError(err)
+	require.Equal([]pkg.FlistMount{{
+		Name:     "legacy",
+		Path:     target,
+		Hash:     filepath.Base(flist),
+		ReadOnly: true,
+		State:    pkg.FlistMountHealthy,
+	}}, mounts)
+
+	m, ok := f.mounts.Get("legacy")
+	require.True(ok)
+	require.Equal(daemon.Process.Pid, m.PID)
+	require.Equal("zdb://hub.grid.tf:9900", m.Storage)
+
+	hash, err := f.HashFromRootPath(target)
+	require.NoError(err)
+	require.Equal(filepath.Base(flist), hash)
+
+	infos, err := ioutil.ReadDir(f.pid)
+	require.NoError(err)
+	require.Empty(infos)
+}
@@ -0,0 +1,110 @@
+package flist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/crypto"
+)
+
+// signatureExt is the extension of the signature published next to a flist
+const signatureExt = ".sig"
+
+// ErrInvalidFlist is returned when a flist doesn't match
+// the checksum or signature it is mounted with
+var ErrInvalidFlist = errors.New("flist verification failed")
+
+// verifyFlist checks the flist at path, downloaded from url, against the
+// checksum and publisher key of opts
+func (f *flistModule) verifyFlist(url, path string, opts pkg.MountOptions) error {
+	if opts.Checksum == "" && opts.PublicKey == "" {
+		if opts.Signature != "" {
+			return fmt.Errorf("flist signature requires the public key of the publisher")
+		}
+		return nil
+	}
+
+	sum, err := fileSHA256(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to hash flist")
+	}
+
+	if opts.Checksum != "" && !strings.EqualFold(hex.EncodeToString(sum), opts.Checksum) {
+		return errors.Wrapf(ErrInvalidFlist, "flist %s has checksum %x, expected %s", url, sum, opts.Checksum)
+	}
+
+	if opts.PublicKey == "" {
+		return nil
+	}
+
+	key, err := crypto.KeyFromHex(opts.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid flist publisher key")
+	}
+
+	signature := opts.Signature
+	if signature == "" {
+		if signature, err = downloadSignature(url); err != nil {
+			return errors.Wrapf(err, "failed to download signature of flist %s", url)
+		}
+	}
+
+	sig, err := hex.DecodeString(strings.TrimSpace(signature))
+	if err != nil {
+		return errors.Wrap(err, "invalid flist signature")
+	}
+
+	if err := crypto.Verify(key, sum, sig); err != nil {
+		return errors.Wrapf(ErrInvalidFlist, "flist %s is not signed by %s: %s", url, opts.PublicKey, err)
+	}
+
+	log.Info().Str("url", url).Str("publisher", opts.PublicKey).Msg("flist signature verified")
+	return nil
+}
+
+// downloadSignature downloads the signature published next to the flist at url
+func downloadSignature(url string) (string, error) {
+	resp, err := http.Get(url + signatureExt)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fail to fetch signature, response: %v", resp.StatusCode)
+	}
+
+	// a hex encoded signature is 128 bytes
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// fileSHA256 returns the sha256 of the file at path
+func fileSHA256(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,93 @@
+package flist
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestVerifyFlist(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "flist_root")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	f := newFlister(root, &StorageMock{}, &testCommander{T: t}, 0).(*flistModule)
+
+	content := []byte("flist metadata")
+	path := filepath.Join(f.flist, "flist")
+	require.NoError(ioutil.WriteFile(path, content, 0644))
+
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+
+	publisher, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+	_, other, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(err)
+
+	signature := hex.EncodeToString(ed25519.Sign(key, sum[:]))
+	forged := hex.EncodeToString(ed25519.Sign(other, sum[:]))
+
+	// the hub publishes the signature next to the flist
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/signed.flist.sig":
+			fmt.Fprintln(w, signature)
+		case "/forged.flist.sig":
+			fmt.Fprintln(w, forged)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer hub.Close()
+
+	pub := hex.EncodeToString(publisher)
+
+	valid := []struct {
+		url  string
+		opts pkg.MountOptions
+	}{
+		{hub.URL + "/any.flist", pkg.DefaultMountOptions},
+		{hub.URL + "/any.flist", pkg.MountOptions{Checksum: checksum}},
+		{hub.URL + "/signed.flist", pkg.MountOptions{PublicKey: pub}},
+		{hub.URL + "/any.flist", pkg.MountOptions{PublicKey: pub, Signature: signature}},
+		{hub.URL + "/signed.flist", pkg.MountOptions{Checksum: checksum, PublicKey: pub}},
+	}
+
+	for _, c := range valid {
+		require.NoError(f.verifyFlist(c.url, path, c.opts), c.url)
+	}
+
+	invalid := []struct {
+		url  string
+		opts pkg.MountOptions
+	}{
+		{hub.URL + "/any.flist", pkg.MountOptions{Checksum: checksum[1:] + "0"}},
+		{hub.URL + "/forged.flist", pkg.MountOptions{PublicKey: pub}},
+		{hub.URL + "/any.flist", pkg.MountOptions{PublicKey: pub, Signature: forged}},
+		{hub.URL + "/signed.flist", pkg.MountOptions{Checksum: checksum[1:] + "0", PublicKey: pub}},
+	}
+
+	for _, c := range invalid {
+		err := f.verifyFlist(c.url, path, c.opts)
+		require.True(errors.Is(err, ErrInvalidFlist), c.url)
+	}
+
+	// missing signature, or signature without key
+	require.Error(f.verifyFlist(hub.URL+"/any.flist", path, pkg.MountOptions{PublicKey: pub}))
+	require.Error(f.verifyFlist(hub.URL+"/any.flist", path, pkg.MountOptions{Signature: signature}))
+}
@@ -108,7 +108,21 @@ type ProvisionCounters struct {
 	Debug     int64 `json:"debug"`
 }
 
+// ProvisionRetry is an operation of the provision engine
+// that failed and is waiting to be retried
+type ProvisionRetry struct {
+	// Kind of operation (provision, feedback, deleted)
+	Kind        string    `json:"kind"`
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
 // ProvisionMonitor interface
 type ProvisionMonitor interface {
 	Counters(ctx context.Context) <-chan ProvisionCounters
+	// Pending returns the operations waiting to be retried
+	Pending() ([]ProvisionRetry, error)
 }
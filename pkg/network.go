@@ -2,7 +2,10 @@ package pkg
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"net"
+	"time"
 
 	"github.com/threefoldtech/zos/pkg/network/types"
 	"github.com/threefoldtech/zos/pkg/versioned"
@@ -35,7 +38,8 @@ type Networker interface {
 	// name.
 	// The member name specifies the name of the member, and must be unique
 	// The NetID is the network id to join
-	Join(networkdID NetID, containerID string, addrs []string, publicIP6 bool) (join Member, err error)
+	// The traffic of the member is limited to bandwidth
+	Join(networkdID NetID, containerID string, addrs []string, publicIP6 bool, bandwidth Bandwidth) (join Member, err error)
 	// Leave delete a container nameapce created by Join
 	Leave(networkdID NetID, containerID string) (err error)
 
@@ -45,13 +49,42 @@ type Networker interface {
 	// hw is an optional hardware address that will be set on the new interface
 	ZDBPrepare(hw net.HardwareAddr) (string, error)
 
-	// SetupTap sets up a tap device in the network namespace for the networkID. It is hooked
-	// to the network bridge. The name of the tap interface is returned
-	SetupTap(networkID NetID) (string, error)
+	// SetupTap sets up a tap device for the vm with name in the network namespace
+	// for the networkID. It is hooked to the network bridge, a vm gets a tap for
+	// each network it joins. The name of the tap interface is returned
+	SetupTap(networkID NetID, name string) (string, error)
 
-	// RemoveTap removes the tap device from the network namespace
+	// RemoveTap removes the tap device of the vm with name from the network namespace
 	// of the networkID
-	RemoveTap(networkID NetID) error
+	RemoveTap(networkID NetID, name string) error
+
+	// RemoveLegacyTap removes the single tap device of the network namespace of
+	// the networkID, used by the vms deployed before each vm got its own tap.
+	// The tap is kept while a vm is still attached to it
+	RemoveLegacyTap(networkID NetID) error
+
+	// SetupPubTap sets up a tap device for the vm with name hooked to the public
+	// IPv6 interface of the node. The name of the tap interface is returned
+	SetupPubTap(name string) (string, error)
+
+	// RemovePubTap removes the public tap device of the vm with name
+	RemovePubTap(name string) error
+
+	// AddPortForward exposes a port of a workload in the network resource of
+	// networkID on the public IPv4 of the node. The name identifies the
+	// forward and must be unique. The public port allocated to the forward
+	// is returned, adding the same forward again returns the same port
+	AddPortForward(networkID NetID, name string, forward PortForward) (uint16, error)
+
+	// RemovePortForward removes the forward added with name and releases
+	// its public port
+	RemovePortForward(name string) error
+
+	// Bandwidth returns the configured limit and the measured rate of the
+	// traffic of the network resource of networkID. If member is not empty
+	// the bandwidth of the container that joined the network as member is
+	// returned instead
+	Bandwidth(networkID NetID, member string) (BandwidthUsage, error)
 
 	// GetSubnet of the network with the given ID on the local node
 	GetSubnet(networkID NetID) (net.IPNet, error)
@@ -74,8 +107,39 @@ type Networker interface {
 	YggAddresses(ctx context.Context) <-chan NetlinkAddresses
 
 	PublicAddresses(ctx context.Context) <-chan NetlinkAddresses
+
+	// NetResourceCounters monitoring streams for the traffic
+	// counters of the network resources of the node
+	NetResourceCounters(ctx context.Context) <-chan NetResourcesCounters
+}
+
+// WGPeerCounters are the counters of a wireguard peer of a network resource
+type WGPeerCounters struct {
+	PublicKey     string    `json:"public_key"`
+	Endpoint      string    `json:"endpoint"`
+	RxBytes       uint64    `json:"rx_bytes"`
+	TxBytes       uint64    `json:"tx_bytes"`
+	LastHandshake time.Time `json:"last_handshake"`
+}
+
+// NetResourceCounters are the traffic counters of a network resource
+type NetResourceCounters struct {
+	NetID NetID `json:"net_id"`
+	// Peers are the counters of the wireguard peers
+	Peers []WGPeerCounters `json:"peers"`
+	// BridgeRxBytes and BridgeTxBytes count the traffic from and to
+	// the workloads on the network resource bridge
+	BridgeRxBytes uint64 `json:"bridge_rx_bytes"`
+	BridgeTxBytes uint64 `json:"bridge_tx_bytes"`
+	// DroppedPackets and DroppedBytes count the traffic
+	// dropped by the firewall of the network resource
+	DroppedPackets uint64 `json:"dropped_packets"`
+	DroppedBytes   uint64 `json:"dropped_bytes"`
 }
 
+// NetResourcesCounters alias for []NetResourceCounters required by zbus
+type NetResourcesCounters []NetResourceCounters
+
 // Network represent the description if a user private network
 type Network struct {
 	Name string `json:"name"`
@@ -104,6 +168,129 @@ type NetResource struct {
 	WGListenPort uint16 `json:"wg_listen_port"`
 
 	Peers []Peer `json:"peers"`
+
+	// Firewall filters the traffic forwarded from and to
+	// the workloads of the network resource
+	Firewall Firewall `json:"firewall"`
+
+	// Bandwidth limits the traffic of all the
+	// workloads of the network resource
+	Bandwidth Bandwidth `json:"bandwidth"`
+}
+
+// MaxBandwidth is the highest rate limit in Kbit/s
+const MaxBandwidth = math.MaxUint32 * 8 / 1000
+
+// Bandwidth is a rate limit of the traffic in Kbit/s. Ingress is the
+// traffic to the workloads, egress the traffic from them. 0 means unlimited
+type Bandwidth struct {
+	Ingress uint64 `json:"ingress"`
+	Egress  uint64 `json:"egress"`
+}
+
+// Validate bandwidth data
+func (b *Bandwidth) Validate() error {
+	if b.Ingress > MaxBandwidth {
+		return fmt.Errorf("ingress rate cannot be higher than %d Kbit/s", uint64(MaxBandwidth))
+	}
+
+	if b.Egress > MaxBandwidth {
+		return fmt.Errorf("egress rate cannot be higher than %d Kbit/s", uint64(MaxBandwidth))
+	}
+
+	return nil
+}
+
+// BandwidthUsage is the configured limit and
+// the measured rate of the traffic in Kbit/s
+type BandwidthUsage struct {
+	Limit Bandwidth `json:"limit"`
+	Rate  Bandwidth `json:"rate"`
+}
+
+// FirewallAction is the action applied to the traffic matched by a firewall rule
+type FirewallAction string
+
+// Possible firewall actions
+const (
+	FirewallAccept FirewallAction = "accept"
+	FirewallDrop   FirewallAction = "drop"
+)
+
+// FirewallRule matches the new connections from or to a remote address
+type FirewallRule struct {
+	// Action applied to the matched connections
+	Action FirewallAction `json:"action"`
+	// CIDR of the remote address, empty matches any address
+	CIDR types.IPNet `json:"cidr"`
+	// Protocol of the connection, one of tcp, udp or icmp. Empty
+	// matches any protocol
+	Protocol string `json:"protocol"`
+	// Port is the destination port of the connection, only valid
+	// with tcp and udp. 0 matches any port
+	Port uint16 `json:"port"`
+}
+
+// Firewall of a network resource. The rules are evaluated in order, the
+// first rule matching a new connection decides its fate. The connections
+// that match no rule get the policy of their direction, accept if empty.
+// The established connections are always accepted
+type Firewall struct {
+	// Ingress rules match the connections to the workloads
+	Ingress []FirewallRule `json:"ingress"`
+	// Egress rules match the connections from the workloads
+	Egress []FirewallRule `json:"egress"`
+
+	IngressPolicy FirewallAction `json:"ingress_policy"`
+	EgressPolicy  FirewallAction `json:"egress_policy"`
+}
+
+// Validate firewall data
+func (f *Firewall) Validate() error {
+	for _, policy := range []FirewallAction{f.IngressPolicy, f.EgressPolicy} {
+		if policy != "" && policy != FirewallAccept && policy != FirewallDrop {
+			return fmt.Errorf("invalid policy '%s' must be accept or drop", policy)
+		}
+	}
+
+	for i, rule := range f.Ingress {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid ingress rule %d: %w", i, err)
+		}
+	}
+
+	for i, rule := range f.Egress {
+		if err := rule.Validate(); err != nil {
+			return fmt.Errorf("invalid egress rule %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// Validate firewall rule data
+func (r *FirewallRule) Validate() error {
+	if r.Action != FirewallAccept && r.Action != FirewallDrop {
+		return fmt.Errorf("invalid action '%s' must be accept or drop", r.Action)
+	}
+
+	switch r.Protocol {
+	case "tcp", "udp":
+	case "icmp", "":
+		if r.Port != 0 {
+			return fmt.Errorf("port is only valid with tcp and udp")
+		}
+	default:
+		return fmt.Errorf("unsupported protocol '%s' must be tcp, udp or icmp", r.Protocol)
+	}
+
+	if !r.CIDR.Nil() {
+		if _, bits := r.CIDR.Mask.Size(); bits == 0 || r.CIDR.IP == nil {
+			return fmt.Errorf("invalid cidr '%s'", r.CIDR.String())
+		}
+	}
+
+	return nil
 }
 
 // Peer is the description of a peer of a NetResource
@@ -116,6 +303,17 @@ type Peer struct {
 	Endpoint    string        `json:"endpoint"`
 }
 
+// PortForward describes a port of a workload in a network resource
+// that is exposed on the public IPv4 of the node
+type PortForward struct {
+	// Protocol of the port, either tcp or udp
+	Protocol string `json:"protocol"`
+	// IP of the workload in the network resource
+	IP net.IP `json:"ip"`
+	// Port of the workload
+	Port uint16 `json:"port"`
+}
+
 // NetID is a type defining the ID of a network
 type NetID string
 
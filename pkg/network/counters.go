@@ -0,0 +1,74 @@
+package network
+
+import (
+	"context"
+	"io/ioutil"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+)
+
+// countersPeriod is the period of the network resources counters stream
+const countersPeriod = 5 * time.Second
+
+// NetResourceCounters implements pkg.Networker interface
+func (n *networker) NetResourceCounters(ctx context.Context) <-chan pkg.NetResourcesCounters {
+	ch := make(chan pkg.NetResourcesCounters)
+	go func() {
+		defer close(ch)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(countersPeriod):
+				result := n.netResourcesCounters()
+
+				select {
+				case ch <- result:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// netResourcesCounters returns the counters of all the network resources of the
+// node, the network resources whose counters cannot be read are skipped
+func (n *networker) netResourcesCounters() pkg.NetResourcesCounters {
+	infos, err := ioutil.ReadDir(n.networkDir)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list network resources")
+		return nil
+	}
+
+	result := make(pkg.NetResourcesCounters, 0, len(infos))
+	for _, info := range infos {
+		netNR, err := n.networkOf(info.Name())
+		if err != nil {
+			log.Error().Err(err).Str("network", info.Name()).Msg("failed to load network resource")
+			continue
+		}
+
+		netr, err := nr.New(netNR)
+		if err != nil {
+			log.Error().Err(err).Str("network", info.Name()).Msg("failed to load network resource")
+			continue
+		}
+
+		counters, err := netr.Counters()
+		if err != nil {
+			log.Error().Err(err).Str("network", info.Name()).Msg("failed to get network resource counters")
+			continue
+		}
+
+		result = append(result, counters)
+	}
+
+	return result
+}
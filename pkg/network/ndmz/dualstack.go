@@ -113,8 +113,8 @@ func (d *DualStack) AttachNR(networkID string, nr *nr.NetResource, ipamLeaseDir
 		return err
 	}
 
-	if !ifaceutil.Exists(nrPubIface, nrNS) {
-		if _, err = macvlan.Create(nrPubIface, BridgeNDMZ, nrNS); err != nil {
+	if !ifaceutil.Exists(NRPubIface, nrNS) {
+		if _, err = macvlan.Create(NRPubIface, BridgeNDMZ, nrNS); err != nil {
 			return err
 		}
 	}
@@ -125,7 +125,7 @@ func (d *DualStack) AttachNR(networkID string, nr *nr.NetResource, ipamLeaseDir
 			return errors.Wrap(err, "ip allocation for network resource")
 		}
 
-		pubIface, err := netlink.LinkByName(nrPubIface)
+		pubIface, err := netlink.LinkByName(NRPubIface)
 		if err != nil {
 			return err
 		}
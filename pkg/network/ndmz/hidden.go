@@ -93,8 +93,8 @@ func (d *Hidden) AttachNR(networkID string, nr *nr.NetResource, ipamLeaseDir str
 		return err
 	}
 
-	if !ifaceutil.Exists(nrPubIface, nrNS) {
-		if _, err = macvlan.Create(nrPubIface, BridgeNDMZ, nrNS); err != nil {
+	if !ifaceutil.Exists(NRPubIface, nrNS) {
+		if _, err = macvlan.Create(NRPubIface, BridgeNDMZ, nrNS); err != nil {
 			return err
 		}
 	}
@@ -105,7 +105,7 @@ func (d *Hidden) AttachNR(networkID string, nr *nr.NetResource, ipamLeaseDir str
 			return errors.Wrap(err, "ip allocation for network resource")
 		}
 
-		pubIface, err := netlink.LinkByName(nrPubIface)
+		pubIface, err := netlink.LinkByName(NRPubIface)
 		if err != nil {
 			return err
 		}
@@ -38,8 +38,8 @@ const (
 	// DMZPub6 ipv6 public interface
 	DMZPub6 = "npub6"
 
-	//nrPubIface is the name of the public interface in a network resource
-	nrPubIface = "public"
+	// NRPubIface is the name of the public interface in a network resource
+	NRPubIface = "public"
 )
 
 // DMZ is an interface used to create an DMZ network namespace
@@ -215,6 +215,29 @@ func applyFirewall() error {
 	return nil
 }
 
+// PortForward is a public port of the node forwarded to the
+// public IP of a network resource, on the same port
+type PortForward struct {
+	Protocol string
+	Port     uint16
+	IP       net.IP
+}
+
+// ApplyPortForwards replaces the public ports of the node forwarded
+// to the network resources with forwards
+func ApplyPortForwards(forwards []PortForward) error {
+	buf := bytes.Buffer{}
+	if err := forwardTmpl.Execute(&buf, forwards); err != nil {
+		return errors.Wrap(err, "failed to build nft port forward rule set")
+	}
+
+	if err := nft.Apply(&buf, NetNSNDMZ); err != nil {
+		return errors.Wrap(err, "failed to apply nft port forward rule set")
+	}
+
+	return nil
+}
+
 func convertIpv4ToIpv6(ip net.IP) net.IP {
 	var ipv6 string
 	if len(ip) == net.IPv4len {
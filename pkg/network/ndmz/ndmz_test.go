@@ -1,6 +1,7 @@
 package ndmz
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -94,3 +95,17 @@ func TestIPv4AllocateConcurent(t *testing.T) {
 	wg.Wait()
 	close(c)
 }
+
+func TestPortForwardTemplate(t *testing.T) {
+	forwards := []PortForward{
+		{Protocol: "tcp", Port: 20000, IP: net.ParseIP("100.127.0.3")},
+		{Protocol: "udp", Port: 20001, IP: net.ParseIP("100.127.0.4")},
+	}
+
+	buf := bytes.Buffer{}
+	require.NoError(t, forwardTmpl.Execute(&buf, forwards))
+
+	rules := buf.String()
+	assert.Contains(t, rules, `iifname "npub4" tcp dport 20000 dnat to 100.127.0.3`)
+	assert.Contains(t, rules, `iifname "npub4" udp dport 20001 dnat to 100.127.0.4`)
+}
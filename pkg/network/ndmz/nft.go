@@ -4,10 +4,14 @@ import (
 	"text/template"
 )
 
-var fwTmpl *template.Template
+var (
+	fwTmpl      *template.Template
+	forwardTmpl *template.Template
+)
 
 func init() {
 	fwTmpl = template.Must(template.New("").Parse(_nft))
+	forwardTmpl = template.Must(template.New("forward").Parse(_nftForward))
 }
 
 var _nft = `
@@ -57,6 +61,8 @@ table inet filter {
     type filter hook forward priority 0; policy accept;
     # is there already an existing stream? (outgoing)
     jump base_checks
+    # accept the connections to the ports exposed by the network resources
+    ct status dnat accept
     # if not, verify if it's new and coming in from the br4-gw network
     # if it is, drop it
     iifname "npub6" counter drop
@@ -68,3 +74,19 @@ table inet filter {
   }
 }
 `
+
+// _nftForward replaces the table of the ports exposed by the network
+// resources, the table is created first so deleting it never fails
+var _nftForward = `
+add table ip portforward
+delete table ip portforward
+
+table ip portforward {
+  chain prerouting {
+    type nat hook prerouting priority dstnat; policy accept;
+{{- range .}}
+    iifname "npub4" {{.Protocol}} dport {{.Port}} dnat to {{.IP}}
+{{- end}}
+  }
+}
+`
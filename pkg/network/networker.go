@@ -2,6 +2,7 @@ package network
 
 import (
 	"context"
+	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/termie/go-shutil"
@@ -16,6 +18,8 @@ import (
 	"github.com/threefoldtech/tfexplorer/client"
 	"github.com/threefoldtech/zos/pkg/cache"
 	"github.com/threefoldtech/zos/pkg/network/ndmz"
+	"github.com/threefoldtech/zos/pkg/network/portm"
+	"github.com/threefoldtech/zos/pkg/network/portm/backend"
 	"github.com/threefoldtech/zos/pkg/network/tuntap"
 	"github.com/threefoldtech/zos/pkg/network/yggdrasil"
 
@@ -41,11 +45,13 @@ import (
 
 const (
 	// ZDBIface is the name of the interface used in the 0-db network namespace
-	ZDBIface     = "zdb0"
-	wgPortDir    = "wireguard_ports"
-	networkDir   = "networks"
-	ipamLeaseDir = "ndmz-lease"
-	ipamPath     = "/var/cache/modules/networkd/lease"
+	ZDBIface       = "zdb0"
+	wgPortDir      = "wireguard_ports"
+	networkDir     = "networks"
+	ipamLeaseDir   = "ndmz-lease"
+	forwardDir     = "port_forwards"
+	forwardPortDir = "port_forward_ports"
+	ipamPath       = "/var/cache/modules/networkd/lease"
 )
 
 const (
@@ -59,6 +65,10 @@ type networker struct {
 	tnodb        client.Directory
 	portSet      *set.UintSet
 
+	forwardDir    string
+	forwardLock   *sync.Mutex
+	portAllocator portm.PortAllocator
+
 	ndmz ndmz.DMZ
 	ygg  *yggdrasil.Server
 }
@@ -78,6 +88,16 @@ func NewNetworker(identity pkg.IdentityManager, tnodb client.Directory, storageD
 	nwDir := filepath.Join(vd, networkDir)
 	ipamLease := filepath.Join(vd, ipamLeaseDir)
 
+	fwdDir := filepath.Join(vd, forwardDir)
+	if err := os.MkdirAll(fwdDir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create port forward cache directory: %w", err)
+	}
+
+	fwdPortStore, err := backend.NewFSStore(filepath.Join(vd, forwardPortDir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create port forward port store: %w", err)
+	}
+
 	oldPath := filepath.Join(ipamPath, "ndmz")
 	newPath := filepath.Join(ipamLease, "ndmz")
 	if _, err := os.Stat(oldPath); err == nil {
@@ -101,6 +121,10 @@ func NewNetworker(identity pkg.IdentityManager, tnodb client.Directory, storageD
 		ipamLeaseDir: ipamLease,
 		portSet:      set.NewUint(wgDir),
 
+		forwardDir:    fwdDir,
+		forwardLock:   &sync.Mutex{},
+		portAllocator: portm.NewAllocator(forwardPorts, fwdPortStore),
+
 		ygg:  ygg,
 		ndmz: ndmz,
 	}
@@ -116,6 +140,13 @@ func NewNetworker(identity pkg.IdentityManager, tnodb client.Directory, storageD
 		return nil, err
 	}
 
+	// the ndmz firewall has been reset when the ndmz was created
+	if forwards, err := nw.portForwards(); err != nil {
+		log.Error().Err(err).Msg("failed to list port forwards")
+	} else if err := nw.applyDMZPortForwards(forwards); err != nil {
+		log.Error().Err(err).Msg("failed to apply port forwards")
+	}
+
 	return nw, nil
 }
 
@@ -161,7 +192,7 @@ func (n *networker) Ready() error {
 	return nil
 }
 
-func (n *networker) Join(networkdID pkg.NetID, containerID string, addrs []string, publicIP6 bool) (join pkg.Member, err error) {
+func (n *networker) Join(networkdID pkg.NetID, containerID string, addrs []string, publicIP6 bool, bandwidth pkg.Bandwidth) (join pkg.Member, err error) {
 	// TODO:
 	// 1- Make sure this network id is actually deployed
 	// 2- Create a new namespace, then create a veth pair inside this namespace
@@ -186,7 +217,7 @@ func (n *networker) Join(networkdID pkg.NetID, containerID string, addrs []strin
 		ips[i] = net.ParseIP(addr)
 	}
 
-	join, err = netRes.Join(containerID, ips, publicIP6)
+	join, err = netRes.Join(containerID, ips, publicIP6, bandwidth)
 	if err != nil {
 		return join, errors.Wrap(err, "failed to load network resource")
 	}
@@ -224,6 +255,25 @@ func (n *networker) Leave(networkdID pkg.NetID, containerID string) error {
 	return netRes.Leave(containerID)
 }
 
+// Bandwidth implements pkg.Networker interface
+func (n *networker) Bandwidth(networkID pkg.NetID, member string) (usage pkg.BandwidthUsage, err error) {
+	localNR, err := n.networkOf(string(networkID))
+	if err != nil {
+		return usage, errors.Wrapf(err, "couldn't load network with id (%s)", networkID)
+	}
+
+	netRes, err := nr.New(localNR)
+	if err != nil {
+		return usage, errors.Wrap(err, "failed to load network resource")
+	}
+
+	if member == "" {
+		return netRes.Bandwidth()
+	}
+
+	return netRes.MemberBandwidth(member)
+}
+
 // ZDBPrepare sends a macvlan interface into the
 // network namespace of a ZDB container
 func (n networker) ZDBPrepare(hw net.HardwareAddr) (string, error) {
@@ -292,10 +342,9 @@ func (n networker) createMacVlan(iface string, hw net.HardwareAddr, ips []*net.I
 	return nil
 }
 
-// SetupTap interface in the network resource. We only allow 1 tap interface to be
-// set up per NR currently
-func (n *networker) SetupTap(networkID pkg.NetID) (string, error) {
-	log.Info().Str("network-id", string(networkID)).Msg("Setting up tap interface")
+// SetupTap interface in the network resource for the vm with name
+func (n *networker) SetupTap(networkID pkg.NetID, name string) (string, error) {
+	log.Info().Str("network-id", string(networkID)).Str("name", name).Msg("Setting up tap interface")
 
 	localNR, err := n.networkOf(string(networkID))
 	if err != nil {
@@ -312,9 +361,10 @@ func (n *networker) SetupTap(networkID pkg.NetID) (string, error) {
 		return "", errors.Wrap(err, "could not get network namespace bridge")
 	}
 
-	tapIface, err := tapName(networkID)
-	if err != nil {
-		return "", errors.Wrap(err, "could not get network namespace tap device name")
+	tapIface := tapName(networkID, name)
+	if ifaceutil.Exists(tapIface, nil) {
+		// the vm is redeployed
+		return tapIface, nil
 	}
 
 	_, err = tuntap.CreateTap(tapIface, bridgeName)
@@ -323,17 +373,74 @@ func (n *networker) SetupTap(networkID pkg.NetID) (string, error) {
 }
 
 // RemoveTap in the network resource.
-func (n *networker) RemoveTap(networkID pkg.NetID) error {
-	log.Info().Str("network-id", string(networkID)).Msg("Removing tap interface")
+func (n *networker) RemoveTap(networkID pkg.NetID, name string) error {
+	log.Info().Str("network-id", string(networkID)).Str("name", name).Msg("Removing tap interface")
 
-	tapIface, err := tapName(networkID)
+	tapIface := tapName(networkID, name)
+	if !ifaceutil.Exists(tapIface, nil) {
+		return nil
+	}
+
+	return ifaceutil.Delete(tapIface, nil)
+}
+
+// RemoveLegacyTap removes the single tap device of the network resource used
+// by the vms deployed before taps were set up per vm. The tap is kept as long as
+// a vm is still attached to it
+func (n *networker) RemoveLegacyTap(networkID pkg.NetID) error {
+	log.Info().Str("network-id", string(networkID)).Msg("Removing legacy tap interface")
+
+	tapIface, err := legacyTapName(networkID)
 	if err != nil {
 		return errors.Wrap(err, "could not get network namespace tap device name")
 	}
 
+	if !ifaceutil.Exists(tapIface, nil) {
+		return nil
+	}
+
+	// the carrier of a tap is up while a process has it open
+	if ifaceutil.IsPlugged(tapIface) {
+		log.Info().Str("tap", tapIface).Msg("legacy tap interface still in use, keeping it")
+		return nil
+	}
+
 	return ifaceutil.Delete(tapIface, nil)
 }
 
+// SetupPubTap sets up a tap interface for the vm with name hooked
+// to the public IPv6 interface of the node
+func (n *networker) SetupPubTap(name string) (string, error) {
+	log.Info().Str("name", name).Msg("Setting up public tap interface")
+
+	master := n.ndmz.IP6PublicIface()
+	link, err := netlink.LinkByName(master)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not find public interface %s", master)
+	}
+
+	if link.Type() != "bridge" {
+		return "", fmt.Errorf("public interface %s is not a bridge, cannot attach a tap to it", master)
+	}
+
+	tapIface := pubTapName(name)
+	if ifaceutil.Exists(tapIface, nil) {
+		// the vm is redeployed
+		return tapIface, nil
+	}
+
+	_, err = tuntap.CreateTap(tapIface, master)
+
+	return tapIface, err
+}
+
+// RemovePubTap removes the public tap interface of the vm with name
+func (n *networker) RemovePubTap(name string) error {
+	log.Info().Str("name", name).Msg("Removing public tap interface")
+
+	return ifaceutil.Delete(pubTapName(name), nil)
+}
+
 // GetSubnet of a local network resource identified by the network ID
 func (n networker) GetSubnet(networkID pkg.NetID) (net.IPNet, error) {
 	localNR, err := n.networkOf(string(networkID))
@@ -465,6 +572,19 @@ func (n *networker) CreateNR(netNR pkg.NetResource) (string, error) {
 		return "", errors.Wrapf(err, "failed to attach network resource to DMZ bridge")
 	}
 
+	if err := netr.ApplyBandwidth(); err != nil {
+		cleanup()
+		return "", errors.Wrap(err, "failed to set network resource bandwidth")
+	}
+
+	// the firewall of the network resource has been reset by Create
+	n.forwardLock.Lock()
+	err = n.applyPortForwards(netNR.NetID)
+	n.forwardLock.Unlock()
+	if err != nil {
+		return "", err
+	}
+
 	if err := netr.ConfigureWG(privateKey); err != nil {
 		cleanup()
 		return "", errors.Wrap(err, "failed to configure network resource")
@@ -810,8 +930,22 @@ func createNetNS(name string) (ns.NetNS, error) {
 	return netNs, nil
 }
 
-// tapName returns the name of the tap device for a network namespace
-func tapName(netID pkg.NetID) (string, error) {
+// tapName returns the name of the tap device of the vm with name in a network.
+// Names are hashed to fit the 15 characters limit of interface names
+func tapName(netID pkg.NetID, name string) string {
+	h := md5.Sum([]byte(fmt.Sprintf("%s-%s", netID, name)))
+	return fmt.Sprintf("t-%x", h[:6])
+}
+
+// pubTapName returns the name of the public tap device of the vm with name
+func pubTapName(name string) string {
+	h := md5.Sum([]byte(name))
+	return fmt.Sprintf("p-%x", h[:6])
+}
+
+// legacyTapName returns the name of the single tap device
+// of a network namespace
+func legacyTapName(netID pkg.NetID) (string, error) {
 	name := fmt.Sprintf("t-%s", netID)
 	if len(name) > 15 {
 		return "", errors.Errorf("tap name too long %s", name)
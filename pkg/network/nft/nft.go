@@ -1,6 +1,8 @@
 package nft
 
 import (
+	"bytes"
+	"encoding/json"
 	"io"
 	"os/exec"
 
@@ -32,3 +34,56 @@ func Apply(r io.Reader, ns string) error {
 	}
 	return nil
 }
+
+// Counter is a named nft counter
+type Counter struct {
+	Family  string `json:"family"`
+	Table   string `json:"table"`
+	Name    string `json:"name"`
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// Counters lists the named counters of the ruleset
+// if ns is specified, the nft command is execute in the network namespace names ns
+func Counters(ns string) ([]Counter, error) {
+	var cmd *exec.Cmd
+
+	if ns != "" {
+		cmd = exec.Command("ip", "netns", "exec", ns, "nft", "-j", "list", "counters")
+	} else {
+		cmd = exec.Command("nft", "-j", "list", "counters")
+	}
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to execute nft: %v", stderr.String())
+	}
+
+	return parseCounters(out)
+}
+
+// parseCounters parses the json output of nft list counters
+func parseCounters(data []byte) ([]Counter, error) {
+	var output struct {
+		Objects []struct {
+			Counter *Counter `json:"counter"`
+		} `json:"nftables"`
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return nil, errors.Wrap(err, "failed to decode nft counters")
+	}
+
+	var counters []Counter
+	for _, object := range output.Objects {
+		if object.Counter != nil {
+			counters = append(counters, *object.Counter)
+		}
+	}
+
+	return counters, nil
+}
@@ -0,0 +1,26 @@
+package nft
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCounters(t *testing.T) {
+	input := `{"nftables": [
+		{"metainfo": {"version": "0.9.6", "json_schema_version": 1}},
+		{"counter": {"family": "inet", "name": "dropped", "table": "filter", "handle": 3, "packets": 12, "bytes": 840}},
+		{"counter": {"family": "inet", "name": "dropped", "table": "tenant", "handle": 2, "packets": 1, "bytes": 60}}
+	]}`
+
+	counters, err := parseCounters([]byte(input))
+	require.NoError(t, err)
+	assert.Equal(t, []Counter{
+		{Family: "inet", Table: "filter", Name: "dropped", Packets: 12, Bytes: 840},
+		{Family: "inet", Table: "tenant", Name: "dropped", Packets: 1, Bytes: 60},
+	}, counters)
+
+	_, err = parseCounters([]byte("Error: syntax error"))
+	assert.Error(t, err)
+}
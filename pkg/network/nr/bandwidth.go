@@ -0,0 +1,258 @@
+package nr
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/tc"
+	"github.com/vishvananda/netlink"
+)
+
+// ratePeriod is the period over which the traffic is
+// sampled to compute the measured rates
+const ratePeriod = time.Second
+
+// ifbName returns the name of the ifb device shaping the egress traffic
+// of the network resource
+func (nr *NetResource) ifbName() (string, error) {
+	name := fmt.Sprintf("i-%s", nr.id)
+	if len(name) > 15 {
+		return "", errors.Errorf("ifb interface name too long %s", name)
+	}
+	return name, nil
+}
+
+// ApplyBandwidth limits the traffic of the network resource to its configured
+// bandwidth. Both limits are set on the interface attached to the network
+// resource bridge: the ingress traffic is shaped when it's sent to the bridge,
+// the egress traffic when it's received from the bridge, through an ifb device.
+// This way the egress limit applies to the traffic sent over wireguard and to
+// the public network together
+func (nr *NetResource) ApplyBandwidth() error {
+	bw := nr.resource.Bandwidth
+	if err := bw.Validate(); err != nil {
+		return errors.Wrap(err, "invalid bandwidth")
+	}
+
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return err
+	}
+	ifbName, err := nr.ifbName()
+	if err != nil {
+		return err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	log.Info().
+		Str("namespace", nsName).
+		Uint64("ingress", bw.Ingress).
+		Uint64("egress", bw.Egress).
+		Msg("set network resource bandwidth")
+
+	return netNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(nrIface)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get interface %s", nrIface)
+		}
+
+		if err := tc.SetRate(link, bw.Ingress); err != nil {
+			return err
+		}
+
+		ifb, err := ensureIfb(ifbName)
+		if err != nil {
+			return err
+		}
+
+		return tc.SetIngressRate(link, ifb, bw.Egress)
+	})
+}
+
+// ensureIfb creates the ifb device name if it doesn't exist, and sets it up
+func ensureIfb(name string) (netlink.Link, error) {
+	link, err := netlink.LinkByName(name)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		ifb := &netlink.Ifb{LinkAttrs: netlink.LinkAttrs{Name: name}}
+		if err := netlink.LinkAdd(ifb); err != nil {
+			return nil, errors.Wrapf(err, "failed to create ifb interface %s", name)
+		}
+
+		link, err = netlink.LinkByName(name)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get interface %s", name)
+	}
+
+	if err := netlink.LinkSetUp(link); err != nil {
+		return nil, errors.Wrapf(err, "failed to set interface %s up", name)
+	}
+
+	return link, nil
+}
+
+// Bandwidth returns the configured limit and the measured rate of the
+// traffic of the network resource
+func (nr *NetResource) Bandwidth() (usage pkg.BandwidthUsage, err error) {
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return usage, err
+	}
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return usage, err
+	}
+	ifbName, err := nr.ifbName()
+	if err != nil {
+		return usage, err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return usage, fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	err = netNS.Do(func(_ ns.NetNS) error {
+		link, err := netlink.LinkByName(nrIface)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get interface %s", nrIface)
+		}
+
+		if usage.Limit.Ingress, err = tc.Rate(link); err != nil {
+			return err
+		}
+
+		// the ifb device only exists once an egress limit has been set
+		if ifb, err := netlink.LinkByName(ifbName); err == nil {
+			if usage.Limit.Egress, err = tc.Rate(ifb); err != nil {
+				return err
+			}
+		}
+
+		// the traffic sent on the bridge goes to the workloads
+		// and the traffic received from it comes from them
+		usage.Rate.Ingress, usage.Rate.Egress, err = measure(func() (in, out uint64, err error) {
+			stats, err := linkStatistics(nrIface)
+			if err != nil {
+				return 0, 0, err
+			}
+
+			return stats.TxBytes, stats.RxBytes, nil
+		})
+
+		return err
+	})
+
+	return usage, err
+}
+
+// MemberBandwidth returns the configured limit and the measured rate
+// of the traffic of the container that joined the network resource
+func (nr *NetResource) MemberBandwidth(containerID string) (usage pkg.BandwidthUsage, err error) {
+	netNS, err := namespace.GetByName(containerID)
+	if err != nil {
+		return usage, errors.Wrapf(err, "failed to get namespace of member %s", containerID)
+	}
+	defer netNS.Close()
+
+	var hostIndex int
+	err = netNS.Do(func(_ ns.NetNS) error {
+		eth0, err := netlink.LinkByName("eth0")
+		if err != nil {
+			return errors.Wrap(err, "failed to get interface eth0")
+		}
+
+		// the host end of the veth pair
+		hostIndex = eth0.Attrs().ParentIndex
+
+		if usage.Limit.Egress, err = tc.Rate(eth0); err != nil {
+			return err
+		}
+
+		// eth0 receives the traffic to the container
+		// and sends the traffic from the container
+		usage.Rate.Ingress, usage.Rate.Egress, err = measure(func() (in, out uint64, err error) {
+			stats, err := linkStatistics("eth0")
+			if err != nil {
+				return 0, 0, err
+			}
+
+			return stats.RxBytes, stats.TxBytes, nil
+		})
+
+		return err
+	})
+
+	if err != nil {
+		return usage, err
+	}
+
+	hostVeth, err := netlink.LinkByIndex(hostIndex)
+	if err != nil {
+		return usage, errors.Wrapf(err, "failed to get host interface of member %s", containerID)
+	}
+
+	usage.Limit.Ingress, err = tc.Rate(hostVeth)
+
+	return usage, err
+}
+
+// measure calls sample twice, ratePeriod apart, and returns the rates in
+// Kbit/s of the ingress and egress byte counters returned by sample
+func measure(sample func() (in, out uint64, err error)) (in, out uint64, err error) {
+	in0, out0, err := sample()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	time.Sleep(ratePeriod)
+
+	in1, out1, err := sample()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return kbitRate(in0, in1, ratePeriod), kbitRate(out0, out1, ratePeriod), nil
+}
+
+// kbitRate returns the rate in Kbit/s of the bytes counted from
+// before to after during period
+func kbitRate(before, after uint64, period time.Duration) uint64 {
+	if after < before {
+		// the counters have been reset
+		return 0
+	}
+
+	// bits per millisecond are Kbit/s
+	return (after - before) * 8 / uint64(period/time.Millisecond)
+}
+
+func linkStatistics(name string) (*netlink.LinkStatistics, error) {
+	link, err := netlink.LinkByName(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get interface %s", name)
+	}
+
+	stats := link.Attrs().Statistics
+	if stats == nil {
+		return nil, fmt.Errorf("no statistics for interface %s", name)
+	}
+
+	return stats, nil
+}
@@ -14,11 +14,17 @@ import (
 	"github.com/threefoldtech/zos/pkg/network/bridge"
 	"github.com/threefoldtech/zos/pkg/network/ifaceutil"
 	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/tc"
 	"github.com/vishvananda/netlink"
 )
 
 // Join make a network namespace of a container join a network resource network
-func (nr *NetResource) Join(containerID string, addrs []net.IP, publicIP6 bool) (join pkg.Member, err error) {
+// the traffic of the container is limited to bandwidth
+func (nr *NetResource) Join(containerID string, addrs []net.IP, publicIP6 bool, bandwidth pkg.Bandwidth) (join pkg.Member, err error) {
+	if err := bandwidth.Validate(); err != nil {
+		return join, errors.Wrap(err, "invalid bandwidth")
+	}
+
 	name, err := nr.BridgeName()
 	if err != nil {
 		return join, err
@@ -131,7 +137,8 @@ func (nr *NetResource) Join(containerID string, addrs []net.IP, publicIP6 bool)
 			}
 		}
 
-		return nil
+		// eth0 sends the traffic from the container
+		return tc.SetRate(eth0, bandwidth.Egress)
 	})
 
 	if err != nil {
@@ -147,6 +154,11 @@ func (nr *NetResource) Join(containerID string, addrs []net.IP, publicIP6 bool)
 		return join, errors.Wrapf(err, "failed to disable ip6 on bridge %s", hostVeth.Attrs().Name)
 	}
 
+	// the host end of the veth pair sends the traffic to the container
+	if err := tc.SetRate(hostVeth, bandwidth.Ingress); err != nil {
+		return join, err
+	}
+
 	return join, bridge.AttachNic(hostVeth, br)
 }
 
@@ -0,0 +1,94 @@
+package nr
+
+import (
+	"fmt"
+
+	"github.com/containernetworking/plugins/pkg/ns"
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/nft"
+	"github.com/threefoldtech/zos/pkg/network/wireguard"
+)
+
+// Counters returns the traffic counters of the network resource
+func (nr *NetResource) Counters() (counters pkg.NetResourceCounters, err error) {
+	counters.NetID = nr.id
+
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return counters, err
+	}
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return counters, err
+	}
+	wgName, err := nr.WGName()
+	if err != nil {
+		return counters, err
+	}
+
+	netNS, err := namespace.GetByName(nsName)
+	if err != nil {
+		return counters, fmt.Errorf("network namespace %s does not exits", nsName)
+	}
+	defer netNS.Close()
+
+	err = netNS.Do(func(_ ns.NetNS) error {
+		stats, err := linkStatistics(nrIface)
+		if err != nil {
+			return err
+		}
+
+		// the interface receives the traffic from
+		// the workloads and sends the traffic to them
+		counters.BridgeRxBytes = stats.RxBytes
+		counters.BridgeTxBytes = stats.TxBytes
+
+		wg, err := wireguard.GetByName(wgName)
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard interface %s", wgName)
+		}
+
+		device, err := wg.Device()
+		if err != nil {
+			return errors.Wrapf(err, "failed to get wireguard device %s", wgName)
+		}
+
+		for _, peer := range device.Peers {
+			var endpoint string
+			if peer.Endpoint != nil {
+				endpoint = peer.Endpoint.String()
+			}
+
+			counters.Peers = append(counters.Peers, pkg.WGPeerCounters{
+				PublicKey:     peer.PublicKey.String(),
+				Endpoint:      endpoint,
+				RxBytes:       uint64(peer.ReceiveBytes),
+				TxBytes:       uint64(peer.TransmitBytes),
+				LastHandshake: peer.LastHandshakeTime,
+			})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return counters, err
+	}
+
+	nftCounters, err := nft.Counters(nsName)
+	if err != nil {
+		return counters, errors.Wrap(err, "failed to get nft counters")
+	}
+
+	for _, counter := range nftCounters {
+		if counter.Name != droppedCounter {
+			continue
+		}
+
+		counters.DroppedPackets += counter.Packets
+		counters.DroppedBytes += counter.Bytes
+	}
+
+	return counters, nil
+}
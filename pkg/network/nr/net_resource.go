@@ -477,8 +477,17 @@ func (nr *NetResource) applyFirewall() error {
 		return err
 	}
 
+	nrIface, err := nr.NRIface()
+	if err != nil {
+		return err
+	}
+
+	if err := nr.resource.Firewall.Validate(); err != nil {
+		return errors.Wrap(err, "invalid firewall")
+	}
+
 	buf := bytes.Buffer{}
-	if err := fwTmpl.Execute(&buf, nil); err != nil {
+	if err := fwTmpl.Execute(&buf, newFWData(nrIface, nr.resource.Firewall)); err != nil {
 		return errors.Wrap(err, "failed to build nft rule set")
 	}
 
@@ -489,6 +498,36 @@ func (nr *NetResource) applyFirewall() error {
 	return nil
 }
 
+// PortForward is a port of a workload in the network resource exposed on a
+// public port. The traffic to the public port received on the public
+// interface of the network resource is forwarded to the workload
+type PortForward struct {
+	Protocol   string
+	PublicPort uint16
+	IP         net.IP
+	Port       uint16
+}
+
+// ApplyPortForwards replaces the ports exposed by the network resource
+// with forwards
+func (nr *NetResource) ApplyPortForwards(forwards []PortForward) error {
+	nsName, err := nr.Namespace()
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	if err := forwardTmpl.Execute(&buf, forwards); err != nil {
+		return errors.Wrap(err, "failed to build nft port forward rule set")
+	}
+
+	if err := nft.Apply(&buf, nsName); err != nil {
+		return errors.Wrap(err, "failed to apply nft port forward rule set")
+	}
+
+	return nil
+}
+
 func convert4to6(netID string, ip net.IP) net.IP {
 	h := md5.New()
 	md5NetID := h.Sum([]byte(netID))
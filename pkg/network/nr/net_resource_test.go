@@ -1,14 +1,17 @@
 package nr
 
 import (
+	"bytes"
 	"fmt"
 	"net"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/types"
 	"github.com/vishvananda/netlink"
 )
 
@@ -178,3 +181,43 @@ func Test_convert4to6(t *testing.T) {
 		})
 	}
 }
+
+func TestFirewallRules(t *testing.T) {
+	fw := pkg.Firewall{
+		Ingress: []pkg.FirewallRule{
+			{Action: pkg.FirewallAccept, CIDR: types.MustParseIPNet("192.168.1.10/24"), Protocol: "tcp", Port: 22},
+			{Action: pkg.FirewallAccept, Protocol: "icmp"},
+		},
+		Egress: []pkg.FirewallRule{
+			{Action: pkg.FirewallDrop, CIDR: types.MustParseIPNet("2001:db8::/32")},
+			{Action: pkg.FirewallDrop, Protocol: "udp"},
+		},
+		IngressPolicy: pkg.FirewallDrop,
+	}
+
+	data := newFWData("n-net1", fw)
+	assert.Equal(t, []string{
+		"ip saddr 192.168.1.0/24 tcp dport 22 accept",
+		"meta l4proto { icmp, ipv6-icmp } accept",
+	}, data.Ingress)
+	assert.Equal(t, []string{
+		"ip6 daddr 2001:db8::/32 counter name dropped drop",
+		"meta l4proto udp counter name dropped drop",
+	}, data.Egress)
+	assert.Equal(t, pkg.FirewallDrop, data.IngressPolicy)
+	assert.Equal(t, pkg.FirewallAccept, data.EgressPolicy)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, fwTmpl.Execute(&buf, data))
+	assert.Contains(t, buf.String(), `oifname "n-net1" jump nr_ingress`)
+	assert.Contains(t, buf.String(), "ip saddr 192.168.1.0/24 tcp dport 22 accept\n    meta l4proto { icmp, ipv6-icmp } accept\n    counter name dropped drop\n")
+}
+
+func TestKbitRate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(8), kbitRate(0, 1000, time.Second))
+	assert.Equal(uint64(4), kbitRate(1000, 2000, 2*time.Second))
+	assert.Equal(uint64(80000), kbitRate(0, 5000000, 500*time.Millisecond))
+	assert.Equal(uint64(0), kbitRate(2000, 1000, time.Second))
+}
@@ -1,13 +1,24 @@
 package nr
 
 import (
+	"fmt"
+	"net"
+	"strings"
 	"text/template"
+
+	"github.com/threefoldtech/zos/pkg"
 )
 
-var fwTmpl *template.Template
+var (
+	fwTmpl      *template.Template
+	forwardTmpl *template.Template
+)
 
 func init() {
-	fwTmpl = template.Must(template.New("nrfw").Parse(_nft))
+	fwTmpl = template.Must(template.New("nrfw").Funcs(template.FuncMap{
+		"verdict": verdict,
+	}).Parse(_nft))
+	forwardTmpl = template.Must(template.New("nrforward").Parse(_nftForward))
 }
 
 var _nft = `
@@ -33,6 +44,8 @@ table inet nat {
 }
 
 table inet filter {
+  counter dropped {}
+
     chain base_checks {
         # allow established/related connections
         ct state {established, related} accept
@@ -43,20 +56,141 @@ table inet filter {
     type filter hook input priority 0; policy accept;
     jump base_checks
     ip6 nexthdr icmpv6 accept
-    iifname "public" counter drop
+    iifname "public" counter name dropped drop
   }
 
   chain forward {
     type filter hook forward priority 0; policy accept;
         # is there already an existing stream? (outgoing)
         jump base_checks
+        # accept the connections to the exposed ports
+        ct status dnat accept
         # if not, verify if it's new and coming in from the br4-gw network
         # if it is, drop it
-        iifname "public" counter drop
+        iifname "public" counter name dropped drop
   }
 
   chain output {
     type filter hook output priority 0; policy accept;
   }
 }
+
+table inet tenant {
+  counter dropped {}
+
+  chain nr_ingress {
+{{- range .Ingress}}
+    {{.}}
+{{- end}}
+    {{verdict .IngressPolicy}}
+  }
+
+  chain nr_egress {
+{{- range .Egress}}
+    {{.}}
+{{- end}}
+    {{verdict .EgressPolicy}}
+  }
+
+  chain forward {
+    type filter hook forward priority 10; policy accept;
+    ct state {established, related} accept
+    oifname "{{.NRIface}}" jump nr_ingress
+    iifname "{{.NRIface}}" jump nr_egress
+  }
+}
+`
+
+// fwData is the data of the firewall template
+type fwData struct {
+	// NRIface is the interface of the namespace on the network resource bridge
+	NRIface string
+
+	Ingress       []string
+	Egress        []string
+	IngressPolicy pkg.FirewallAction
+	EgressPolicy  pkg.FirewallAction
+}
+
+func newFWData(iface string, fw pkg.Firewall) fwData {
+	data := fwData{
+		NRIface:       iface,
+		IngressPolicy: fw.IngressPolicy,
+		EgressPolicy:  fw.EgressPolicy,
+	}
+
+	if data.IngressPolicy == "" {
+		data.IngressPolicy = pkg.FirewallAccept
+	}
+	if data.EgressPolicy == "" {
+		data.EgressPolicy = pkg.FirewallAccept
+	}
+
+	for _, rule := range fw.Ingress {
+		data.Ingress = append(data.Ingress, nftRule(rule, "saddr"))
+	}
+	for _, rule := range fw.Egress {
+		data.Egress = append(data.Egress, nftRule(rule, "daddr"))
+	}
+
+	return data
+}
+
+// nftRule renders a validated firewall rule. The remote address is the
+// source of the ingress connections and the destination of the egress ones
+func nftRule(rule pkg.FirewallRule, remote string) string {
+	var parts []string
+
+	if !rule.CIDR.Nil() {
+		family := "ip"
+		if rule.CIDR.IP.To4() == nil {
+			family = "ip6"
+		}
+		cidr := net.IPNet{IP: rule.CIDR.IP.Mask(rule.CIDR.Mask), Mask: rule.CIDR.Mask}
+		parts = append(parts, fmt.Sprintf("%s %s %s", family, remote, cidr.String()))
+	}
+
+	switch {
+	case rule.Protocol == "icmp":
+		parts = append(parts, "meta l4proto { icmp, ipv6-icmp }")
+	case rule.Port != 0:
+		parts = append(parts, fmt.Sprintf("%s dport %d", rule.Protocol, rule.Port))
+	case rule.Protocol != "":
+		parts = append(parts, fmt.Sprintf("meta l4proto %s", rule.Protocol))
+	}
+
+	parts = append(parts, verdict(rule.Action))
+
+	return strings.Join(parts, " ")
+}
+
+// droppedCounter is the named counter of the packets
+// dropped by the firewall of the network resource
+const droppedCounter = "dropped"
+
+// verdict renders the verdict of action, the dropped
+// packets are counted in the dropped counter
+func verdict(action pkg.FirewallAction) string {
+	if action == pkg.FirewallDrop {
+		return fmt.Sprintf("counter name %s drop", droppedCounter)
+	}
+
+	return string(action)
+}
+
+// _nftForward replaces the table of the ports exposed by the
+// network resource, the table is created first so deleting it
+// never fails
+var _nftForward = `
+add table ip portforward
+delete table ip portforward
+
+table ip portforward {
+  chain prerouting {
+    type nat hook prerouting priority dstnat; policy accept;
+{{- range .}}
+    iifname "public" {{.Protocol}} dport {{.PublicPort}} dnat to {{.IP}}:{{.Port}}
+{{- end}}
+  }
+}
 `
@@ -0,0 +1,349 @@
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
+	"github.com/threefoldtech/zos/pkg/network/ndmz"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+	"github.com/threefoldtech/zos/pkg/network/portm"
+	"github.com/threefoldtech/zos/pkg/set"
+)
+
+// forwardPorts is the range of the public ports allocated to the port forwards
+var forwardPorts = portm.PortRange{
+	Start: 20000,
+	End:   29999,
+}
+
+// portForward is a port forward added with AddPortForward
+type portForward struct {
+	Name       string          `json:"name"`
+	NetID      pkg.NetID       `json:"net_id"`
+	PublicPort uint16          `json:"public_port"`
+	Forward    pkg.PortForward `json:"forward"`
+}
+
+func (f *portForward) equal(networkID pkg.NetID, forward pkg.PortForward) bool {
+	return f.NetID == networkID &&
+		f.Forward.Protocol == forward.Protocol &&
+		f.Forward.Port == forward.Port &&
+		f.Forward.IP.Equal(forward.IP)
+}
+
+func validatePortForward(forward pkg.PortForward, subnet net.IPNet) error {
+	if forward.Protocol != "tcp" && forward.Protocol != "udp" {
+		return fmt.Errorf("unsupported protocol '%s', must be tcp or udp", forward.Protocol)
+	}
+
+	if forward.Port == 0 {
+		return fmt.Errorf("port cannot be 0")
+	}
+
+	if forward.IP.To4() == nil {
+		return fmt.Errorf("ip must be an IPv4 address")
+	}
+
+	if !subnet.Contains(forward.IP) {
+		return fmt.Errorf("ip %s is not part of the network resource subnet %s", forward.IP, subnet.String())
+	}
+
+	return nil
+}
+
+// AddPortForward implements pkg.Networker interface
+func (n *networker) AddPortForward(networkID pkg.NetID, name string, forward pkg.PortForward) (uint16, error) {
+	n.forwardLock.Lock()
+	defer n.forwardLock.Unlock()
+
+	log.Info().
+		Str("network", string(networkID)).
+		Str("name", name).
+		Str("protocol", forward.Protocol).
+		Uint16("port", forward.Port).
+		Msg("add port forward")
+
+	existing, err := n.portForward(name)
+	if err == nil {
+		if !existing.equal(networkID, forward) {
+			return 0, fmt.Errorf("port forward %s already exists with another configuration", name)
+		}
+
+		return existing.PublicPort, n.applyPortForwards(networkID)
+	} else if !os.IsNotExist(err) {
+		return 0, errors.Wrapf(err, "failed to load port forward %s", name)
+	}
+
+	subnet, err := n.GetSubnet(networkID)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to get subnet of network %s", networkID)
+	}
+
+	if err := validatePortForward(forward, subnet); err != nil {
+		return 0, errors.Wrap(err, "invalid port forward")
+	}
+
+	defer func() {
+		if err := n.publishWGPorts(); err != nil {
+			log.Warn().Err(err).Msg("failed to publish wireguard port to BCDB")
+		}
+	}()
+
+	port, err := n.reserveForwardPort()
+	if err != nil {
+		return 0, err
+	}
+
+	f := portForward{
+		Name:       name,
+		NetID:      networkID,
+		PublicPort: port,
+		Forward:    forward,
+	}
+
+	if err := n.storePortForward(f); err != nil {
+		n.releaseForwardPort(port)
+		return 0, errors.Wrap(err, "failed to store port forward")
+	}
+
+	if err := n.applyPortForwards(networkID); err != nil {
+		if err := n.deletePortForward(f); err != nil {
+			log.Error().Err(err).Str("name", name).Msg("failed to clean up port forward")
+		}
+		return 0, err
+	}
+
+	return port, nil
+}
+
+// RemovePortForward implements pkg.Networker interface
+func (n *networker) RemovePortForward(name string) error {
+	n.forwardLock.Lock()
+	defer n.forwardLock.Unlock()
+
+	log.Info().Str("name", name).Msg("remove port forward")
+
+	f, err := n.portForward(name)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to load port forward %s", name)
+	}
+
+	defer func() {
+		if err := n.publishWGPorts(); err != nil {
+			log.Warn().Err(err).Msg("failed to publish wireguard port to BCDB")
+		}
+	}()
+
+	return n.deletePortForward(f)
+}
+
+// deletePortForward removes the forward, releases its
+// public port and applies the remaining forwards
+func (n *networker) deletePortForward(f portForward) error {
+	path := filepath.Join(n.forwardDir, f.Name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to remove port forward %s", f.Name)
+	}
+
+	n.releaseForwardPort(f.PublicPort)
+
+	return n.applyPortForwards(f.NetID)
+}
+
+// reserveForwardPort allocates a public port for a port forward. The port
+// is also added to the port set, so it cannot be used as a wireguard
+// listen port, the ports already used by wireguard are skipped
+func (n *networker) reserveForwardPort() (uint16, error) {
+	var skipped []int
+	defer func() {
+		for _, port := range skipped {
+			if err := n.portAllocator.Release(ndmz.NetNSNDMZ, port); err != nil {
+				log.Error().Err(err).Int("port", port).Msg("failed to release public port")
+			}
+		}
+	}()
+
+	for {
+		port, err := n.portAllocator.Reserve(ndmz.NetNSNDMZ)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to reserve public port")
+		}
+
+		err = n.portSet.Add(uint(port))
+		if err == nil {
+			log.Debug().Int("port", port).Msg("reserve port forward public port")
+			return uint16(port), nil
+		}
+
+		skipped = append(skipped, port)
+		if _, ok := err.(set.ErrConflict); !ok {
+			return 0, errors.Wrap(err, "failed to reserve public port")
+		}
+	}
+}
+
+func (n *networker) releaseForwardPort(port uint16) {
+	log.Debug().Uint16("port", port).Msg("release port forward public port")
+	if err := n.portAllocator.Release(ndmz.NetNSNDMZ, int(port)); err != nil {
+		log.Error().Err(err).Uint16("port", port).Msg("failed to release public port")
+	}
+	n.portSet.Remove(uint(port))
+}
+
+func (n *networker) storePortForward(f portForward) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(n.forwardDir, f.Name), data, 0600)
+}
+
+func (n *networker) portForward(name string) (f portForward, err error) {
+	data, err := ioutil.ReadFile(filepath.Join(n.forwardDir, name))
+	if err != nil {
+		return f, err
+	}
+
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, errors.Wrapf(err, "failed to decode port forward %s", name)
+	}
+
+	return f, nil
+}
+
+func (n *networker) portForwards() ([]portForward, error) {
+	infos, err := ioutil.ReadDir(n.forwardDir)
+	if err != nil {
+		return nil, err
+	}
+
+	forwards := make([]portForward, 0, len(infos))
+	for _, info := range infos {
+		f, err := n.portForward(info.Name())
+		if err != nil {
+			return nil, err
+		}
+		forwards = append(forwards, f)
+	}
+
+	return forwards, nil
+}
+
+// applyPortForwards programs the port forwards in the namespace of the
+// network resource of networkID and in the ndmz
+func (n *networker) applyPortForwards(networkID pkg.NetID) error {
+	forwards, err := n.portForwards()
+	if err != nil {
+		return errors.Wrap(err, "failed to list port forwards")
+	}
+
+	netNR, err := n.networkOf(string(networkID))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to load network %s", networkID)
+	}
+
+	// the network resource does not exist anymore
+	// if it has been deleted already
+	if err == nil {
+		var nrForwards []nr.PortForward
+		for _, f := range forwards {
+			if f.NetID != networkID {
+				continue
+			}
+
+			nrForwards = append(nrForwards, nr.PortForward{
+				Protocol:   f.Forward.Protocol,
+				PublicPort: f.PublicPort,
+				IP:         f.Forward.IP,
+				Port:       f.Forward.Port,
+			})
+		}
+
+		netr, err := nr.New(netNR)
+		if err != nil {
+			return err
+		}
+
+		if err := netr.ApplyPortForwards(nrForwards); err != nil {
+			return errors.Wrapf(err, "failed to apply port forwards of network %s", networkID)
+		}
+	}
+
+	return n.applyDMZPortForwards(forwards)
+}
+
+// applyDMZPortForwards forwards the public ports of the forwards
+// to the public IP of their network resources
+func (n *networker) applyDMZPortForwards(forwards []portForward) error {
+	ips := make(map[pkg.NetID]net.IP)
+	dmzForwards := make([]ndmz.PortForward, 0, len(forwards))
+
+	for _, f := range forwards {
+		ip, ok := ips[f.NetID]
+		if !ok {
+			var err error
+			ip, err = n.nrPublicIP(f.NetID)
+			if err != nil {
+				// the other forwards are still applied
+				log.Error().Err(err).Str("name", f.Name).Msg("cannot forward public port")
+				continue
+			}
+			ips[f.NetID] = ip
+		}
+
+		dmzForwards = append(dmzForwards, ndmz.PortForward{
+			Protocol: f.Forward.Protocol,
+			Port:     f.PublicPort,
+			IP:       ip,
+		})
+	}
+
+	if err := ndmz.ApplyPortForwards(dmzForwards); err != nil {
+		return errors.Wrap(err, "failed to apply port forwards of the ndmz")
+	}
+
+	return nil
+}
+
+// nrPublicIP returns the IPv4 of the network resource
+// of networkID on the ndmz bridge
+func (n *networker) nrPublicIP(networkID pkg.NetID) (net.IP, error) {
+	netr, err := nr.New(pkg.NetResource{NetID: networkID})
+	if err != nil {
+		return nil, err
+	}
+
+	nsName, err := netr.Namespace()
+	if err != nil {
+		return nil, err
+	}
+
+	if !namespace.Exists(nsName) {
+		return nil, fmt.Errorf("network resource %s does not exist", networkID)
+	}
+
+	ips, err := n.Addrs(ndmz.NRPubIface, nsName)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			return ip, nil
+		}
+	}
+
+	return nil, fmt.Errorf("network resource %s has no public IPv4", networkID)
+}
@@ -0,0 +1,38 @@
+package network
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestValidatePortForward(t *testing.T) {
+	_, subnet, err := net.ParseCIDR("10.1.2.0/24")
+	assert.NoError(t, err)
+
+	tt := []struct {
+		name    string
+		forward pkg.PortForward
+		valid   bool
+	}{
+		{"tcp", pkg.PortForward{Protocol: "tcp", IP: net.ParseIP("10.1.2.3"), Port: 80}, true},
+		{"udp", pkg.PortForward{Protocol: "udp", IP: net.ParseIP("10.1.2.3"), Port: 53}, true},
+		{"protocol", pkg.PortForward{Protocol: "sctp", IP: net.ParseIP("10.1.2.3"), Port: 80}, false},
+		{"port", pkg.PortForward{Protocol: "tcp", IP: net.ParseIP("10.1.2.3")}, false},
+		{"subnet", pkg.PortForward{Protocol: "tcp", IP: net.ParseIP("10.1.3.3"), Port: 80}, false},
+		{"ipv6", pkg.PortForward{Protocol: "tcp", IP: net.ParseIP("fd00::1"), Port: 80}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validatePortForward(tc.forward, *subnet)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
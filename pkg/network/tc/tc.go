@@ -0,0 +1,210 @@
+package tc
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+var (
+	// rootHandle is the handle of the htb qdisc
+	rootHandle = netlink.MakeHandle(1, 0)
+	// classHandle is the handle of the htb class limiting the rate
+	classHandle = netlink.MakeHandle(1, 1)
+	// leafHandle is the handle of the fq_codel qdisc of the htb class
+	leafHandle = netlink.MakeHandle(10, 0)
+	// ingressHandle is the handle of the ingress qdisc
+	ingressHandle = netlink.MakeHandle(0xffff, 0)
+)
+
+// SetRate limits the rate of the traffic sent by link to rate Kbit/s. The
+// traffic is shaped by an htb qdisc, and the queue of the class is managed
+// by fq_codel so the flows share the rate fairly. A rate of 0 removes the
+// limit. It must be called in the network namespace of link
+func SetRate(link netlink.Link, rate uint64) error {
+	if rate == 0 {
+		return removeRate(link)
+	}
+
+	index := link.Attrs().Index
+
+	htb := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    rootHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	// the unclassified traffic goes to the limited class
+	htb.Defcls = 1
+
+	if err := netlink.QdiscReplace(htb); err != nil {
+		return errors.Wrapf(err, "failed to set htb qdisc on %s", link.Attrs().Name)
+	}
+
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: index,
+		Handle:    classHandle,
+		Parent:    rootHandle,
+	}, netlink.HtbClassAttrs{
+		// the class takes a rate in bit/s
+		Rate: rate * 1000,
+	})
+
+	if err := netlink.ClassReplace(class); err != nil {
+		return errors.Wrapf(err, "failed to set htb class on %s", link.Attrs().Name)
+	}
+
+	fq := netlink.NewFqCodel(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    leafHandle,
+		Parent:    classHandle,
+	})
+
+	if err := netlink.QdiscReplace(fq); err != nil {
+		return errors.Wrapf(err, "failed to set fq_codel qdisc on %s", link.Attrs().Name)
+	}
+
+	return nil
+}
+
+// SetIngressRate limits the rate of the traffic received by link to rate
+// Kbit/s. The received traffic is redirected to the ifb device, where it's
+// shaped by SetRate. A rate of 0 removes the limit. It must be called in the
+// network namespace of link and ifb
+func SetIngressRate(link, ifb netlink.Link, rate uint64) error {
+	if rate == 0 {
+		if err := removeIngress(link); err != nil {
+			return err
+		}
+		return removeRate(ifb)
+	}
+
+	if err := SetRate(ifb, rate); err != nil {
+		return err
+	}
+
+	index := link.Attrs().Index
+
+	ingress := &netlink.Ingress{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: index,
+			Handle:    ingressHandle,
+			Parent:    netlink.HANDLE_INGRESS,
+		},
+	}
+
+	if err := netlink.QdiscReplace(ingress); err != nil {
+		return errors.Wrapf(err, "failed to set ingress qdisc on %s", link.Attrs().Name)
+	}
+
+	// the redirect is added again in case the ifb device changed
+	filters, err := netlink.FilterList(link, ingressHandle)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list ingress filters of %s", link.Attrs().Name)
+	}
+
+	for _, filter := range filters {
+		if err := netlink.FilterDel(filter); err != nil {
+			return errors.Wrapf(err, "failed to delete ingress filter of %s", link.Attrs().Name)
+		}
+	}
+
+	// a u32 filter without selector matches all the traffic
+	redirect := &netlink.U32{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: index,
+			Parent:    ingressHandle,
+			Priority:  1,
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Actions: []netlink.Action{
+			netlink.NewMirredAction(ifb.Attrs().Index),
+		},
+	}
+
+	if err := netlink.FilterAdd(redirect); err != nil {
+		return errors.Wrapf(err, "failed to redirect the traffic of %s to %s", link.Attrs().Name, ifb.Attrs().Name)
+	}
+
+	return nil
+}
+
+// removeIngress removes the ingress qdisc set by SetIngressRate, and
+// the redirect filter with it
+func removeIngress(link netlink.Link) error {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return errors.Wrapf(err, "failed to list qdiscs of %s", link.Attrs().Name)
+	}
+
+	for _, qdisc := range qdiscs {
+		if qdisc.Attrs().Parent != netlink.HANDLE_INGRESS {
+			continue
+		}
+
+		if err := netlink.QdiscDel(qdisc); err != nil && !os.IsNotExist(err) && err != syscall.ENOENT {
+			return errors.Wrapf(err, "failed to delete ingress qdisc of %s", link.Attrs().Name)
+		}
+	}
+
+	return nil
+}
+
+// removeRate removes the htb qdisc set by SetRate if any
+func removeRate(link netlink.Link) error {
+	htb, err := rootQdisc(link)
+	if err != nil || htb == nil {
+		return err
+	}
+
+	if err := netlink.QdiscDel(htb); err != nil && !os.IsNotExist(err) && err != syscall.ENOENT {
+		return errors.Wrapf(err, "failed to delete htb qdisc of %s", link.Attrs().Name)
+	}
+
+	return nil
+}
+
+// Rate returns the rate limit of the traffic sent by link in Kbit/s, 0 if
+// the rate is not limited. It must be called in the network namespace of link
+func Rate(link netlink.Link) (uint64, error) {
+	htb, err := rootQdisc(link)
+	if err != nil || htb == nil {
+		return 0, err
+	}
+
+	classes, err := netlink.ClassList(link, rootHandle)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to list htb classes of %s", link.Attrs().Name)
+	}
+
+	for _, class := range classes {
+		htbClass, ok := class.(*netlink.HtbClass)
+		if !ok || htbClass.Handle != classHandle {
+			continue
+		}
+
+		// the rate of the class is kept in bytes/s
+		return htbClass.Rate * 8 / 1000, nil
+	}
+
+	return 0, nil
+}
+
+// rootQdisc returns the htb qdisc set by SetRate, nil if there is none
+func rootQdisc(link netlink.Link) (netlink.Qdisc, error) {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list qdiscs of %s", link.Attrs().Name)
+	}
+
+	for _, qdisc := range qdiscs {
+		attrs := qdisc.Attrs()
+		if attrs.Parent == netlink.HANDLE_ROOT && attrs.Handle == rootHandle && qdisc.Type() == "htb" {
+			return qdisc, nil
+		}
+	}
+
+	return nil, nil
+}
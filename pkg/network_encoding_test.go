@@ -93,3 +93,57 @@ func TestEncodeDecode(t *testing.T) {
 	assert.Equal(t, ePeer.AllowedIPs, aPeer.AllowedIPs)
 	assert.Equal(t, ePeer.WGPublicKey, aPeer.WGPublicKey)
 }
+
+func TestFirewallUnmarshal(t *testing.T) {
+	input := `{
+		"ingress": [
+			{"action": "accept", "cidr": "192.168.1.0/24", "protocol": "tcp", "port": 22},
+			{"action": "accept", "protocol": "icmp"}
+		],
+		"egress": [
+			{"action": "drop", "cidr": "10.0.0.0/8"}
+		],
+		"ingress_policy": "drop"
+	}`
+
+	var fw Firewall
+	err := json.Unmarshal([]byte(input), &fw)
+	require.NoError(t, err)
+	require.NoError(t, fw.Validate())
+
+	assert := assert.New(t)
+	assert.Len(fw.Ingress, 2)
+	assert.Equal("192.168.1.0/24", fw.Ingress[0].CIDR.String())
+	assert.Equal(uint16(22), fw.Ingress[0].Port)
+	assert.True(fw.Ingress[1].CIDR.Nil())
+	assert.Equal(FirewallDrop, fw.IngressPolicy)
+	assert.Equal(FirewallAction(""), fw.EgressPolicy)
+
+	invalid := []Firewall{
+		{IngressPolicy: "reject"},
+		{Ingress: []FirewallRule{{Action: "log"}}},
+		{Egress: []FirewallRule{{Action: FirewallDrop, Protocol: "sctp"}}},
+		{Egress: []FirewallRule{{Action: FirewallDrop, Protocol: "icmp", Port: 8}}},
+	}
+	for _, fw := range invalid {
+		assert.Error(fw.Validate(), "%+v", fw)
+	}
+}
+
+func TestBandwidthUnmarshal(t *testing.T) {
+	input := `{"name": "test", "bandwidth": {"ingress": 10000, "egress": 2000}}`
+
+	var nr NetResource
+	err := json.Unmarshal([]byte(input), &nr)
+	require.NoError(t, err)
+	require.NoError(t, nr.Bandwidth.Validate())
+
+	assert := assert.New(t)
+	assert.Equal(uint64(10000), nr.Bandwidth.Ingress)
+	assert.Equal(uint64(2000), nr.Bandwidth.Egress)
+
+	bw := Bandwidth{Ingress: MaxBandwidth + 1}
+	assert.Error(bw.Validate())
+	bw = Bandwidth{Egress: MaxBandwidth + 1}
+	assert.Error(bw.Validate())
+}
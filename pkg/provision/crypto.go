@@ -2,6 +2,8 @@ package provision
 
 import (
 	"bytes"
+	"encoding/json"
+	"strconv"
 
 	"github.com/pkg/errors"
 
@@ -10,31 +12,65 @@ import (
 	"golang.org/x/crypto/ed25519"
 )
 
-// Sign creates a signature from all the field of the reservation
-// object and fill the Signature field
-func (r *Reservation) Sign(privateKey ed25519.PrivateKey) error {
+// KeyResolver is used by the engine to find the public key
+// of the user that created a reservation, so the reservation signature
+// can be verified before the reservation is deployed
+type KeyResolver interface {
+	PublicKey(user string) (ed25519.PublicKey, error)
+}
+
+// IdentityKeyResolver is a KeyResolver for users identified
+// by the base58 encoding of their public key
+type IdentityKeyResolver struct{}
+
+// PublicKey implements KeyResolver
+func (IdentityKeyResolver) PublicKey(user string) (ed25519.PublicKey, error) {
+	return crypto.KeyFromID(pkg.StrIdentifier(user))
+}
+
+// SigningBytes returns the canonical encoding of the reservation
+// that is signed by the user. If the source of the reservation set the
+// Challenge field, it is returned as is.
+// The canonical encoding is made of the node ID, user, type, reference, and
+// the compacted json data of the reservation
+func (r *Reservation) SigningBytes() ([]byte, error) {
+	if len(r.Challenge) != 0 {
+		return r.Challenge, nil
+	}
+
 	buf := &bytes.Buffer{}
 	//FIME: Since the ID is only set when the reservation is sent to bcdb
 	// we cannot use it in the signature. This is a problem
 
-	_, err := buf.WriteString(r.NodeID)
-	if err != nil {
-		return err
+	for _, field := range []string{r.NodeID, r.User, string(r.Type), r.Reference} {
+		// prefix each field with its length so fields boundaries
+		// can't be moved around
+		if _, err := buf.WriteString(strconv.Itoa(len(field))); err != nil {
+			return nil, err
+		}
+		if _, err := buf.WriteString(field); err != nil {
+			return nil, err
+		}
 	}
-	_, err = buf.WriteString(r.User)
-	if err != nil {
-		return err
-	}
-	_, err = buf.WriteString(string(r.Type))
-	if err != nil {
-		return err
+
+	if len(r.Data) != 0 {
+		if err := json.Compact(buf, r.Data); err != nil {
+			return nil, errors.Wrap(err, "invalid reservation data")
+		}
 	}
-	_, err = buf.Write(r.Data)
+
+	return buf.Bytes(), nil
+}
+
+// Sign creates a signature from all the field of the reservation
+// object and fill the Signature field
+func (r *Reservation) Sign(privateKey ed25519.PrivateKey) error {
+	b, err := r.SigningBytes()
 	if err != nil {
 		return err
 	}
 
-	signature, err := crypto.Sign(privateKey, buf.Bytes())
+	signature, err := crypto.Sign(privateKey, b)
 	if err != nil {
 		return err
 	}
@@ -43,33 +79,21 @@ func (r *Reservation) Sign(privateKey ed25519.PrivateKey) error {
 }
 
 // Verify verifies the signature of the reservation
-func Verify(r *Reservation) error {
-	buf := &bytes.Buffer{}
-	//FIME: Since the ID is only set when the reservation is sent to bcdb
-	// we cannot use it in the signature. This is a problem
-
-	_, err := buf.WriteString(r.NodeID)
-	if err != nil {
-		return err
+// using the public key of the reservation user returned by the resolver
+func Verify(r *Reservation, resolver KeyResolver) error {
+	if len(r.Signature) == 0 {
+		return errors.New("reservation is not signed")
 	}
 
-	_, err = buf.WriteString(r.User)
-	if err != nil {
-		return err
-	}
-	_, err = buf.WriteString(string(r.Type))
-	if err != nil {
-		return err
-	}
-	_, err = buf.Write(r.Data)
+	b, err := r.SigningBytes()
 	if err != nil {
 		return err
 	}
 
-	publicKey, err := crypto.KeyFromID(pkg.StrIdentifier(r.User))
+	publicKey, err := resolver.PublicKey(r.User)
 	if err != nil {
-		return errors.Wrap(err, "failed to extract public key from user ID")
+		return errors.Wrapf(err, "failed to get public key of user %s", r.User)
 	}
 
-	return crypto.Verify(publicKey, buf.Bytes(), r.Signature)
+	return crypto.Verify(publicKey, b, r.Signature)
 }
@@ -1,48 +1,105 @@
 package provision
 
-// func TestVerifySignature(t *testing.T) {
-// 	keyPair, err := identity.GenerateKeyPair()
-// 	require.NoError(t, err)
-
-// 	data, err := json.Marshal(Volume{
-// 		Type: SSDDiskType,
-// 		Size: 20,
-// 	})
-// 	require.NoError(t, err)
-
-// 	r := &Reservation{
-// 		ID:     "reservationID",
-// 		NodeID: "node1",
-// 		User:   keyPair.Identity(),
-// 		Type:   ContainerReservation,
-// 		Data:   data,
-// 	}
-
-// 	err = r.Sign(keyPair.PrivateKey)
-// 	require.NoError(t, err)
-
-// 	err = Verify(r)
-// 	assert.NoError(t, err)
-
-// 	validSignature := make([]byte, len(r.Signature))
-// 	copy(validSignature, r.Signature)
-
-// 	// corrupt the signature
-// 	_, err = rand.Read(r.Signature)
-// 	require.NoError(t, err)
-
-// 	err = Verify(r)
-// 	assert.Error(t, err)
-
-// 	// restore signature
-// 	copy(r.Signature, validSignature)
-
-// 	// sanity test
-// 	err = Verify(r)
-// 	require.NoError(t, err)
-
-// 	// change the reservation
-// 	r.User = "attackerID"
-// 	err = Verify(r)
-// 	assert.Error(t, err)
-// }
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ed25519"
+)
+
+type testResolver map[string]ed25519.PublicKey
+
+func (t testResolver) PublicKey(user string) (ed25519.PublicKey, error) {
+	key, ok := t[user]
+	if !ok {
+		return nil, fmt.Errorf("unknown user %s", user)
+	}
+	return key, nil
+}
+
+func TestVerifySignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	resolver := testResolver{"user1": publicKey}
+
+	r := &Reservation{
+		ID:     "reservationID",
+		NodeID: "node1",
+		User:   "user1",
+		Type:   "volume",
+		Data:   json.RawMessage(`{"type": "SSD", "size": 20}`),
+	}
+
+	err = Verify(r, resolver)
+	assert.Error(t, err, "unsigned reservation must not verify")
+
+	err = r.Sign(privateKey)
+	require.NoError(t, err)
+
+	err = Verify(r, resolver)
+	assert.NoError(t, err)
+
+	// the encoding of the data is canonical
+	r.Data = json.RawMessage(`{"type":"SSD","size":20}`)
+	err = Verify(r, resolver)
+	assert.NoError(t, err)
+
+	validSignature := make([]byte, len(r.Signature))
+	copy(validSignature, r.Signature)
+
+	// corrupt the signature
+	_, err = rand.Read(r.Signature)
+	require.NoError(t, err)
+
+	err = Verify(r, resolver)
+	assert.Error(t, err)
+
+	// restore signature
+	copy(r.Signature, validSignature)
+
+	// sanity test
+	err = Verify(r, resolver)
+	require.NoError(t, err)
+
+	// change the reservation
+	r.Data = json.RawMessage(`{"type":"SSD","size":200}`)
+	err = Verify(r, resolver)
+	assert.Error(t, err)
+	r.Data = json.RawMessage(`{"type":"SSD","size":20}`)
+
+	// unknown user
+	r.User = "attackerID"
+	err = Verify(r, resolver)
+	assert.Error(t, err)
+}
+
+func TestVerifyChallenge(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	r := &Reservation{
+		User:      "user1",
+		Type:      "volume",
+		Challenge: []byte("explorer challenge"),
+	}
+	r.Signature = ed25519.Sign(privateKey, r.Challenge)
+
+	err = Verify(r, testResolver{"user1": publicKey})
+	assert.NoError(t, err)
+
+	// the challenge is kept by the reservation cache
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+
+	var cached Reservation
+	require.NoError(t, json.Unmarshal(data, &cached))
+	assert.Equal(t, r.Challenge, cached.Challenge)
+
+	err = Verify(&cached, testResolver{"user1": publicKey})
+	assert.NoError(t, err)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/threefoldtech/zos/pkg"
@@ -17,14 +18,32 @@ import (
 // Engine is the core of this package
 // The engine is responsible to manage provision and decomission of workloads on the system
 type Engine struct {
-	nodeID         string
-	source         ReservationSource
-	cache          ReservationCache
-	feedback       Feedbacker
-	provisioners   map[ReservationType]ProvisionerFunc
-	decomissioners map[ReservationType]DecomissionerFunc
-	signer         Signer
-	statser        Statser
+	nodeID            string
+	source            ReservationSource
+	cache             ReservationCache
+	feedback          Feedbacker
+	provisioners      map[ReservationType]ProvisionerFunc
+	decomissioners    map[ReservationType]DecomissionerFunc
+	updaters          map[ReservationType]UpdaterFunc
+	checkers          map[ReservationType]CheckerFunc
+	suspenders        map[ReservationType]SuspenderFunc
+	resumers          map[ReservationType]SuspenderFunc
+	prefetchers       map[ReservationType]PrefetcherFunc
+	watcher           Watcher
+	signer            Signer
+	users             KeyResolver
+	statser           Statser
+	retries           RetryQueue
+	workers           int
+	order             map[ReservationType]int
+	reconcileInterval time.Duration
+
+	// prefetches queues the reservations to prefetch
+	prefetches chan *Reservation
+
+	// notifyM serializes the notifications sent to the feedback
+	// so an older notification is never sent after a newer one
+	notifyM sync.Mutex
 }
 
 // EngineOps are the configuration of the engine
@@ -45,30 +64,96 @@ type EngineOps struct {
 	// Decomissioners contains the opposite function from Provisioners
 	// they are used to decomission workloads from the system
 	Decomissioners map[ReservationType]DecomissionerFunc
+	// Updaters contains the functions used to update a workload already
+	// provisioned on the system, without having to decomission it first
+	Updaters map[ReservationType]UpdaterFunc
+	// Checkers contains the functions used to verify that the workloads
+	// provisioned on the system are still in the state described by their reservation.
+	// The engine periodically checks all the cached reservations, workloads that
+	// drifted are redeployed, and an error result is sent if that fails
+	Checkers map[ReservationType]CheckerFunc
+	// Suspenders contains the functions used to suspend the workloads of the
+	// reservations that have the Suspended flag set. Suspended workloads keep their
+	// data and are reported with an ok result that has its Suspended flag set
+	Suspenders map[ReservationType]SuspenderFunc
+	// Resumers contains the opposite function from Suspenders, they are used
+	// once the Suspended flag of a reservation is unset
+	Resumers map[ReservationType]SuspenderFunc
+	// Prefetchers contains the functions used to download ahead of time
+	// what the workloads need, like the flist of a container, as soon as
+	// their reservation is received and while it waits to be provisioned
+	Prefetchers map[ReservationType]PrefetcherFunc
+	// Watcher streams the state changes of the provisioned workloads, like a container
+	// that keeps crashing. Each change is sent to the Feedback as an updated result
+	// of the reservation. If nil, only the result of the provisioning is sent
+	Watcher Watcher
+	// ReconcileInterval is the time between two checks of the provisioned workloads
+	// if not set, defaults to 10 minutes
+	ReconcileInterval time.Duration
 	// Signer is used to authenticate the result send to the source
 	Signer Signer
+	// Users is used to find the public key of the users to verify
+	// the signature of the reservations before they are provisioned.
+	// Reservations that fail verification are rejected and an error result is sent
+	// to the Feedback. If nil, signatures are not verified
+	Users KeyResolver
 	// Statser is responsible to keep track of how much workloads and resource units
 	// are reserved on the system running the engine
 	// After each provision/decomission the engine sends statistics update to the staster
 	Statser Statser
+	// Retries is used to persist the operations that failed so they
+	// are retried later with an increasing delay. Reservations that failed
+	// to be provisioned are retried a limited number of times, results and deleted
+	// notifications that could not be sent are retried until they succeed.
+	// If nil, failed operations are not retried
+	Retries RetryQueue
+	// Workers is the maximum number of reservations processed at the same time
+	// if not set, the engine process one reservation at a time
+	Workers int
+	// ProvisionOrder is a map with each primitive type as key. It is used by the engine
+	// to make sure workloads that depend on others are only processed after
+	// the workloads they depend on. Lower values are processed first
+	ProvisionOrder map[ReservationType]int
 }
 
 // New creates a new engine. Once started, the engine
 // will continue processing all reservations from the reservation source
 // and try to apply them.
-// the default implementation is a single threaded worker. so it process
-// one reservation at a time. On error, the engine will log the error. and
-// continue to next reservation.
+// the engine uses a pool of opts.Workers workers to process reservations
+// concurrently. Operations on the same reservation are never executed at the
+// same time and a reservation only starts once all the reservations of the same
+// user with a lower provision order are processed.
+// On error, the engine will log the error. and continue to next reservation.
 func New(opts EngineOps) *Engine {
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	if opts.ReconcileInterval <= 0 {
+		opts.ReconcileInterval = defaultReconcileInterval
+	}
+
 	return &Engine{
-		nodeID:         opts.NodeID,
-		source:         opts.Source,
-		cache:          opts.Cache,
-		feedback:       opts.Feedback,
-		provisioners:   opts.Provisioners,
-		decomissioners: opts.Decomissioners,
-		signer:         opts.Signer,
-		statser:        opts.Statser,
+		nodeID:            opts.NodeID,
+		source:            opts.Source,
+		cache:             opts.Cache,
+		feedback:          opts.Feedback,
+		provisioners:      opts.Provisioners,
+		decomissioners:    opts.Decomissioners,
+		updaters:          opts.Updaters,
+		checkers:          opts.Checkers,
+		suspenders:        opts.Suspenders,
+		resumers:          opts.Resumers,
+		prefetchers:       opts.Prefetchers,
+		watcher:           opts.Watcher,
+		signer:            opts.Signer,
+		users:             opts.Users,
+		statser:           opts.Statser,
+		retries:           opts.Retries,
+		workers:           opts.Workers,
+		order:             opts.ProvisionOrder,
+		reconcileInterval: opts.ReconcileInterval,
+		prefetches:        make(chan *Reservation, prefetchQueueSize),
 	}
 }
 
@@ -83,48 +168,150 @@ func (e *Engine) Run(ctx context.Context) error {
 
 	cReservation := e.source.Reservations(ctx)
 
+	var (
+		wg      sync.WaitGroup
+		jobs    = make(chan job)
+		sched   = newScheduler(e.order)
+		retried = make(chan *Reservation)
+		drifted = make(chan *Reservation)
+	)
+
+	if e.retries != nil {
+		go e.retryLoop(ctx, retried)
+	}
+
+	if len(e.checkers) != 0 {
+		go e.reconcileLoop(ctx, drifted)
+	}
+
+	if len(e.prefetchers) != 0 {
+		for i := 0; i < maxPrefetches; i++ {
+			go e.prefetchWorker(ctx)
+		}
+	}
+
+	var changes <-chan StateChange
+	if e.watcher != nil {
+		changes = e.watcher.Watch(ctx)
+	}
+
+	wg.Add(e.workers)
+	for i := 0; i < e.workers; i++ {
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				sched.acquire(j)
+				switch {
+				case j.change != nil:
+					e.stateChanged(ctx, j.reservation, j.change)
+				case j.redeploy:
+					e.redeploy(ctx, j.reservation)
+				default:
+					e.handle(ctx, j.reservation)
+				}
+				sched.release(j)
+			}
+		}()
+	}
+
+	defer func() {
+		close(jobs)
+		wg.Wait()
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			log.Info().Msg("provision engine context done, exiting")
 			return nil
 
-		case reservation, ok := <-cReservation:
+		case reservation := <-drifted:
+			j := sched.add(reservation)
+			j.redeploy = true
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				log.Info().Msg("provision engine context done, exiting")
+				return nil
+			}
+
+		case change, ok := <-changes:
 			if !ok {
-				log.Info().Msg("reservation source is emptied. stopping engine")
+				changes = nil
+				continue
+			}
+
+			reservation, err := e.cache.Get(change.ID)
+			if err != nil {
+				log.Debug().Str("id", change.ID).Msg("state change of unknown reservation, skipping")
+				continue
+			}
+
+			j := sched.add(reservation)
+			j.change = &change
+			select {
+			case jobs <- j:
+			case <-ctx.Done():
+				log.Info().Msg("provision engine context done, exiting")
 				return nil
 			}
 
-			expired := reservation.Expired()
-			slog := log.With().
-				Str("id", string(reservation.ID)).
-				Str("type", string(reservation.Type)).
-				Str("duration", fmt.Sprintf("%v", reservation.Duration)).
-				Str("tag", reservation.Tag.String()).
-				Bool("to-delete", reservation.ToDelete).
-				Bool("expired", expired).
-				Logger()
-
-			if expired || reservation.ToDelete {
-				slog.Info().Msg("start decommissioning reservation")
-				if err := e.decommission(ctx, reservation); err != nil {
-					log.Error().Err(err).Msgf("failed to decommission reservation %s", reservation.ID)
-					continue
-				}
-			} else {
-				slog.Info().Msg("start provisioning reservation")
-				if err := e.provision(ctx, reservation); err != nil {
-					log.Error().Err(err).Msgf("failed to provision reservation %s", reservation.ID)
-					continue
-				}
+		case reservation := <-retried:
+			select {
+			case jobs <- sched.add(reservation):
+			case <-ctx.Done():
+				log.Info().Msg("provision engine context done, exiting")
+				return nil
+			}
+
+		case reservation, ok := <-cReservation:
+			if !ok {
+				log.Info().Msg("reservation source is emptied. stopping engine")
+				return nil
 			}
 
-			if err := e.updateStats(); err != nil {
-				log.Error().Err(err).Msg("failed to updated the capacity counters")
+			e.prefetch(ctx, reservation)
+			select {
+			case jobs <- sched.add(reservation):
+			case <-ctx.Done():
+				log.Info().Msg("provision engine context done, exiting")
+				return nil
 			}
+		}
+	}
+}
 
+// handle provisions or decommissions a single reservation
+func (e *Engine) handle(ctx context.Context, reservation *Reservation) {
+	expired := reservation.Expired()
+	slog := log.With().
+		Str("id", string(reservation.ID)).
+		Str("type", string(reservation.Type)).
+		Str("duration", fmt.Sprintf("%v", reservation.Duration)).
+		Str("tag", reservation.Tag.String()).
+		Bool("to-delete", reservation.ToDelete).
+		Bool("expired", expired).
+		Logger()
+
+	if expired || reservation.ToDelete {
+		slog.Info().Msg("start decommissioning reservation")
+		if err := e.decommission(ctx, reservation); err != nil {
+			log.Error().Err(err).Msgf("failed to decommission reservation %s", reservation.ID)
+			return
+		}
+	} else {
+		slog.Info().Msg("start provisioning reservation")
+		if err := e.provision(ctx, reservation); err != nil {
+			log.Error().Err(err).Msgf("failed to provision reservation %s", reservation.ID)
+			return
 		}
 	}
+
+	e.retryDone(reservation.ID)
+
+	if err := e.updateStats(); err != nil {
+		log.Error().Err(err).Msg("failed to updated the capacity counters")
+	}
 }
 
 func (e *Engine) provision(ctx context.Context, r *Reservation) error {
@@ -132,6 +319,14 @@ func (e *Engine) provision(ctx context.Context, r *Reservation) error {
 		return errors.Wrapf(err, "failed validation of reservation")
 	}
 
+	if err := e.verify(r); err != nil {
+		log.Warn().Err(err).Str("id", r.ID).Msg("verification of reservation signature failed")
+		if replyErr := e.reply(ctx, r, err, nil); replyErr != nil {
+			log.Error().Err(replyErr).Msg("failed to send result to BCDB")
+		}
+		return err
+	}
+
 	fn, ok := e.provisioners[r.Type]
 	if !ok {
 		return fmt.Errorf("type of reservation not supported: %s", r.Type)
@@ -143,8 +338,24 @@ func (e *Engine) provision(ctx context.Context, r *Reservation) error {
 		}
 	}
 
-	_, err := e.cache.Get(r.ID)
+	cached, err := e.cache.Get(r.ID)
 	if err == nil {
+		if !bytes.Equal(cached.Data, r.Data) {
+			if cached.Suspended || r.Suspended {
+				err := fmt.Errorf("reservation %s is suspended, it cannot be updated", r.ID)
+				if replyErr := e.reply(ctx, r, err, nil); replyErr != nil {
+					log.Error().Err(replyErr).Msg("failed to send result to BCDB")
+				}
+				return err
+			}
+
+			return e.update(ctx, cached, r)
+		}
+
+		if cached.Suspended != r.Suspended {
+			return e.suspend(ctx, r)
+		}
+
 		log.Info().Str("id", r.ID).Msg("reservation already deployed")
 		return nil
 	}
@@ -157,6 +368,11 @@ func (e *Engine) provision(ctx context.Context, r *Reservation) error {
 	}
 
 	result, err := fn(ctx, r)
+	if err == nil && r.Suspended {
+		// the workload is deployed first so it's ready once resumed
+		result, err = e.applySuspend(ctx, r)
+	}
+
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -175,6 +391,7 @@ func (e *Engine) provision(ctx context.Context, r *Reservation) error {
 	}
 
 	if err != nil {
+		e.retryLater(r, err)
 		return err
 	}
 
@@ -189,6 +406,80 @@ func (e *Engine) provision(ctx context.Context, r *Reservation) error {
 	return nil
 }
 
+// verify checks the reservation has been signed by its user
+func (e *Engine) verify(r *Reservation) error {
+	if e.users == nil {
+		return nil
+	}
+
+	if err := Verify(r, e.users); err != nil {
+		return errors.Wrapf(err, "verification of reservation %s signature failed", r.ID)
+	}
+
+	return nil
+}
+
+func (e *Engine) update(ctx context.Context, old, r *Reservation) error {
+	log.Info().Str("id", r.ID).Msg("reservation content changed, updating workload")
+
+	var (
+		result interface{}
+		err    error
+	)
+
+	fn, ok := e.updaters[r.Type]
+	if !ok {
+		err = fmt.Errorf("update of reservation type %s not supported", r.Type)
+	} else {
+		// same as for provision, the reference is used as workload ID
+		// so workloads migrated from an old reservation keep running
+		realID := r.ID
+		if r.Reference != "" {
+			r.ID = r.Reference
+			old.ID = r.Reference
+		}
+
+		result, err = fn(ctx, old, r)
+		r.ID = realID
+		old.ID = realID
+	}
+
+	if err != nil {
+		log.Error().Err(err).Str("id", r.ID).Msg("failed to apply update")
+	} else {
+		log.Info().Str("result", fmt.Sprintf("%v", result)).Msg("workload updated")
+	}
+
+	if replyErr := e.reply(ctx, r, err, result); replyErr != nil {
+		log.Error().Err(replyErr).Msg("failed to send result to BCDB")
+	}
+
+	if err != nil {
+		if ok {
+			e.retryLater(r, err)
+		}
+		return err
+	}
+
+	if err := e.cache.Remove(r.ID); err != nil {
+		return errors.Wrapf(err, "failed to remove old version of reservation %s from cache", r.ID)
+	}
+
+	if err := e.cache.Add(r); err != nil {
+		return errors.Wrapf(err, "failed to cache reservation %s locally", r.ID)
+	}
+
+	if err := e.statser.Decrement(old); err != nil {
+		log.Err(err).Str("reservation_id", r.ID).Msg("failed to decrement workloads statistics")
+	}
+
+	if err := e.statser.Increment(r); err != nil {
+		log.Err(err).Str("reservation_id", r.ID).Msg("failed to increment workloads statistics")
+	}
+
+	return nil
+}
+
 func (e *Engine) decommission(ctx context.Context, r *Reservation) error {
 	fn, ok := e.decomissioners[r.Type]
 	if !ok {
@@ -202,7 +493,7 @@ func (e *Engine) decommission(ctx context.Context, r *Reservation) error {
 
 	if !exists {
 		log.Info().Str("id", r.ID).Msg("reservation not provisioned, no need to decomission")
-		if err := e.feedback.Deleted(e.nodeID, r.ID); err != nil {
+		if err := e.notify(&RetryItem{Kind: RetryDeleted, ID: r.ID}); err != nil {
 			log.Error().Err(err).Str("id", r.ID).Msg("failed to mark reservation as deleted")
 		}
 		return nil
@@ -230,7 +521,7 @@ func (e *Engine) decommission(ctx context.Context, r *Reservation) error {
 		log.Err(err).Str("reservation_id", r.ID).Msg("failed to decrement workloads statistics")
 	}
 
-	if err := e.feedback.Deleted(e.nodeID, r.ID); err != nil {
+	if err := e.notify(&RetryItem{Kind: RetryDeleted, ID: r.ID}); err != nil {
 		return errors.Wrap(err, "failed to mark reservation as deleted")
 	}
 
@@ -250,6 +541,7 @@ func (e *Engine) reply(ctx context.Context, r *Reservation, err error, info inte
 		result.State = StateError
 	} else {
 		result.State = StateOk
+		result.Suspended = r.Suspended
 	}
 
 	br, err := json.Marshal(info)
@@ -262,7 +554,7 @@ func (e *Engine) reply(ctx context.Context, r *Reservation, err error, info inte
 		return err
 	}
 
-	return e.feedback.Feedback(e.nodeID, result)
+	return e.notify(&RetryItem{Kind: RetryFeedback, ID: r.ID, Result: result})
 }
 
 func (e *Engine) signResult(result *Result) error {
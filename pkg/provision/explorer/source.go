@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/rs/zerolog/log"
 
@@ -42,8 +44,9 @@ func (r *Poller) Poll(nodeID pkg.Identifier, from uint64) ([]*provision.Reservat
 	}
 
 	result := make([]*provision.Reservation, 0, len(list))
+	pools := make(map[int64]bool)
 	for _, wl := range list {
-		r, err := r.inputConv(wl)
+		res, err := r.inputConv(wl)
 		if err != nil {
 			if errors.Is(err, primitives.ErrUnsupportedWorkload) {
 				log.Warn().Err(err).Msgf("received unsupported workload, skipping")
@@ -52,7 +55,12 @@ func (r *Poller) Poll(nodeID pkg.Identifier, from uint64) ([]*provision.Reservat
 			return nil, 0, err
 		}
 
-		result = append(result, r)
+		res.Suspended, err = r.poolEmpty(pools, wl.GetPoolID())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		result = append(result, res)
 	}
 
 	if r.provisionOrder != nil {
@@ -64,3 +72,26 @@ func (r *Poller) Poll(nodeID pkg.Identifier, from uint64) ([]*provision.Reservat
 
 	return result, lastID, nil
 }
+
+// poolEmpty checks if the capacity pool of a workload ran out of capacity,
+// the workloads of an empty pool are suspended until the pool is refilled.
+// Workloads from before the capacity pools have no pool and are never suspended.
+// The state of the pools is cached in pools for the duration of a poll
+func (r *Poller) poolEmpty(pools map[int64]bool, poolID int64) (bool, error) {
+	if poolID == 0 {
+		return false, nil
+	}
+
+	if empty, ok := pools[poolID]; ok {
+		return empty, nil
+	}
+
+	pool, err := r.wl.PoolGet(strconv.FormatInt(poolID, 10))
+	if err != nil {
+		return false, fmt.Errorf("error while retrieving capacity pool %d from explorer: %w", poolID, err)
+	}
+
+	empty := pool.EmptyAt <= time.Now().Unix()
+	pools[poolID] = empty
+	return empty, nil
+}
@@ -2,6 +2,7 @@ package explorer
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -16,6 +17,7 @@ import (
 
 type clientMock struct {
 	workloads []workloads.Workloader
+	pools     map[string]types.Pool
 }
 
 func (c *clientMock) Create(reservation workloads.Workloader) (resp wrklds.ReservationCreateResponse, err error) {
@@ -52,7 +54,7 @@ func (c *clientMock) PoolCreate(reservation types.Reservation) (resp wrklds.Capa
 	return
 }
 func (c *clientMock) PoolGet(poolID string) (result types.Pool, err error) {
-	return
+	return c.pools[poolID], nil
 }
 func (c *clientMock) PoolsGetByOwner(ownerID string) (result []types.Pool, err error) {
 	return
@@ -86,3 +88,38 @@ func TestSkipUnsupportedType(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1, len(result))
 }
+
+func TestSuspendEmptyPool(t *testing.T) {
+	container := func(pool int64) workloads.Workloader {
+		return &workloads.Container{ReservationInfo: workloads.ReservationInfo{
+			WorkloadType: workloads.WorkloadTypeContainer,
+			PoolId:       pool,
+		}}
+	}
+
+	client := &clientMock{
+		workloads: []workloads.Workloader{
+			container(0),
+			container(1),
+			container(2),
+			container(1),
+		},
+		pools: map[string]types.Pool{
+			"1": {EmptyAt: time.Now().Add(-time.Hour).Unix()},
+			"2": {EmptyAt: time.Now().Add(time.Hour).Unix()},
+		},
+	}
+
+	p := &Poller{
+		wl:        client,
+		inputConv: primitives.WorkloadToProvisionType,
+	}
+
+	result, _, err := p.Poll(pkg.StrIdentifier(""), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(result))
+	assert.False(t, result[0].Suspended)
+	assert.True(t, result[1].Suspended)
+	assert.False(t, result[2].Suspended)
+	assert.True(t, result[3].Suspended)
+}
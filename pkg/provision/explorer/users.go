@@ -0,0 +1,61 @@
+package explorer
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/threefoldtech/tfexplorer/client"
+	"github.com/threefoldtech/tfexplorer/schema"
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"golang.org/x/crypto/ed25519"
+)
+
+// KeyResolver is an implementation of the provision.KeyResolver
+// that gets the public key of the users from the TFExplorer phonebook.
+// Keys are cached in memory once retrieved
+type KeyResolver struct {
+	phonebook client.Phonebook
+
+	m    sync.RWMutex
+	keys map[string]ed25519.PublicKey
+}
+
+// NewKeyResolver creates a KeyResolver
+func NewKeyResolver(cl *client.Client) *KeyResolver {
+	return &KeyResolver{
+		phonebook: cl.Phonebook,
+		keys:      make(map[string]ed25519.PublicKey),
+	}
+}
+
+// PublicKey implements provision.KeyResolver
+func (k *KeyResolver) PublicKey(user string) (ed25519.PublicKey, error) {
+	k.m.RLock()
+	key, ok := k.keys[user]
+	k.m.RUnlock()
+	if ok {
+		return key, nil
+	}
+
+	id, err := strconv.ParseInt(user, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid user id '%s': %w", user, err)
+	}
+
+	u, err := k.phonebook.Get(schema.ID(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user %s from explorer: %w", user, err)
+	}
+
+	key, err = crypto.KeyFromHex(u.Pubkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key for user %s: %w", user, err)
+	}
+
+	k.m.Lock()
+	k.keys[user] = key
+	k.m.Unlock()
+
+	return key, nil
+}
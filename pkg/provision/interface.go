@@ -21,6 +21,40 @@ type ProvisionerFunc func(ctx context.Context, reservation *Reservation) (interf
 // DecomissionerFunc is the function called by the Engine to decomission a workload
 type DecomissionerFunc func(ctx context.Context, reservation *Reservation) error
 
+// CheckerFunc is the function called by the Engine to verify that a provisioned workload
+// is still in the state described by the reservation. It returns a non nil error
+// describing the drift if the workload is missing or broken
+type CheckerFunc func(ctx context.Context, reservation *Reservation) error
+
+// UpdaterFunc is the function called by the Engine to update a workload that is already
+// provisioned. old is the reservation currently deployed and reservation the new version of it
+type UpdaterFunc func(ctx context.Context, old, reservation *Reservation) (interface{}, error)
+
+// SuspenderFunc is the function called by the Engine to suspend or resume a provisioned
+// workload. Suspended workloads keep their data but don't consume any compute capacity
+type SuspenderFunc func(ctx context.Context, reservation *Reservation) (interface{}, error)
+
+// PrefetcherFunc is the function called by the Engine as soon as a reservation is
+// received, to download what its workload needs while the reservation is queued
+type PrefetcherFunc func(ctx context.Context, reservation *Reservation) error
+
+// StateChange is a change of the state of a provisioned workload
+// detected by the node after the workload was provisioned
+type StateChange struct {
+	// ID of the reservation of the workload
+	ID string
+	// Err describes why the workload is not working anymore
+	// it is nil once the workload is working again
+	Err error
+	// Result is the new result data of the workload
+	Result interface{}
+}
+
+// Watcher is a source of state changes of the provisioned workloads
+type Watcher interface {
+	Watch(ctx context.Context) <-chan StateChange
+}
+
 // ReservationConverterFunc is used to convert from the explorer workloads type into the
 // internal Reservation type
 type ReservationConverterFunc func(w workloads.Workloader) (*Reservation, error)
@@ -35,9 +69,21 @@ type ReservationCache interface {
 	Get(id string) (*Reservation, error)
 	Remove(id string) error
 	Exists(id string) (bool, error)
+	List() ([]*Reservation, error)
 	Sync(Statser) error
 }
 
+// RetryQueue define the interface to durably store
+// the operations the engine failed to complete, so they
+// can be retried later, even after a restart
+type RetryQueue interface {
+	// Push adds the item to the queue, replacing any item with the same key
+	Push(item *RetryItem) error
+	Get(key string) (*RetryItem, error)
+	Remove(key string) error
+	List() ([]*RetryItem, error)
+}
+
 // Feedbacker defines the method that needs to be implemented
 // to send the provision result to BCDB
 type Feedbacker interface {
@@ -0,0 +1,88 @@
+package local
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/threefoldtech/tfexplorer/models/generated/directory"
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+const statsFile = "stats.json"
+
+// Feedback is an implementation of the provision.Feedbacker
+// that writes the results of the reservations as json files in a directory.
+// The result of a reservation is written to <root>/<reservation id>.json
+// and the node statistics to <root>/stats.json
+type Feedback struct {
+	root string
+}
+
+// Stats is the content of the stats file written by Feedback
+type Stats struct {
+	NodeID    string                   `json:"node_id"`
+	Updated   time.Time                `json:"updated"`
+	Workloads directory.WorkloadAmount `json:"workloads"`
+	Resources directory.ResourceAmount `json:"resources"`
+}
+
+// NewFeedback creates a local Feedback that writes results in root
+func NewFeedback(root string) (*Feedback, error) {
+	if err := os.MkdirAll(root, 0770); err != nil {
+		return nil, err
+	}
+
+	return &Feedback{root: root}, nil
+}
+
+// Feedback implements provision.Feedbacker
+func (f *Feedback) Feedback(nodeID string, r *provision.Result) error {
+	return f.write(r.ID+reservationExt, r)
+}
+
+// Deleted implements provision.Feedbacker
+func (f *Feedback) Deleted(nodeID, id string) error {
+	return f.write(id+reservationExt, &provision.Result{
+		ID:      id,
+		Created: time.Now(),
+		State:   provision.StateDeleted,
+	})
+}
+
+// UpdateStats implements provision.Feedbacker
+func (f *Feedback) UpdateStats(nodeID string, w directory.WorkloadAmount, u directory.ResourceAmount) error {
+	return f.write(statsFile, &Stats{
+		NodeID:    nodeID,
+		Updated:   time.Now(),
+		Workloads: w,
+		Resources: u,
+	})
+}
+
+// write atomically replaces the file name in root with the json encoding of v
+func (f *Feedback) write(name string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(f.root, "."+name)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(f.root, name))
+}
@@ -0,0 +1,44 @@
+package local
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/threefoldtech/zos/pkg/crypto"
+	"golang.org/x/crypto/ed25519"
+)
+
+const keyExt = ".pub"
+
+// KeyResolver is an implementation of the provision.KeyResolver
+// that reads the public key of the users from files in a directory.
+// The key of a user is stored hex encoded in <root>/<user>.pub
+type KeyResolver struct {
+	root string
+}
+
+// NewKeyResolver creates a KeyResolver that reads keys from root
+func NewKeyResolver(root string) *KeyResolver {
+	return &KeyResolver{root: root}
+}
+
+// PublicKey implements provision.KeyResolver
+func (k *KeyResolver) PublicKey(user string) (ed25519.PublicKey, error) {
+	if user == "" || strings.ContainsAny(user, `/\`) || strings.HasPrefix(user, ".") {
+		return nil, fmt.Errorf("invalid user id '%s'", user)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(k.root, user+keyExt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key of user %s: %w", user, err)
+	}
+
+	key, err := crypto.KeyFromHex(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key for user %s: %w", user, err)
+	}
+
+	return key, nil
+}
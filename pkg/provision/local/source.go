@@ -0,0 +1,234 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+const reservationExt = ".json"
+
+// Source is an implementation of the provision.ReservationSource
+// that reads reservations from json files dropped in a directory.
+// It allows to use the provision engine on a node that is not connected to the TFExplorer.
+//
+// Each file contains a single provision.Reservation encoded in json. Creating or
+// modifying a file sends the reservation to the engine, removing the file sends
+// a request to decommission the reservation.
+// To avoid reading partially written files, reservations should be written to a
+// temporary file (without the .json extension) first, then renamed into place.
+type Source struct {
+	root           string
+	provisionOrder map[provision.ReservationType]int
+
+	known map[string]*provision.Reservation
+}
+
+// NewSource creates a local Source that watches the root directory
+// provisionOrder is used to order the reservations already present in root when the source starts
+func NewSource(root string, provisionOrder map[provision.ReservationType]int) (*Source, error) {
+	if err := os.MkdirAll(root, 0770); err != nil {
+		return nil, err
+	}
+
+	return &Source{
+		root:           root,
+		provisionOrder: provisionOrder,
+		known:          make(map[string]*provision.Reservation),
+	}, nil
+}
+
+// Reservations implements provision.ReservationSource
+func (s *Source) Reservations(ctx context.Context) <-chan *provision.Reservation {
+	ch := make(chan *provision.Reservation)
+
+	go func() {
+		defer close(ch)
+
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			log.Error().Err(err).Msg("failed to create local reservation watcher")
+			return
+		}
+		defer watcher.Close()
+
+		// start watching before the initial scan so we don't miss
+		// files created in between
+		if err := watcher.Add(s.root); err != nil {
+			log.Error().Err(err).Str("root", s.root).Msg("failed to watch local reservation directory")
+			return
+		}
+
+		reservations, err := s.scan()
+		if err != nil {
+			log.Error().Err(err).Str("root", s.root).Msg("failed to list local reservations")
+			return
+		}
+
+		for _, r := range reservations {
+			if !send(ctx, ch, r) {
+				return
+			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Error().Err(err).Msg("error while watching local reservations")
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				r := s.handle(event)
+				if r == nil {
+					continue
+				}
+
+				if !send(ctx, ch, r) {
+					return
+				}
+			}
+		}
+	}()
+
+	return ch
+}
+
+// handle processes a file system event and returns the reservation
+// that needs to be sent to the engine, if any
+func (s *Source) handle(event fsnotify.Event) *provision.Reservation {
+	if !isReservationFile(event.Name) {
+		return nil
+	}
+
+	if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		r, ok := s.known[event.Name]
+		if !ok {
+			return nil
+		}
+		delete(s.known, event.Name)
+
+		log.Info().Str("id", r.ID).Str("file", event.Name).Msg("local reservation removed, decommission")
+		deleted := *r
+		deleted.ToDelete = true
+		return &deleted
+	}
+
+	if event.Op&(fsnotify.Create|fsnotify.Write) == 0 {
+		return nil
+	}
+
+	r, err := load(event.Name)
+	if err != nil {
+		// the file might still be being written, we will get another
+		// write event once it is complete
+		log.Debug().Err(err).Str("file", event.Name).Msg("failed to load local reservation")
+		return nil
+	}
+
+	if old, ok := s.known[event.Name]; ok && same(old, r) {
+		return nil
+	}
+
+	s.known[event.Name] = r
+	return r
+}
+
+// scan loads all the reservations present in the root directory
+// sorted by provision order
+func (s *Source) scan() ([]*provision.Reservation, error) {
+	infos, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*provision.Reservation, 0, len(infos))
+	for _, info := range infos {
+		path := filepath.Join(s.root, info.Name())
+		if info.IsDir() || !isReservationFile(path) {
+			continue
+		}
+
+		r, err := load(path)
+		if err != nil {
+			log.Error().Err(err).Str("file", path).Msg("failed to load local reservation, skipping")
+			continue
+		}
+
+		s.known[path] = r
+		result = append(result, r)
+	}
+
+	if s.provisionOrder != nil {
+		sort.SliceStable(result, func(i int, j int) bool {
+			return s.provisionOrder[result[i].Type] < s.provisionOrder[result[j].Type]
+		})
+	}
+
+	return result, nil
+}
+
+func load(path string) (*provision.Reservation, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var r provision.Reservation
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("failed to decode reservation file '%s': %w", path, err)
+	}
+
+	if r.ID == "" {
+		return nil, fmt.Errorf("reservation file '%s' has no id", path)
+	}
+
+	// local reservations are always signed using their canonical encoding
+	// a challenge provided by the file would not be bound to the reservation data
+	r.Challenge = nil
+
+	return &r, nil
+}
+
+func same(a, b *provision.Reservation) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+
+	return string(x) == string(y)
+}
+
+func isReservationFile(path string) bool {
+	name := filepath.Base(path)
+	return !strings.HasPrefix(name, ".") && filepath.Ext(name) == reservationExt
+}
+
+func send(ctx context.Context, ch chan<- *provision.Reservation, r *provision.Reservation) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case ch <- r:
+		return true
+	}
+}
@@ -0,0 +1,116 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+func writeReservation(t *testing.T, root string, r *provision.Reservation) string {
+	data, err := json.Marshal(r)
+	require.NoError(t, err)
+
+	// write then rename so the source never sees a partial file
+	path := filepath.Join(root, r.ID+reservationExt)
+	tmp := filepath.Join(root, r.ID+".tmp")
+	require.NoError(t, ioutil.WriteFile(tmp, data, 0660))
+	require.NoError(t, os.Rename(tmp, path))
+
+	return path
+}
+
+func receive(t *testing.T, ch <-chan *provision.Reservation) *provision.Reservation {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for reservation")
+	}
+	return nil
+}
+
+func TestSource(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "local-source")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	writeReservation(t, root, &provision.Reservation{ID: "1-2", Type: "container"})
+	writeReservation(t, root, &provision.Reservation{ID: "1-1", Type: "network"})
+	require.NoError(ioutil.WriteFile(filepath.Join(root, "README"), []byte("ignored"), 0660))
+
+	source, err := NewSource(root, map[provision.ReservationType]int{
+		"network":   0,
+		"container": 1,
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ch := source.Reservations(ctx)
+
+	// existing reservations are sent in provision order
+	require.Equal("1-1", receive(t, ch).ID)
+	require.Equal("1-2", receive(t, ch).ID)
+
+	path := writeReservation(t, root, &provision.Reservation{ID: "1-3", Type: "zdb"})
+	r := receive(t, ch)
+	require.Equal("1-3", r.ID)
+	require.False(r.ToDelete)
+
+	require.NoError(os.Remove(path))
+	r = receive(t, ch)
+	require.Equal("1-3", r.ID)
+	require.True(r.ToDelete)
+}
+
+func TestFeedback(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "local-feedback")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	feedback, err := NewFeedback(root)
+	require.NoError(err)
+
+	err = feedback.Feedback("node", &provision.Result{
+		ID:    "1-1",
+		Type:  "container",
+		State: provision.StateOk,
+		Data:  json.RawMessage(`{"id":"1-1"}`),
+	})
+	require.NoError(err)
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "1-1.json"))
+	require.NoError(err)
+
+	var result provision.Result
+	require.NoError(json.Unmarshal(data, &result))
+	assert.Equal("1-1", result.ID)
+	assert.Equal(provision.StateOk, result.State)
+
+	require.NoError(feedback.Deleted("node", "1-1"))
+
+	data, err = ioutil.ReadFile(filepath.Join(root, "1-1.json"))
+	require.NoError(err)
+	require.NoError(json.Unmarshal(data, &result))
+	assert.Equal(provision.StateDeleted, result.State)
+
+	// no temporary files are left behind
+	infos, err := ioutil.ReadDir(root)
+	require.NoError(err)
+	assert.Len(infos, 1)
+}
@@ -0,0 +1,57 @@
+package provision
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxPrefetches is the number of reservations prefetched at the same time
+	maxPrefetches = 2
+	// prefetchQueueSize is the number of reservations waiting to be prefetched,
+	// the reservations received while the queue is full are not prefetched
+	prefetchQueueSize = 32
+)
+
+// prefetch queues the reservation to be prefetched in the background, so
+// what the workload needs is downloaded while the reservation is queued
+func (e *Engine) prefetch(ctx context.Context, r *Reservation) {
+	if _, ok := e.prefetchers[r.Type]; !ok || r.Expired() || r.ToDelete {
+		return
+	}
+
+	if cached, err := e.cache.Get(r.ID); err == nil && bytes.Equal(cached.Data, r.Data) {
+		// already provisioned
+		return
+	}
+
+	select {
+	case e.prefetches <- r:
+	default:
+		// the workload is downloaded when it's provisioned anyway
+		log.Debug().Str("id", r.ID).Msg("prefetch queue is full, skip prefetch")
+	}
+}
+
+// prefetchWorker runs the prefetchers of the queued reservations until
+// the context is done
+func (e *Engine) prefetchWorker(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-e.prefetches:
+			// nothing is downloaded for reservations that will be rejected
+			if err := e.verify(r); err != nil {
+				continue
+			}
+
+			log.Debug().Str("id", r.ID).Str("type", string(r.Type)).Msg("prefetch reservation")
+			if err := e.prefetchers[r.Type](ctx, r); err != nil {
+				log.Warn().Err(err).Str("id", r.ID).Msg("failed to prefetch reservation")
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefetch(t *testing.T) {
+	require := require.New(t)
+
+	prefetched := make(chan string, 10)
+	cache := memCache{}
+	e := New(EngineOps{
+		Cache:    cache,
+		Feedback: &testFeedback{},
+		Signer:   testSigner{},
+		Statser:  testStatser{},
+		Prefetchers: map[ReservationType]PrefetcherFunc{
+			"container": func(ctx context.Context, r *Reservation) error {
+				prefetched <- r.ID
+				return nil
+			},
+		},
+	})
+
+	reservation := func(id string, typ ReservationType) *Reservation {
+		return &Reservation{
+			ID:       id,
+			Type:     typ,
+			Created:  time.Now(),
+			Duration: math.MaxInt64,
+			Data:     []byte(`{}`),
+		}
+	}
+
+	deployed := reservation("1-1", "container")
+	require.NoError(cache.Add(deployed))
+
+	deleted := reservation("3-1", "container")
+	deleted.ToDelete = true
+
+	expired := reservation("4-1", "container")
+	expired.Duration = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	e.prefetch(ctx, deployed)
+	e.prefetch(ctx, reservation("2-1", "volume"))
+	e.prefetch(ctx, deleted)
+	e.prefetch(ctx, expired)
+
+	// an update of a deployed reservation is prefetched too
+	updated := *deployed
+	updated.Data = []byte(`{"flist": "new"}`)
+	e.prefetch(ctx, &updated)
+	e.prefetch(ctx, reservation("5-1", "container"))
+	go e.prefetchWorker(ctx)
+
+	var ids []string
+	for len(ids) < 2 {
+		select {
+		case id := <-prefetched:
+			ids = append(ids, id)
+		case <-time.After(time.Second):
+			t.Fatal("reservation was not prefetched")
+		}
+	}
+	require.ElementsMatch([]string{"1-1", "5-1"}, ids)
+
+	select {
+	case id := <-prefetched:
+		t.Fatalf("reservation %s should not be prefetched", id)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPrefetchQueueFull(t *testing.T) {
+	e := New(EngineOps{
+		Cache: memCache{},
+		Prefetchers: map[ReservationType]PrefetcherFunc{
+			"container": func(ctx context.Context, r *Reservation) error {
+				return nil
+			},
+		},
+	})
+
+	// without workers, the reservations that don't fit in the queue are dropped
+	for i := 0; i < prefetchQueueSize+1; i++ {
+		e.prefetch(context.Background(), &Reservation{
+			ID:       fmt.Sprintf("%d-1", i),
+			Type:     "container",
+			Created:  time.Now(),
+			Duration: math.MaxInt64,
+		})
+	}
+
+	require.Len(t, e.prefetches, prefetchQueueSize)
+}
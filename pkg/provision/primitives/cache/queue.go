@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/versioned"
+)
+
+var (
+	// retrySchemaV1 retry item schema version 1
+	retrySchemaV1 = versioned.MustParse("1.0.0")
+	// retrySchemaLastVersion link to latest version
+	retrySchemaLastVersion = retrySchemaV1
+)
+
+// FsQueue is a provision.RetryQueue using the filesystem as backend
+// each item is stored in its own file named after the item key
+type FsQueue struct {
+	sync.RWMutex
+	root string
+}
+
+// NewFSQueue creates a retry queue that stores its items in root
+func NewFSQueue(root string) (*FsQueue, error) {
+	if err := os.MkdirAll(root, 0770); err != nil {
+		return nil, err
+	}
+
+	return &FsQueue{root: root}, nil
+}
+
+// Push adds an item to the queue, replacing any item with the same key
+func (q *FsQueue) Push(item *provision.RetryItem) error {
+	q.Lock()
+	defer q.Unlock()
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so a crash never leaves
+	// a partially written item behind
+	path := filepath.Join(q.root, item.Key())
+	tmp := filepath.Join(q.root, "."+item.Key())
+	if err := versioned.WriteFile(tmp, retrySchemaLastVersion, data, 0660); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// Get retrieves an item from the queue using its key
+// it returns a non nil error if the item is not in the queue
+func (q *FsQueue) Get(key string) (*provision.RetryItem, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	return q.get(key)
+}
+
+// Remove an item from the queue. Removing an item
+// that is not in the queue is not an error
+func (q *FsQueue) Remove(key string) error {
+	q.Lock()
+	defer q.Unlock()
+
+	err := os.Remove(filepath.Join(q.root, key))
+	if os.IsNotExist(errors.Cause(err)) {
+		return nil
+	}
+
+	return err
+}
+
+// Clear removes all the items from the queue
+func (q *FsQueue) Clear() error {
+	q.Lock()
+	defer q.Unlock()
+
+	infos, err := ioutil.ReadDir(q.root)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		if err := os.RemoveAll(filepath.Join(q.root, info.Name())); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List returns all the items in the queue sorted by next attempt
+func (q *FsQueue) List() ([]*provision.RetryItem, error) {
+	q.RLock()
+	defer q.RUnlock()
+
+	infos, err := ioutil.ReadDir(q.root)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*provision.RetryItem, 0, len(infos))
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+			continue
+		}
+
+		item, err := q.get(info.Name())
+		if err != nil {
+			log.Error().Err(err).Str("key", info.Name()).Msg("invalid item in retry queue, skipping")
+			continue
+		}
+
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].NextAttempt.Before(items[j].NextAttempt)
+	})
+
+	return items, nil
+}
+
+func (q *FsQueue) get(key string) (*provision.RetryItem, error) {
+	version, data, err := versioned.ReadFile(filepath.Join(q.root, key))
+	if os.IsNotExist(err) {
+		return nil, errors.Wrapf(err, "retry item %s not found", key)
+	} else if err != nil {
+		return nil, err
+	}
+
+	validV1 := versioned.MustParseRange(fmt.Sprintf("<=%s", retrySchemaV1))
+	if !validV1(version) {
+		return nil, fmt.Errorf("unknown retry item version (%s)", version)
+	}
+
+	var item provision.RetryItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
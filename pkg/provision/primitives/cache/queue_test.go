@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+func TestFsQueue(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	q, err := NewFSQueue(root)
+	require.NoError(err)
+
+	now := time.Now().UTC().Round(time.Second)
+	provision1 := &provision.RetryItem{
+		Kind:        provision.RetryProvision,
+		ID:          "1-1",
+		Reservation: &provision.Reservation{ID: "1-1", Type: "container"},
+		NextAttempt: now.Add(time.Minute),
+	}
+	feedback1 := &provision.RetryItem{
+		Kind:        provision.RetryFeedback,
+		ID:          "1-1",
+		Result:      &provision.Result{ID: "1-1", State: provision.StateOk},
+		NextAttempt: now,
+	}
+
+	require.NoError(q.Push(provision1))
+	require.NoError(q.Push(feedback1))
+
+	items, err := q.List()
+	require.NoError(err)
+	require.Len(items, 2)
+	// sorted by next attempt
+	assert.Equal(provision.RetryFeedback, items[0].Kind)
+	assert.Equal(provision.RetryProvision, items[1].Kind)
+
+	// deleted notification replaces the feedback of the same reservation
+	deleted1 := &provision.RetryItem{
+		Kind:        provision.RetryDeleted,
+		ID:          "1-1",
+		NextAttempt: now,
+		Attempts:    2,
+	}
+	require.NoError(q.Push(deleted1))
+
+	item, err := q.Get(deleted1.Key())
+	require.NoError(err)
+	assert.Equal(provision.RetryDeleted, item.Kind)
+	assert.Equal(2, item.Attempts)
+
+	items, err = q.List()
+	require.NoError(err)
+	require.Len(items, 2)
+
+	require.NoError(q.Remove(provision1.Key()))
+	require.NoError(q.Remove(provision1.Key()), "removing a missing item is not an error")
+
+	_, err = q.Get(provision1.Key())
+	assert.Error(err)
+
+	require.NoError(q.Clear())
+	items, err = q.List()
+	require.NoError(err)
+	assert.Empty(items)
+}
@@ -0,0 +1,140 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/network/nr"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+// containerCheck makes sure the container still exists and, unless it's
+// suspended, that its task is running when it should. Containers being
+// restarted are left to the container supervisor, and the ones that exited
+// are left alone if their restart policy doesn't want them running
+func (p *Provisioner) containerCheck(ctx context.Context, reservation *provision.Reservation) error {
+	container := stubs.NewContainerModuleStub(p.zbus)
+	tenantNS := fmt.Sprintf("ns%s", reservation.User)
+
+	info, err := container.Inspect(tenantNS, pkg.ContainerID(reservation.ID))
+	if err != nil {
+		return errors.Wrapf(err, "container %s not found", reservation.ID)
+	}
+
+	if reservation.Suspended {
+		return nil
+	}
+
+	if containerDown(info) {
+		return fmt.Errorf("container %s is not running (%s)", reservation.ID, info.State)
+	}
+
+	return nil
+}
+
+// volumeCheck makes sure the volume subvolume still exists
+func (p *Provisioner) volumeCheck(ctx context.Context, reservation *provision.Reservation) error {
+	storage := stubs.NewStorageModuleStub(p.zbus)
+
+	if _, err := storage.Path(reservation.ID); err != nil {
+		return errors.Wrapf(err, "volume %s not found", reservation.ID)
+	}
+
+	return nil
+}
+
+// zdbCheck makes sure the 0-db container holding the namespace is running
+// and that the namespace still exists in it
+func (p *Provisioner) zdbCheck(ctx context.Context, reservation *provision.Reservation) error {
+	var (
+		storage   = stubs.NewZDBAllocaterStub(p.zbus)
+		container = stubs.NewContainerModuleStub(p.zbus)
+		nsID      = reservation.ID
+	)
+
+	allocation, err := storage.Find(nsID)
+	if err != nil {
+		return errors.Wrapf(err, "storage of 0-db namespace %s not found", nsID)
+	}
+
+	containerID := pkg.ContainerID(allocation.VolumeID)
+	if _, err := container.Inspect(zdbContainerNS, containerID); err != nil {
+		return errors.Wrapf(err, "0-db container %s not found", containerID)
+	}
+
+	zdbCl := zdbConnection(containerID)
+	defer zdbCl.Close()
+	if err := zdbCl.Connect(); err != nil {
+		return errors.Wrapf(err, "failed to connect to 0-db: %s", containerID)
+	}
+
+	exists, err := zdbCl.Exist(nsID)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check namespace %s in 0-db: %s", nsID, containerID)
+	}
+
+	if !exists {
+		return fmt.Errorf("namespace %s not found in 0-db: %s", nsID, containerID)
+	}
+
+	return nil
+}
+
+// networkCheck makes sure the network resource is still known by networkd
+// and that its namespace and wireguard interface still exist
+func (p *Provisioner) networkCheck(ctx context.Context, reservation *provision.Reservation) error {
+	mgr := stubs.NewNetworkerStub(p.zbus)
+
+	network := pkg.NetResource{}
+	if err := json.Unmarshal(reservation.Data, &network); err != nil {
+		return fmt.Errorf("failed to unmarshal network from reservation: %w", err)
+	}
+
+	network.NetID = networkID(reservation.User, network.Name)
+
+	if _, err := mgr.GetSubnet(network.NetID); err != nil {
+		return errors.Wrapf(err, "network resource %s not found", network.NetID)
+	}
+
+	netRes, err := nr.New(network)
+	if err != nil {
+		return errors.Wrap(err, "failed to load network resource")
+	}
+
+	netNS, err := netRes.Namespace()
+	if err != nil {
+		return err
+	}
+
+	wgName, err := netRes.WGName()
+	if err != nil {
+		return err
+	}
+
+	if _, err := mgr.Addrs(wgName, netNS); err != nil {
+		return errors.Wrapf(err, "wireguard interface of network resource %s not found", network.NetID)
+	}
+
+	return nil
+}
+
+// kubernetesCheck makes sure the kubernetes vm still exists
+func (p *Provisioner) kubernetesCheck(ctx context.Context, reservation *provision.Reservation) error {
+	return p.vmCheck(ctx, reservation)
+}
+
+// vmCheck makes sure the vm still exists
+func (p *Provisioner) vmCheck(ctx context.Context, reservation *provision.Reservation) error {
+	vm := stubs.NewVMModuleStub(p.zbus)
+
+	if !vm.Exists(reservation.ID) {
+		return fmt.Errorf("vm %s not found", reservation.ID)
+	}
+
+	return nil
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path"
+	"reflect"
 	"time"
 
 	"github.com/cenkalti/backoff/v3"
@@ -16,6 +17,7 @@ import (
 	"github.com/threefoldtech/zos/pkg"
 	"github.com/threefoldtech/zos/pkg/container/logger"
 	"github.com/threefoldtech/zos/pkg/container/stats"
+	"github.com/threefoldtech/zos/pkg/network/namespace"
 	"github.com/threefoldtech/zos/pkg/provision"
 	"github.com/threefoldtech/zos/pkg/stubs"
 )
@@ -26,6 +28,36 @@ type Network struct {
 	// IP to give to the container
 	IPs       []net.IP `json:"ips"`
 	PublicIP6 bool     `json:"public_ip6"`
+	// Bandwidth limits the traffic of the container
+	// in the network, unlimited if empty
+	Bandwidth pkg.Bandwidth `json:"bandwidth"`
+}
+
+// RestartPolicy defines when the container entrypoint is restarted after it exits
+type RestartPolicy struct {
+	// Policy is one of always (default), on-failure or never
+	Policy pkg.RestartPolicyType `json:"policy"`
+	// MaxRetries is the maximum number of restarts, 0 means no limit
+	MaxRetries uint `json:"max_retries"`
+}
+
+// HealthCheck defines how to probe the health of the container
+type HealthCheck struct {
+	// Type is one of exec, tcp or http
+	Type pkg.HealthCheckType `json:"type"`
+	// Command to run inside the container for exec checks
+	Command string `json:"command,omitempty"`
+	// Port to probe for tcp and http checks
+	Port uint16 `json:"port,omitempty"`
+	// Path of the http request for http checks
+	Path string `json:"path,omitempty"`
+	// Interval between two probes in seconds
+	Interval uint `json:"interval,omitempty"`
+	// Timeout of a probe in seconds
+	Timeout uint `json:"timeout,omitempty"`
+	// Retries is the number of consecutive failed probes after
+	// which the container is restarted
+	Retries uint `json:"retries,omitempty"`
 }
 
 // Mount defines a container volume mounted inside the container
@@ -40,6 +72,18 @@ type Container struct {
 	FList string `json:"flist"`
 	// URL of the storage backend for the flist
 	FlistStorage string `json:"flist_storage"`
+	// FlistChecksum is the hex encoded sha256 of the flist, the
+	// container is not deployed if the flist doesn't match it
+	FlistChecksum string `json:"flist_checksum"`
+	// FlistPublisher is the hex encoded ed25519 key of the publisher
+	// of the flist, the flist must be signed by this key
+	FlistPublisher string `json:"flist_publisher"`
+	// FlistSignature is the hex encoded signature of the sha256 of the flist
+	// by FlistPublisher. If empty, it is downloaded next to the flist
+	FlistSignature string `json:"flist_signature"`
+	// Image is a reference to an OCI image to use instead of
+	// the flist, like docker.io/library/redis:6
+	Image string `json:"image"`
 	// Env env variables to container in format
 	Env map[string]string `json:"env"`
 	// Env env variables to container that the value is encrypted
@@ -60,6 +104,10 @@ type Container struct {
 	Logs []logger.Logs `json:"logs,omitempty"`
 	// StatsAggregator container metrics backend
 	StatsAggregator []stats.Aggregator
+	// RestartPolicy of the container entrypoint
+	RestartPolicy *RestartPolicy `json:"restart_policy,omitempty"`
+	// HealthCheck of the container
+	HealthCheck *HealthCheck `json:"health_check,omitempty"`
 }
 
 // ContainerResult is the information return to the BCDB
@@ -91,7 +139,6 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	var (
 		containerClient = stubs.NewContainerModuleStub(p.zbus)
 		flistClient     = stubs.NewFlisterStub(p.zbus)
-		storageClient   = stubs.NewStorageModuleStub(p.zbus)
 		networkMgr      = stubs.NewNetworkerStub(p.zbus)
 		tenantNS        = fmt.Sprintf("ns%s", reservation.User)
 		containerID     = reservation.ID
@@ -103,8 +150,15 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	}
 
 	// check if workload is already deployed
-	_, err := containerClient.Inspect(tenantNS, pkg.ContainerID(containerID))
+	info, err := containerClient.Inspect(tenantNS, pkg.ContainerID(containerID))
 	if err == nil {
+		if !reservation.Suspended && containerDown(info) {
+			// the container is there but its task is gone, start it again
+			if err := containerClient.Start(tenantNS, pkg.ContainerID(containerID)); err != nil {
+				return ContainerResult{}, errors.Wrapf(err, "failed to start container %s", containerID)
+			}
+		}
+
 		log.Info().Str("id", containerID).Msg("container already deployed")
 		return ContainerResult{
 			ID:   containerID,
@@ -128,29 +182,14 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	}
 
 	// check to make sure the requested volume are accessible
-	for _, mount := range config.Mounts {
-		volumeRes, err := p.cache.Get(mount.VolumeID)
-		if err != nil {
-			return ContainerResult{}, errors.Wrapf(err, "failed to retrieve the owner of volume %s", mount.VolumeID)
-		}
-
-		if volumeRes.User != reservation.User {
-			return ContainerResult{}, fmt.Errorf("cannot use volume %s, user %s is not the owner of it", mount.VolumeID, reservation.User)
-		}
+	if err := p.checkVolumesOwner(reservation.User, config.Mounts); err != nil {
+		return ContainerResult{}, err
 	}
 
 	// ensure we can decrypt all environment variables
-	var env []string
-	for k, v := range config.Env {
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
-	}
-
-	for k, v := range config.SecretEnv {
-		v, err := decryptSecret(p.zbus, v)
-		if err != nil {
-			return ContainerResult{}, errors.Wrapf(err, "failed to decrypt secret env var '%s'", k)
-		}
-		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	env, err := p.containerEnv(config)
+	if err != nil {
+		return ContainerResult{}, err
 	}
 
 	// prepare container network
@@ -158,8 +197,16 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	for i, ip := range config.Network.IPs {
 		ips[i] = ip.String()
 	}
+	// the container might have disappeared while its network namespace
+	// is still there, make sure it's removed before joining the network again
+	if namespace.Exists(containerID) {
+		if err := networkMgr.Leave(netID, containerID); err != nil {
+			log.Error().Err(err).Str("container", containerID).Msg("failed to clean up container network namespace")
+		}
+	}
+
 	var join pkg.Member
-	join, err = networkMgr.Join(netID, containerID, ips, config.Network.PublicIP6)
+	join, err = networkMgr.Join(netID, containerID, ips, config.Network.PublicIP6, config.Network.Bandwidth)
 	if err != nil {
 		return ContainerResult{}, err
 	}
@@ -177,45 +224,17 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 		}
 	}()
 
-	// mount root flist
-	log.Debug().Str("flist", config.FList).Msg("mounting flist")
-	rootfsMntOpt := pkg.MountOptions{
-		Limit:    config.Capacity.DiskSize,
-		ReadOnly: false,
-		Type:     config.Capacity.DiskType,
-	}
-	if rootfsMntOpt.Limit == 0 || rootfsMntOpt.Type == "" {
-		rootfsMntOpt = pkg.DefaultMountOptions
-	}
-
 	var mnt string
-	mnt, err = flistClient.NamedMount(reservation.ID, config.FList, config.FlistStorage, rootfsMntOpt)
+	mnt, err = mountRootFS(flistClient, reservation.ID, config)
 	if err != nil {
 		return ContainerResult{}, err
 	}
 
 	// prepare mount info for volumes
 	var mounts []pkg.MountInfo
-	for _, mount := range config.Mounts {
-		// we make sure that mountpoint in config doesn't have relative parts
-		mountpoint := path.Join("/", mount.Mountpoint)
-
-		if err := os.MkdirAll(path.Join(mnt, mountpoint), 0755); err != nil {
-			return ContainerResult{}, err
-		}
-		var source string
-		source, err = storageClient.Path(mount.VolumeID)
-		if err != nil {
-			return ContainerResult{}, errors.Wrapf(err, "failed to get the mountpoint path of the volume %s", mount.VolumeID)
-		}
-
-		mounts = append(
-			mounts,
-			pkg.MountInfo{
-				Source: source,
-				Target: mountpoint,
-			},
-		)
+	mounts, err = p.containerMounts(mnt, config.Mounts)
+	if err != nil {
+		return ContainerResult{}, err
 	}
 
 	defer func() {
@@ -233,21 +252,7 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	var id pkg.ContainerID
 	id, err = containerClient.Run(
 		tenantNS,
-		pkg.Container{
-			Name:   containerID,
-			RootFS: mnt,
-			Env:    env,
-			Network: pkg.NetworkInfo{
-				Namespace: join.Namespace,
-			},
-			Mounts:          mounts,
-			Entrypoint:      config.Entrypoint,
-			Interactive:     config.Interactive,
-			CPU:             config.Capacity.CPU,
-			Memory:          config.Capacity.Memory * mib,
-			Logs:            config.Logs,
-			StatsAggregator: config.StatsAggregator,
-		},
+		containerSpec(containerID, mnt, join.Namespace, env, mounts, config),
 	)
 	if err != nil {
 		return ContainerResult{}, errors.Wrap(err, "error starting container")
@@ -272,6 +277,170 @@ func (p *Provisioner) containerProvisionImpl(ctx context.Context, reservation *p
 	}, nil
 }
 
+func (p *Provisioner) containerUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	return p.containerUpdateImpl(ctx, old, reservation)
+}
+
+// containerUpdateImpl restarts the container with the new configuration while keeping its network
+// namespace and, as long as the flist or image doesn't change, the read-write layer of its root filesystem
+func (p *Provisioner) containerUpdateImpl(ctx context.Context, old, reservation *provision.Reservation) (ContainerResult, error) {
+	var (
+		containerClient = stubs.NewContainerModuleStub(p.zbus)
+		flistClient     = stubs.NewFlisterStub(p.zbus)
+		storageClient   = stubs.NewStorageModuleStub(p.zbus)
+		tenantNS        = fmt.Sprintf("ns%s", reservation.User)
+		containerID     = reservation.ID
+	)
+
+	var current, config Container
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return ContainerResult{}, err
+	}
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return ContainerResult{}, err
+	}
+
+	if err := validateContainerConfig(config); err != nil {
+		return ContainerResult{}, errors.Wrap(err, "container provision schema not valid")
+	}
+
+	if !reflect.DeepEqual(current.Network, config.Network) {
+		return ContainerResult{}, fmt.Errorf("network configuration of a container cannot be updated")
+	}
+
+	if current.Capacity.DiskType != config.Capacity.DiskType {
+		return ContainerResult{}, fmt.Errorf("disk type of a container root filesystem cannot be updated")
+	}
+
+	if err := p.checkVolumesOwner(reservation.User, config.Mounts); err != nil {
+		return ContainerResult{}, err
+	}
+
+	env, err := p.containerEnv(config)
+	if err != nil {
+		return ContainerResult{}, err
+	}
+
+	info, err := containerClient.Inspect(tenantNS, pkg.ContainerID(containerID))
+	if err != nil {
+		return ContainerResult{}, errors.Wrapf(err, "failed to inspect container %s", containerID)
+	}
+
+	rootFS := info.RootFS
+	if info.Interactive {
+		rootFS, err = findRootFS(info.Mounts)
+		if err != nil {
+			return ContainerResult{}, err
+		}
+	}
+
+	// the current container is only replaced once the new root filesystem and
+	// volumes are ready, so an update that cannot be applied leaves it running
+	currentEnv, err := p.containerEnv(current)
+	if err != nil {
+		return ContainerResult{}, err
+	}
+
+	currentMounts, err := p.containerMounts(rootFS, current.Mounts)
+	if err != nil {
+		return ContainerResult{}, err
+	}
+
+	var (
+		newRootFS = rootFS
+		updated   bool
+	)
+
+	if current.FList != config.FList || current.FlistStorage != config.FlistStorage || current.Image != config.Image ||
+		current.FlistChecksum != config.FlistChecksum || current.FlistPublisher != config.FlistPublisher {
+		// a new flist or image means a new root filesystem, the read-write layer
+		// of the previous one and its snapshots are dropped together with the
+		// old mount once the container runs from the new one
+		newRootFS, err = mountRootFS(flistClient, updateMountName(reservation.ID, rootFS), config)
+		if err != nil {
+			return ContainerResult{}, err
+		}
+
+		defer func() {
+			drop := newRootFS
+			if updated {
+				drop = rootFS
+			}
+
+			if err := dropRootFS(flistClient, drop); err != nil {
+				log.Error().Err(err).Str("container", containerID).Msg("failed to drop root filesystem")
+			}
+		}()
+	} else if current.Capacity.DiskSize != config.Capacity.DiskSize && config.Capacity.DiskSize != 0 {
+		name := path.Base(rootFS)
+		if err := storageClient.ResizeFilesystem(name, config.Capacity.DiskSize*mib); err != nil {
+			return ContainerResult{}, errors.Wrap(err, "failed to resize container root filesystem")
+		}
+
+		defer func() {
+			if updated {
+				return
+			}
+
+			size := current.Capacity.DiskSize
+			if size == 0 {
+				size = pkg.DefaultMountOptions.Limit
+			}
+
+			if err := storageClient.ResizeFilesystem(name, size*mib); err != nil {
+				log.Error().Err(err).Str("container", containerID).Msg("failed to restore container root filesystem size")
+			}
+		}()
+	}
+
+	mounts, err := p.containerMounts(newRootFS, config.Mounts)
+	if err != nil {
+		return ContainerResult{}, err
+	}
+
+	if err := containerClient.Delete(tenantNS, pkg.ContainerID(containerID)); err != nil {
+		return ContainerResult{}, errors.Wrapf(err, "failed to stop container %s", containerID)
+	}
+
+	id, err := containerClient.Run(
+		tenantNS,
+		containerSpec(containerID, newRootFS, info.Network.Namespace, env, mounts, config),
+	)
+	if err != nil {
+		// the previous configuration is started again so a failed update
+		// doesn't take the workload down
+		if err := containerClient.Delete(tenantNS, pkg.ContainerID(containerID)); err != nil {
+			log.Error().Err(err).Str("container", containerID).Msg("failed to delete container after failed update")
+		}
+
+		if _, err := containerClient.Run(
+			tenantNS,
+			containerSpec(containerID, rootFS, info.Network.Namespace, currentEnv, currentMounts, current),
+		); err != nil {
+			log.Error().Err(err).Str("container", containerID).Msg("failed to restart container with its previous configuration")
+		}
+
+		return ContainerResult{}, errors.Wrap(err, "error starting container")
+	}
+	updated = true
+
+	result := ContainerResult{
+		ID:   string(id),
+		IPv4: config.Network.IPs[0].String(),
+	}
+
+	if config.Network.PublicIP6 {
+		ip, err := p.waitContainerIP(ctx, "pub", info.Network.Namespace)
+		if err != nil {
+			return ContainerResult{}, errors.Wrap(err, "error reading container ipv6")
+		}
+		result.IPv6 = ip.String()
+	}
+
+	log.Info().Msgf("container updated with id: '%s'", id)
+	return result, nil
+}
+
 func (p *Provisioner) containerDecommission(ctx context.Context, reservation *provision.Reservation) error {
 	container := stubs.NewContainerModuleStub(p.zbus)
 	flist := stubs.NewFlisterStub(p.zbus)
@@ -299,8 +468,8 @@ func (p *Provisioner) containerDecommission(ctx context.Context, reservation *pr
 			}
 		}
 
-		if err := flist.Umount(rootFS); err != nil {
-			return errors.Wrapf(err, "failed to unmount flist at %s", rootFS)
+		if err := dropRootFS(flist, rootFS); err != nil {
+			return err
 		}
 
 	} else {
@@ -317,6 +486,46 @@ func (p *Provisioner) containerDecommission(ctx context.Context, reservation *pr
 	return nil
 }
 
+// containerSuspend stops the container, its root filesystem and
+// network namespace are kept so it can be resumed
+func (p *Provisioner) containerSuspend(ctx context.Context, reservation *provision.Reservation) (interface{}, error) {
+	container := stubs.NewContainerModuleStub(p.zbus)
+	tenantNS := fmt.Sprintf("ns%s", reservation.User)
+
+	if err := container.Stop(tenantNS, pkg.ContainerID(reservation.ID)); err != nil {
+		return nil, errors.Wrapf(err, "failed to stop container %s", reservation.ID)
+	}
+
+	return p.containerResult(reservation, tenantNS)
+}
+
+// containerResume starts again a container stopped by containerSuspend
+func (p *Provisioner) containerResume(ctx context.Context, reservation *provision.Reservation) (interface{}, error) {
+	container := stubs.NewContainerModuleStub(p.zbus)
+	tenantNS := fmt.Sprintf("ns%s", reservation.User)
+
+	if err := container.Start(tenantNS, pkg.ContainerID(reservation.ID)); err != nil {
+		return nil, errors.Wrapf(err, "failed to start container %s", reservation.ID)
+	}
+
+	return p.containerResult(reservation, tenantNS)
+}
+
+// containerPrefetch downloads the flist of the container ahead of its provisioning
+func (p *Provisioner) containerPrefetch(ctx context.Context, reservation *provision.Reservation) error {
+	var config Container
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	if config.Image != "" {
+		// image layers are downloaded when the container is provisioned
+		return nil
+	}
+
+	return stubs.NewFlisterStub(p.zbus).Prefetch(config.FList, config.FlistStorage)
+}
+
 func (p *Provisioner) waitContainerIP(ctx context.Context, ifaceName, namespace string) (net.IP, error) {
 	var (
 		network     = stubs.NewNetworkerStub(p.zbus)
@@ -356,6 +565,127 @@ func (p *Provisioner) waitContainerIP(ctx context.Context, ifaceName, namespace
 	return containerIP, nil
 }
 
+// containerSpec builds the container module definition of a container reservation
+// containerDown checks if the task of the container is gone while it should
+// be running. A container that exited is only down if its restart policy
+// wants it running, the others exited for good
+func containerDown(info pkg.Container) bool {
+	switch info.State {
+	case pkg.ContainerStopped:
+		return true
+	case pkg.ContainerExited:
+		return info.RestartPolicy.ShouldRestart(info.ExitCode, info.Restarts)
+	default:
+		return false
+	}
+}
+
+func containerSpec(name, rootFS, netns string, env []string, mounts []pkg.MountInfo, config Container) pkg.Container {
+	spec := pkg.Container{
+		Name:   name,
+		RootFS: rootFS,
+		Env:    env,
+		Network: pkg.NetworkInfo{
+			Namespace: netns,
+		},
+		Mounts:          mounts,
+		Entrypoint:      config.Entrypoint,
+		Interactive:     config.Interactive,
+		CPU:             config.Capacity.CPU,
+		Memory:          config.Capacity.Memory * mib,
+		Logs:            config.Logs,
+		StatsAggregator: config.StatsAggregator,
+	}
+
+	if config.RestartPolicy != nil {
+		spec.RestartPolicy = pkg.RestartPolicy{
+			Policy:     config.RestartPolicy.Policy,
+			MaxRetries: config.RestartPolicy.MaxRetries,
+		}
+	}
+
+	if check := config.HealthCheck; check != nil {
+		spec.HealthCheck = &pkg.HealthCheck{
+			Type:     check.Type,
+			Command:  check.Command,
+			Port:     check.Port,
+			Path:     check.Path,
+			Interval: time.Duration(check.Interval) * time.Second,
+			Timeout:  time.Duration(check.Timeout) * time.Second,
+			Retries:  check.Retries,
+		}
+	}
+
+	return spec
+}
+
+// containerEnv returns the environment variables of the container
+// with the secret ones decrypted
+func (p *Provisioner) containerEnv(config Container) ([]string, error) {
+	var env []string
+	for k, v := range config.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	for k, v := range config.SecretEnv {
+		v, err := decryptSecret(p.zbus, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt secret env var '%s'", k)
+		}
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	return env, nil
+}
+
+// checkVolumesOwner makes sure all the volumes mounted in the container
+// belong to the user
+func (p *Provisioner) checkVolumesOwner(user string, mounts []Mount) error {
+	for _, mount := range mounts {
+		volumeRes, err := p.cache.Get(mount.VolumeID)
+		if err != nil {
+			return errors.Wrapf(err, "failed to retrieve the owner of volume %s", mount.VolumeID)
+		}
+
+		if volumeRes.User != user {
+			return fmt.Errorf("cannot use volume %s, user %s is not the owner of it", mount.VolumeID, user)
+		}
+	}
+
+	return nil
+}
+
+// containerMounts prepares the mount info for the volumes
+// mounted in the container with root filesystem rootFS
+func (p *Provisioner) containerMounts(rootFS string, volumes []Mount) ([]pkg.MountInfo, error) {
+	storageClient := stubs.NewStorageModuleStub(p.zbus)
+
+	var mounts []pkg.MountInfo
+	for _, mount := range volumes {
+		// we make sure that mountpoint in config doesn't have relative parts
+		mountpoint := path.Join("/", mount.Mountpoint)
+
+		if err := os.MkdirAll(path.Join(rootFS, mountpoint), 0755); err != nil {
+			return nil, err
+		}
+
+		source, err := storageClient.Path(mount.VolumeID)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get the mountpoint path of the volume %s", mount.VolumeID)
+		}
+
+		mounts = append(
+			mounts,
+			pkg.MountInfo{
+				Source: source,
+				Target: mountpoint,
+			},
+		)
+	}
+
+	return mounts, nil
+}
+
 func validateContainerConfig(config Container) error {
 	if config.Network.NetworkID == "" {
 		return fmt.Errorf("network ID cannot be empty")
@@ -365,8 +695,20 @@ func validateContainerConfig(config Container) error {
 		return fmt.Errorf("missing container IP address")
 	}
 
-	if config.FList == "" {
-		return fmt.Errorf("missing flist url")
+	if config.FList == "" && config.Image == "" {
+		return fmt.Errorf("missing flist url or image")
+	}
+
+	if config.FList != "" && config.Image != "" {
+		return fmt.Errorf("a container cannot use both an flist and an image")
+	}
+
+	if config.FlistSignature != "" && config.FlistPublisher == "" {
+		return fmt.Errorf("flist signature requires the flist publisher key")
+	}
+
+	if config.Image != "" && (config.FlistChecksum != "" || config.FlistPublisher != "") {
+		return fmt.Errorf("flist checksum and publisher cannot be used with an image, use an image digest instead")
 	}
 
 	if config.Capacity.Memory < 1024 {
@@ -377,9 +719,79 @@ func validateContainerConfig(config Container) error {
 		return fmt.Errorf("cannot create a container with 0 CPU allocated")
 	}
 
+	if policy := config.RestartPolicy; policy != nil {
+		switch policy.Policy {
+		case "", pkg.RestartAlways, pkg.RestartOnFailure, pkg.RestartNever:
+		default:
+			return fmt.Errorf("invalid restart policy '%s'", policy.Policy)
+		}
+	}
+
+	if check := config.HealthCheck; check != nil {
+		switch check.Type {
+		case pkg.HealthCheckExec:
+			if check.Command == "" {
+				return fmt.Errorf("exec health check requires a command")
+			}
+		case pkg.HealthCheckTCP, pkg.HealthCheckHTTP:
+			if check.Port == 0 {
+				return fmt.Errorf("%s health check requires a port", check.Type)
+			}
+		default:
+			return fmt.Errorf("invalid health check type '%s'", check.Type)
+		}
+	}
+
+	return nil
+}
+
+// mountRootFS mounts the root filesystem of the container, from its flist or image
+func mountRootFS(flistClient pkg.Flister, name string, config Container) (string, error) {
+	rootfsMntOpt := pkg.MountOptions{
+		Limit:    config.Capacity.DiskSize,
+		ReadOnly: false,
+		Type:     config.Capacity.DiskType,
+	}
+	if rootfsMntOpt.Limit == 0 || rootfsMntOpt.Type == "" {
+		rootfsMntOpt = pkg.DefaultMountOptions
+	}
+
+	if config.Image != "" {
+		log.Debug().Str("image", config.Image).Msg("mounting image")
+		return flistClient.MountImage(name, config.Image, rootfsMntOpt)
+	}
+
+	rootfsMntOpt.Checksum = config.FlistChecksum
+	rootfsMntOpt.PublicKey = config.FlistPublisher
+	rootfsMntOpt.Signature = config.FlistSignature
+
+	log.Debug().Str("flist", config.FList).Msg("mounting flist")
+	return flistClient.NamedMount(name, config.FList, config.FlistStorage, rootfsMntOpt)
+}
+
+// dropRootFS unmounts the root filesystem of a container, the snapshots of
+// its read-write layer are deleted with it
+func dropRootFS(flistClient pkg.Flister, rootFS string) error {
+	if err := flistClient.DeleteSnapshots(path.Base(rootFS)); err != nil {
+		return errors.Wrapf(err, "failed to delete snapshots of flist at %s", rootFS)
+	}
+
+	if err := flistClient.Umount(rootFS); err != nil {
+		return errors.Wrapf(err, "failed to unmount flist at %s", rootFS)
+	}
+
 	return nil
 }
 
+// updateMountName returns the name to mount the new root filesystem of a
+// container under, so it can be prepared next to the current one
+func updateMountName(id, rootFS string) string {
+	if path.Base(rootFS) == id {
+		return id + "-update"
+	}
+	return id
+}
+
 func findRootFS(mounts []pkg.MountInfo) (string, error) {
 	for _, m := range mounts {
 		if m.Target == "/sandbox" {
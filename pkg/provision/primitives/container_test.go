@@ -0,0 +1,69 @@
+package primitives
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestContainerDown(t *testing.T) {
+	cases := []struct {
+		name string
+		info pkg.Container
+		down bool
+	}{
+		{
+			name: "running",
+			info: pkg.Container{State: pkg.ContainerRunning, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartAlways}},
+			down: false,
+		},
+		{
+			name: "restarting",
+			info: pkg.Container{State: pkg.ContainerRestarting, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartAlways}},
+			down: false,
+		},
+		{
+			name: "stopped",
+			info: pkg.Container{State: pkg.ContainerStopped, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartNever}},
+			down: true,
+		},
+		{
+			name: "exited never",
+			info: pkg.Container{State: pkg.ContainerExited, ExitCode: 1, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartNever}},
+			down: false,
+		},
+		{
+			name: "exited no policy",
+			info: pkg.Container{State: pkg.ContainerExited, ExitCode: 1},
+			down: false,
+		},
+		{
+			name: "exited always",
+			info: pkg.Container{State: pkg.ContainerExited, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartAlways}},
+			down: true,
+		},
+		{
+			name: "exited on-failure clean",
+			info: pkg.Container{State: pkg.ContainerExited, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartOnFailure}},
+			down: false,
+		},
+		{
+			name: "exited on-failure failed",
+			info: pkg.Container{State: pkg.ContainerExited, ExitCode: 1, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartOnFailure}},
+			down: true,
+		},
+		{
+			name: "exited past max retries",
+			info: pkg.Container{State: pkg.ContainerExited, ExitCode: 1, Restarts: 3, RestartPolicy: pkg.RestartPolicy{Policy: pkg.RestartAlways, MaxRetries: 3}},
+			down: false,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.down, containerDown(c.info))
+		})
+	}
+}
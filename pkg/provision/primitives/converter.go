@@ -1,6 +1,7 @@
 package primitives
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"math"
@@ -21,6 +22,12 @@ import (
 // provisiond is received from the explorer
 var ErrUnsupportedWorkload = errors.New("workload type not supported")
 
+// signatureChallenger is implemented by the explorer workloads
+// it returns the data signed by the customer
+type signatureChallenger interface {
+	SignatureChallenge() ([]byte, error)
+}
+
 // ContainerToProvisionType converts TfgridReservationContainer1 to Container
 func ContainerToProvisionType(w workloads.Workloader, reservationID string) (Container, string, error) {
 	c, ok := w.(*workloads.Container)
@@ -245,7 +252,6 @@ func WorkloadToProvisionType(w workloads.Workloader) (*provision.Reservation, er
 		Type:      provision.ReservationType(w.GetWorkloadType().String()),
 		Created:   w.GetEpoch().Time,
 		Duration:  math.MaxInt64, //ensure we never decomission based on expiration time. Since the capacity pool introduction this is not needed anymore
-		Signature: []byte(w.GetCustomerSignature()),
 		ToDelete:  w.GetNextAction() == workloads.NextActionDelete,
 		Reference: w.GetReference(),
 		Result:    resultFromSchemaType(w.GetResult()),
@@ -256,6 +262,24 @@ func WorkloadToProvisionType(w workloads.Workloader) (*provision.Reservation, er
 		err  error
 	)
 
+	// an invalid signature is not a conversion error
+	// the reservation is rejected by the engine when verifying it
+	if signature, err := hex.DecodeString(w.GetCustomerSignature()); err == nil {
+		reservation.Signature = signature
+	}
+
+	// the signature of a workload can't be verified without the
+	// data signed by the customer, so such workloads are never deployed
+	c, ok := w.(signatureChallenger)
+	if !ok {
+		return nil, fmt.Errorf("%w: no signature challenge (%T)", ErrUnsupportedWorkload, w)
+	}
+
+	reservation.Challenge, err = c.SignatureChallenge()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get signature challenge of workload %s", reservation.ID)
+	}
+
 	switch w.GetWorkloadType() {
 	case workloads.WorkloadTypeZDB:
 		data, reservation.NodeID, err = ZDBToProvisionType(w)
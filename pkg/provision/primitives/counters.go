@@ -115,6 +115,9 @@ func (c *Counters) Increment(r *provision.Reservation) error {
 	case KubernetesReservation:
 		c.vms.Increment(1)
 		u, err = processKubernetes(r)
+	case VMReservation:
+		c.vms.Increment(1)
+		u, err = processVM(r)
 	case NetworkReservation, NetworkResourceReservation:
 		c.networks.Increment(1)
 		u = resourceUnits{}
@@ -156,6 +159,9 @@ func (c *Counters) Decrement(r *provision.Reservation) error {
 	case KubernetesReservation:
 		c.vms.Decrement(1)
 		u, err = processKubernetes(r)
+	case VMReservation:
+		c.vms.Decrement(1)
+		u, err = processVM(r)
 	case NetworkReservation, NetworkResourceReservation:
 		c.networks.Decrement(1)
 		u = resourceUnits{}
@@ -242,16 +248,31 @@ func processKubernetes(r *provision.Reservation) (u resourceUnits, err error) {
 		return u, err
 	}
 
-	// size are defined at https://github.com/threefoldtech/zos/blob/master/pkg/provision/kubernetes.go#L311
-	switch k8s.Size {
-	case 1:
-		u.CRU = 1
-		u.MRU = 2 * gib
-		u.SRU = 50 * gib
-	case 2:
-		u.CRU = 2
-		u.MRU = 4 * gib
-		u.SRU = 100 * gib
+	cpu, memory, disk, err := k8s.resources()
+	if err != nil {
+		// unknown sizes are not counted
+		return u, nil
+	}
+
+	u.CRU = uint64(cpu)
+	// memory and disk size are in MiB
+	u.MRU = memory * mib
+	u.SRU = disk * mib
+
+	return u, nil
+}
+
+func processVM(r *provision.Reservation) (u resourceUnits, err error) {
+	var vm VM
+	if err = json.Unmarshal(r.Data, &vm); err != nil {
+		return u, err
+	}
+
+	u.CRU = uint64(vm.CPU)
+	// memory and disks size are in MiB
+	u.MRU = vm.Memory * mib
+	for _, disk := range vm.Disks {
+		u.SRU += disk.Size * mib
 	}
 
 	return u, nil
@@ -5,10 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"reflect"
 	"time"
 
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/environment"
 	"github.com/threefoldtech/zos/pkg/provision"
 	"github.com/threefoldtech/zos/pkg/stubs"
 )
@@ -17,6 +20,8 @@ import (
 type KubernetesResult struct {
 	ID string `json:"id"`
 	IP string `json:"ip"`
+	// ImageHash is the hash of the k3os flist the vm runs
+	ImageHash string `json:"image_hash"`
 }
 
 // Kubernetes reservation data
@@ -24,6 +29,15 @@ type Kubernetes struct {
 	// Size of the vm, this defines the amount of vCpu, memory, and the disk size
 	// Docs: docs/kubernetes/sizes.md
 	Size uint8 `json:"size"`
+	// CPU, Memory and Disk define a custom size for the vm, if any of them
+	// is set they must all be set and Size is ignored. Memory and Disk
+	// are expressed in MiB
+	CPU    uint8  `json:"cpu"`
+	Memory uint64 `json:"memory"`
+	Disk   uint64 `json:"disk"`
+	// FList is the url of the k3os flist to deploy, it defaults to the
+	// official k3os flist and must be allowed by the node environment
+	FList string `json:"flist"`
 
 	// NetworkID of the network namepsace in which to run the VM. The network
 	// must be provisioned previously.
@@ -31,6 +45,11 @@ type Kubernetes struct {
 	// IP of the VM. The IP must be part of the subnet available in the network
 	// resource defined by the networkID on this node
 	IP net.IP `json:"ip"`
+	// Networks are extra network resources the VM is attached to. They are
+	// rejected, k3os only configures the interface of NetworkID
+	Networks []VMNetwork `json:"networks,omitempty"`
+	// PublicIP6 attaches the VM to the public IPv6 network of the node
+	PublicIP6 bool `json:"public_ip6"`
 
 	// ClusterSecret is the hex encoded encrypted cluster secret.
 	ClusterSecret string `json:"cluster_secret"`
@@ -55,7 +74,6 @@ func (p *Provisioner) kubernetesProvision(ctx context.Context, reservation *prov
 func (p *Provisioner) kubernetesProvisionImpl(ctx context.Context, reservation *provision.Reservation) (result KubernetesResult, err error) {
 	var (
 		storage = stubs.NewVDiskModuleStub(p.zbus)
-		network = stubs.NewNetworkerStub(p.zbus)
 		flist   = stubs.NewFlisterStub(p.zbus)
 		vm      = stubs.NewVMModuleStub(p.zbus)
 
@@ -76,18 +94,29 @@ func (p *Provisioner) kubernetesProvisionImpl(ctx context.Context, reservation *
 		return result, errors.Wrap(err, "failed to decrypt namespace password")
 	}
 
-	cpu, memory, disk, err := vmSize(config.Size)
+	cpu, memory, disk, err := config.resources()
 	if err != nil {
 		return result, errors.Wrap(err, "could not interpret vm size")
 	}
 
-	if _, err = vm.Inspect(reservation.ID); err == nil {
+	// k3os doesn't use cloud-init, the kernel only configures one interface
+	if len(config.Networks) > 0 {
+		return result, fmt.Errorf("extra networks are not supported by kubernetes vms")
+	}
+
+	flistURL, err := kubernetesFlist(config)
+	if err != nil {
+		return result, err
+	}
+
+	if info, err := vm.Inspect(reservation.ID); err == nil && info.State == pkg.VMRunning {
 		// vm is already running, nothing to do here
+		result.ImageHash = p.kubernetesImageHash(reservation.ID)
 		return result, nil
 	}
 
 	var imagePath string
-	imagePath, err = flist.NamedMount(reservation.ID, k3osFlistURL, "", pkg.ReadOnlyMountOptions)
+	imagePath, err = flist.NamedMount(reservation.ID, flistURL, "", pkg.ReadOnlyMountOptions)
 	if err != nil {
 		return result, errors.Wrap(err, "could not mount k3os flist")
 	}
@@ -98,48 +127,52 @@ func (p *Provisioner) kubernetesProvisionImpl(ctx context.Context, reservation *
 		}
 	}()
 
+	result.ImageHash, err = flist.HashFromRootPath(reservation.ID)
+	if err != nil {
+		return result, errors.Wrap(err, "could not get hash of k3os flist")
+	}
+
 	var diskPath string
-	diskName := fmt.Sprintf("%s-%s", reservation.ID, "vda")
+	diskName := kubernetesDiskName(reservation.ID)
 	if storage.Exists(diskName) {
 		needsInstall = false
 		info, err := storage.Inspect(diskName)
 		if err != nil {
 			return result, errors.Wrap(err, "could not get path to existing disk")
 		}
-		diskName = info.Path
+		diskPath = info.Path
 	} else {
 		diskPath, err = storage.Allocate(diskName, int64(disk))
 		if err != nil {
 			return result, errors.Wrap(err, "failed to reserve filesystem for vm")
 		}
 	}
-	// clean up the disk anyway, even if it has already been installed.
+	// clean up the disk if it has been allocated by this call, an installed
+	// disk is kept so the vm can be redeployed with its data
 	defer func() {
-		if err != nil {
+		if err != nil && needsInstall {
 			_ = storage.Deallocate(diskName)
 		}
 	}()
 
-	var iface string
-	netID := networkID(reservation.User, string(config.NetworkID))
-	iface, err = network.SetupTap(netID)
+	networks, err := vmNetworks(VMNetwork{NetworkID: config.NetworkID, IP: config.IP}, config.Networks)
 	if err != nil {
-		return result, errors.Wrap(err, "could not set up tap device")
+		return result, errors.Wrap(err, "invalid network configuration")
+	}
+
+	var netInfo pkg.VMNetworkInfo
+	netInfo, err = p.setupVMNetwork(ctx, reservation, networks, config.PublicIP6)
+	if err != nil {
+		return result, errors.Wrap(err, "could not set up vm network")
 	}
 
 	defer func() {
 		if err != nil {
 			_ = vm.Delete(reservation.ID)
-			_ = network.RemoveTap(netID)
+			_ = p.removeVMNetwork(reservation, networks, config.PublicIP6)
 		}
 	}()
 
-	var netInfo pkg.VMNetworkInfo
-	netInfo, err = p.buildNetworkInfo(ctx, reservation.User, iface, config)
-	if err != nil {
-		return result, errors.Wrap(err, "could not generate network info")
-	}
-
 	if needsInstall {
 		if err = p.kubernetesInstall(ctx, reservation.ID, cpu, memory, diskPath, imagePath, netInfo, config); err != nil {
 			return result, errors.Wrap(err, "failed to install k3s")
@@ -150,6 +183,134 @@ func (p *Provisioner) kubernetesProvisionImpl(ctx context.Context, reservation *
 	return result, err
 }
 
+func (p *Provisioner) kubernetesUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	return p.kubernetesUpdateImpl(ctx, old, reservation)
+}
+
+// kubernetesUpdateImpl applies a new size to the vm. The disk is grown while
+// the vm is running, a change of cpu or memory restarts the vm
+func (p *Provisioner) kubernetesUpdateImpl(ctx context.Context, old, reservation *provision.Reservation) (result KubernetesResult, err error) {
+	vm := stubs.NewVMModuleStub(p.zbus)
+
+	var current, config Kubernetes
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	result.ID = reservation.ID
+	result.IP = config.IP.String()
+
+	if err := checkKubernetesUpdate(current, config); err != nil {
+		return result, err
+	}
+
+	currentCPU, currentMemory, currentDisk, err := current.resources()
+	if err != nil {
+		return result, errors.Wrap(err, "could not interpret vm size")
+	}
+
+	cpu, memory, disk, err := config.resources()
+	if err != nil {
+		return result, errors.Wrap(err, "could not interpret vm size")
+	}
+
+	result.ImageHash = p.kubernetesImageHash(reservation.ID)
+	if cpu == currentCPU && memory == currentMemory && disk == currentDisk {
+		return result, nil
+	}
+
+	if disk < currentDisk {
+		return result, fmt.Errorf("disk of a kubernetes vm cannot shrink from %d to %d MiB", currentDisk, disk)
+	}
+
+	// the vm is only changed once the new configuration is known to be usable
+	if _, err := decryptSecret(p.zbus, config.ClusterSecret); err != nil {
+		return result, errors.Wrap(err, "failed to decrypt namespace password")
+	}
+
+	log.Info().
+		Str("id", reservation.ID).
+		Uint8("cpu", cpu).
+		Uint64("memory", memory).
+		Uint64("disk", disk).
+		Msg("resizing kubernetes vm")
+
+	if err := p.resizeVMDisk(reservation.ID, kubernetesDiskName(reservation.ID), disk); err != nil {
+		return result, err
+	}
+
+	if cpu == currentCPU && memory == currentMemory {
+		return result, nil
+	}
+
+	// firecracker cannot change the cpu or memory of a running vm
+	if err := vm.Delete(reservation.ID); err != nil {
+		return result, errors.Wrapf(err, "failed to delete vm %s", reservation.ID)
+	}
+
+	result, err = p.kubernetesProvisionImpl(ctx, reservation)
+	if err != nil {
+		// the vm is started again with its previous size, so a failed
+		// update doesn't take the workload down
+		if _, err := p.kubernetesProvisionImpl(ctx, old); err != nil {
+			log.Error().Err(err).Str("id", reservation.ID).Msg("failed to restart kubernetes vm with its previous size")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+// checkKubernetesUpdate makes sure only the size of the vm changed
+func checkKubernetesUpdate(current, config Kubernetes) error {
+	current.Size = config.Size
+	current.CPU = config.CPU
+	current.Memory = config.Memory
+	current.Disk = config.Disk
+	// the secret is encrypted again each time the reservation is signed
+	current.ClusterSecret = config.ClusterSecret
+
+	if !reflect.DeepEqual(current, config) {
+		return fmt.Errorf("only the size of a kubernetes vm can be updated")
+	}
+
+	return nil
+}
+
+// kubernetesPrefetch downloads the flist of the vm ahead of its provisioning
+func (p *Provisioner) kubernetesPrefetch(ctx context.Context, reservation *provision.Reservation) error {
+	var config Kubernetes
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	flistURL, err := kubernetesFlist(config)
+	if err != nil {
+		return err
+	}
+
+	return stubs.NewFlisterStub(p.zbus).Prefetch(flistURL, "")
+}
+
+// kubernetesImageHash returns the hash of the k3os flist mounted for
+// a running vm, or an empty string if it cannot be found
+func (p *Provisioner) kubernetesImageHash(id string) string {
+	hash, err := stubs.NewFlisterStub(p.zbus).HashFromRootPath(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to get hash of k3os flist")
+	}
+
+	return hash
+}
+
+// kubernetesDiskName returns the name of the vdisk of a kubernetes vm
+func kubernetesDiskName(id string) string {
+	return fmt.Sprintf("%s-%s", id, "vda")
+}
+
 func (p *Provisioner) kubernetesInstall(ctx context.Context, name string, cpu uint8, memory uint64, diskPath string, imagePath string, networkInfo pkg.VMNetworkInfo, cfg Kubernetes) error {
 	vm := stubs.NewVMModuleStub(p.zbus)
 
@@ -236,7 +397,6 @@ func (p *Provisioner) kubernetesRun(ctx context.Context, name string, cpu uint8,
 func (p *Provisioner) kubernetesDecomission(ctx context.Context, reservation *provision.Reservation) error {
 	var (
 		storage = stubs.NewVDiskModuleStub(p.zbus)
-		network = stubs.NewNetworkerStub(p.zbus)
 		flist   = stubs.NewFlisterStub(p.zbus)
 		vm      = stubs.NewVMModuleStub(p.zbus)
 
@@ -253,12 +413,17 @@ func (p *Provisioner) kubernetesDecomission(ctx context.Context, reservation *pr
 		}
 	}
 
-	netID := networkID(reservation.User, string(cfg.NetworkID))
-	if err := network.RemoveTap(netID); err != nil {
-		return errors.Wrap(err, "could not clean up tap device")
+	primary := VMNetwork{NetworkID: cfg.NetworkID, IP: cfg.IP}
+	networks := append([]VMNetwork{primary}, cfg.Networks...)
+	if err := p.removeVMNetwork(reservation, networks, cfg.PublicIP6); err != nil {
+		return errors.Wrap(err, "could not clean up vm network")
 	}
 
-	if err := storage.Deallocate(fmt.Sprintf("%s-%s", reservation.ID, "vda")); err != nil {
+	if err := p.removeLegacyVMNetwork(reservation, primary); err != nil {
+		return errors.Wrap(err, "could not clean up vm network")
+	}
+
+	if err := storage.Deallocate(kubernetesDiskName(reservation.ID)); err != nil {
 		return errors.Wrap(err, "could not remove vDisk")
 	}
 
@@ -269,38 +434,44 @@ func (p *Provisioner) kubernetesDecomission(ctx context.Context, reservation *pr
 	return nil
 }
 
-func (p *Provisioner) buildNetworkInfo(ctx context.Context, userID string, iface string, cfg Kubernetes) (pkg.VMNetworkInfo, error) {
-	network := stubs.NewNetworkerStub(p.zbus)
+// resources returns the vCpu's, memory and disk size of the vm, either the
+// custom ones or the ones of its size. Memory and disk size is expressed in MiB
+func (k *Kubernetes) resources() (uint8, uint64, uint64, error) {
+	if k.CPU == 0 && k.Memory == 0 && k.Disk == 0 {
+		return vmSize(k.Size)
+	}
 
-	netID := networkID(userID, string(cfg.NetworkID))
-	subnet, err := network.GetSubnet(netID)
-	if err != nil {
-		return pkg.VMNetworkInfo{}, errors.Wrapf(err, "could not get network resource subnet")
+	if k.CPU == 0 || k.CPU > 32 {
+		return 0, 0, 0, fmt.Errorf("invalid cpu must be between 1 and 32")
+	}
+
+	if k.Memory < 512 {
+		return 0, 0, 0, fmt.Errorf("invalid memory must not be less than 512M")
 	}
 
-	if !subnet.Contains(cfg.IP) {
-		return pkg.VMNetworkInfo{}, fmt.Errorf("IP %s is not part of local nr subnet %s", cfg.IP.String(), subnet.String())
+	if k.Disk == 0 {
+		return 0, 0, 0, fmt.Errorf("disk size cannot be 0")
 	}
 
-	addrCIDR := net.IPNet{
-		IP:   cfg.IP,
-		Mask: subnet.Mask,
+	return k.CPU, k.Memory, k.Disk, nil
+}
+
+// kubernetesFlist returns the url of the flist to deploy the vm from
+func kubernetesFlist(k Kubernetes) (string, error) {
+	if k.FList == "" {
+		return k3osFlistURL, nil
 	}
 
-	gw, err := network.GetDefaultGwIP(netID)
+	env, err := environment.Get()
 	if err != nil {
-		return pkg.VMNetworkInfo{}, errors.Wrapf(err, "could not get network resource default gateway")
+		return "", errors.Wrap(err, "failed to get node environment")
 	}
 
-	networkInfo := pkg.VMNetworkInfo{
-		Tap:         iface,
-		MAC:         "", // rely on static IP configuration so we don't care here
-		AddressCIDR: addrCIDR,
-		GatewayIP:   net.IP(gw),
-		Nameservers: []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")},
+	if !env.KubernetesFlistAllowed(k.FList) {
+		return "", fmt.Errorf("flist '%s' is not allowed for kubernetes vms", k.FList)
 	}
 
-	return networkInfo, nil
+	return k.FList, nil
 }
 
 // returns the vCpu's, memory, disksize for a vm size
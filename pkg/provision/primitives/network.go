@@ -45,6 +45,30 @@ func (p *Provisioner) networkProvision(ctx context.Context, reservation *provisi
 	return nil, p.networkProvisionImpl(ctx, reservation)
 }
 
+// networkUpdate applies the changes made to a network resource, like adding or removing peers
+func (p *Provisioner) networkUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	current := pkg.NetResource{}
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal network from reservation: %w", err)
+	}
+
+	nr := pkg.NetResource{}
+	if err := json.Unmarshal(reservation.Data, &nr); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal network from reservation: %w", err)
+	}
+
+	if current.Name != nr.Name {
+		return nil, fmt.Errorf("name of a network resource cannot be updated")
+	}
+
+	if current.Subnet.String() != nr.Subnet.String() {
+		return nil, fmt.Errorf("subnet of a network resource cannot be updated")
+	}
+
+	// CreateNR reconfigures an existing network resource in place
+	return nil, p.networkProvisionImpl(ctx, reservation)
+}
+
 func (p *Provisioner) networkDecommission(ctx context.Context, reservation *provision.Reservation) error {
 	mgr := stubs.NewNetworkerStub(p.zbus)
 
@@ -112,6 +136,14 @@ func validateNR(nr pkg.NetResource) error {
 		}
 	}
 
+	if err := nr.Firewall.Validate(); err != nil {
+		return fmt.Errorf("network resource firewall is invalid: %w", err)
+	}
+
+	if err := nr.Bandwidth.Validate(); err != nil {
+		return fmt.Errorf("network resource bandwidth is invalid: %w", err)
+	}
+
 	return nil
 }
 
@@ -17,6 +17,10 @@ const (
 	DebugReservation provision.ReservationType = "debug"
 	// KubernetesReservation type
 	KubernetesReservation provision.ReservationType = "kubernetes"
+	// VMReservation type
+	VMReservation provision.ReservationType = "vm"
+	// PortForwardReservation type
+	PortForwardReservation provision.ReservationType = "port_forward"
 )
 
 // ProvisionOrder is used to sort the workload type
@@ -29,4 +33,6 @@ var ProvisionOrder = map[provision.ReservationType]int{
 	VolumeReservation:          4,
 	ContainerReservation:       5,
 	KubernetesReservation:      6,
+	VMReservation:              6,
+	PortForwardReservation:     7,
 }
@@ -0,0 +1,71 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+// PortForwardResult result returned by a port forward reservation
+type PortForwardResult struct {
+	ID string `json:"id"`
+	// PublicPort is the port allocated on the public IPv4 of the node
+	PublicPort uint16 `json:"public_port"`
+}
+
+// PortForward reservation data, it exposes a port of a workload in
+// a network resource on the public IPv4 of the node
+type PortForward struct {
+	// NetworkID is the name of the network of the workload, like for
+	// containers. Its network resource must be provisioned previously.
+	NetworkID pkg.NetID `json:"network_id"`
+	// Protocol of the port, either tcp or udp
+	Protocol string `json:"protocol"`
+	// IP of the workload in the network resource
+	IP net.IP `json:"ip"`
+	// Port of the workload to expose
+	Port uint16 `json:"port"`
+}
+
+func (p *Provisioner) portForwardProvision(ctx context.Context, reservation *provision.Reservation) (interface{}, error) {
+	return p.portForwardProvisionImpl(ctx, reservation)
+}
+
+func (p *Provisioner) portForwardProvisionImpl(ctx context.Context, reservation *provision.Reservation) (result PortForwardResult, err error) {
+	network := stubs.NewNetworkerStub(p.zbus)
+
+	var config PortForward
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	// the network resource is looked up in the networks of the tenant
+	netID := networkID(reservation.User, string(config.NetworkID))
+
+	result.ID = reservation.ID
+	result.PublicPort, err = network.AddPortForward(netID, reservation.ID, pkg.PortForward{
+		Protocol: config.Protocol,
+		IP:       config.IP,
+		Port:     config.Port,
+	})
+	if err != nil {
+		return result, errors.Wrap(err, "failed to forward port")
+	}
+
+	return result, nil
+}
+
+func (p *Provisioner) portForwardDecommission(ctx context.Context, reservation *provision.Reservation) error {
+	network := stubs.NewNetworkerStub(p.zbus)
+
+	if err := network.RemovePortForward(reservation.ID); err != nil {
+		return errors.Wrap(err, "failed to remove port forward")
+	}
+
+	return nil
+}
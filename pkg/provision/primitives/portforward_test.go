@@ -0,0 +1,56 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zbus"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/provision"
+)
+
+// testClient records the requests made through the stubs. Requests
+// fail, which makes the stubs panic once the request is recorded
+type testClient struct {
+	method string
+	args   []interface{}
+}
+
+func (c *testClient) Request(module string, object zbus.ObjectID, method string, args ...interface{}) (*zbus.Response, error) {
+	c.method = method
+	c.args = args
+	return nil, fmt.Errorf("request not supported")
+}
+
+func (c *testClient) Stream(ctx context.Context, module string, object zbus.ObjectID, event string) (<-chan zbus.Event, error) {
+	return nil, fmt.Errorf("stream not supported")
+}
+
+func TestPortForwardNetworkID(t *testing.T) {
+	require := require.New(t)
+
+	data, err := json.Marshal(PortForward{
+		NetworkID: "net",
+		Protocol:  "tcp",
+		IP:        net.ParseIP("10.1.1.10"),
+		Port:      8080,
+	})
+	require.NoError(err)
+
+	client := &testClient{}
+	p := &Provisioner{zbus: client}
+
+	reservation := &provision.Reservation{ID: "1-1", User: "12", Data: data}
+	require.Panics(func() {
+		_, _ = p.portForwardProvisionImpl(context.Background(), reservation)
+	})
+
+	// the network resource of the tenant is used, never the raw id
+	require.Equal("AddPortForward", client.method)
+	require.Equal(networkID("12", "net"), client.args[0])
+	require.NotEqual(pkg.NetID("net"), client.args[0])
+}
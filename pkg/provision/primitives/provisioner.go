@@ -15,6 +15,11 @@ type Provisioner struct {
 
 	Provisioners    map[provision.ReservationType]provision.ProvisionerFunc
 	Decommissioners map[provision.ReservationType]provision.DecomissionerFunc
+	Updaters        map[provision.ReservationType]provision.UpdaterFunc
+	Checkers        map[provision.ReservationType]provision.CheckerFunc
+	Suspenders      map[provision.ReservationType]provision.SuspenderFunc
+	Resumers        map[provision.ReservationType]provision.SuspenderFunc
+	Prefetchers     map[provision.ReservationType]provision.PrefetcherFunc
 }
 
 // NewProvisioner creates a new 0-OS provisioner
@@ -31,6 +36,8 @@ func NewProvisioner(cache provision.ReservationCache, zbus zbus.Client) *Provisi
 		ZDBReservation:             p.zdbProvision,
 		DebugReservation:           p.debugProvision,
 		KubernetesReservation:      p.kubernetesProvision,
+		VMReservation:              p.vmProvision,
+		PortForwardReservation:     p.portForwardProvision,
 	}
 	p.Decommissioners = map[provision.ReservationType]provision.DecomissionerFunc{
 		ContainerReservation:       p.containerDecommission,
@@ -40,6 +47,36 @@ func NewProvisioner(cache provision.ReservationCache, zbus zbus.Client) *Provisi
 		ZDBReservation:             p.zdbDecommission,
 		DebugReservation:           p.debugDecommission,
 		KubernetesReservation:      p.kubernetesDecomission,
+		VMReservation:              p.vmDecomission,
+		PortForwardReservation:     p.portForwardDecommission,
+	}
+	p.Updaters = map[provision.ReservationType]provision.UpdaterFunc{
+		ContainerReservation:       p.containerUpdate,
+		VolumeReservation:          p.volumeUpdate,
+		NetworkReservation:         p.networkUpdate,
+		NetworkResourceReservation: p.networkUpdate,
+		ZDBReservation:             p.zdbUpdate,
+		KubernetesReservation:      p.kubernetesUpdate,
+		VMReservation:              p.vmUpdate,
+	}
+	p.Checkers = map[provision.ReservationType]provision.CheckerFunc{
+		ContainerReservation:       p.containerCheck,
+		VolumeReservation:          p.volumeCheck,
+		NetworkReservation:         p.networkCheck,
+		NetworkResourceReservation: p.networkCheck,
+		ZDBReservation:             p.zdbCheck,
+		KubernetesReservation:      p.kubernetesCheck,
+		VMReservation:              p.vmCheck,
+	}
+	p.Suspenders = map[provision.ReservationType]provision.SuspenderFunc{
+		ContainerReservation: p.containerSuspend,
+	}
+	p.Resumers = map[provision.ReservationType]provision.SuspenderFunc{
+		ContainerReservation: p.containerResume,
+	}
+	p.Prefetchers = map[provision.ReservationType]provision.PrefetcherFunc{
+		ContainerReservation:  p.containerPrefetch,
+		KubernetesReservation: p.kubernetesPrefetch,
 	}
 
 	return p
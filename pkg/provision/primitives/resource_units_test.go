@@ -242,6 +242,28 @@ func Test_processKubernetes(t *testing.T) {
 	}
 }
 
+func Test_processVM(t *testing.T) {
+	r := &provision.Reservation{
+		Type: VMReservation,
+		Data: mustMarshalJSON(t, VM{
+			CPU:    2,
+			Memory: 2048,
+			Disks: []VMDisk{
+				{Size: 10 * 1024},
+				{Size: 5 * 1024},
+			},
+		}),
+	}
+
+	u, err := processVM(r)
+	require.NoError(t, err)
+	assert.Equal(t, resourceUnits{
+		CRU: 2,
+		MRU: 2 * gib,
+		SRU: 15 * gib,
+	}, u)
+}
+
 func mustMarshalJSON(t *testing.T, v interface{}) []byte {
 	b, err := json.Marshal(v)
 	require.NoError(t, err)
@@ -0,0 +1,380 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+const (
+	// vmKernel is the path of the kernel in the vm flist
+	vmKernel = "vmlinux"
+	// vmInitrd is the path of the optional initrd in the vm flist
+	vmInitrd = "initrd.img"
+
+	vmKernelArgs = "console=ttyS0 reboot=k panic=1"
+)
+
+// VMResult result returned by a vm reservation
+type VMResult struct {
+	ID string `json:"id"`
+	IP string `json:"ip"`
+}
+
+// VMDisk is a virtual disk attached to a vm. The disk is allocated empty,
+// it's up to the vm to format it
+type VMDisk struct {
+	// Size of the disk in MiB
+	Size uint64 `json:"size"`
+}
+
+// VM reservation data
+type VM struct {
+	// FList containing the image to boot. The flist must contain an uncompressed
+	// kernel at /vmlinux and can contain an initrd at /initrd.img
+	FList string `json:"flist"`
+	// KernelArgs are extra arguments appended to the kernel command line
+	KernelArgs string `json:"kernel_args"`
+
+	// CPU is the number of vCPU of the vm
+	CPU uint8 `json:"cpu"`
+	// Memory of the vm in MiB
+	Memory uint64 `json:"memory"`
+	// Disks attached to the vm, in order. The first disk is /dev/vda
+	Disks []VMDisk `json:"disks"`
+
+	// NetworkID of the network namepsace in which to run the VM. The network
+	// must be provisioned previously.
+	NetworkID pkg.NetID `json:"network_id"`
+	// IP of the VM. The IP must be part of the subnet available in the network
+	// resource defined by the networkID on this node
+	IP net.IP `json:"ip"`
+	// Networks are extra network resources the VM is attached to, each one
+	// with its own interface
+	Networks []VMNetwork `json:"networks,omitempty"`
+	// PublicIP6 attaches the VM to the public IPv6 network of the node
+	PublicIP6 bool `json:"public_ip6"`
+
+	// UserData is the cloud-init user data passed to the VM
+	UserData string `json:"user_data"`
+	// SSHKeys is a list of ssh keys to add to the VM with cloud-init
+	SSHKeys []string `json:"ssh_keys"`
+}
+
+func validateVMConfig(config VM) error {
+	if config.FList == "" {
+		return fmt.Errorf("missing flist url")
+	}
+
+	if config.NetworkID == "" {
+		return fmt.Errorf("network ID cannot be empty")
+	}
+
+	if config.IP == nil {
+		return fmt.Errorf("missing vm IP address")
+	}
+
+	if config.CPU == 0 || config.CPU > 32 {
+		return fmt.Errorf("invalid cpu must be between 1 and 32")
+	}
+
+	if config.Memory < 512 {
+		return fmt.Errorf("invalid memory must not be less than 512M")
+	}
+
+	for i, disk := range config.Disks {
+		if disk.Size == 0 {
+			return fmt.Errorf("size of disk %d cannot be 0", i)
+		}
+	}
+
+	return nil
+}
+
+// vmDiskName returns the name of the vdisk at index of a vm
+func vmDiskName(id string, index int) string {
+	return fmt.Sprintf("%s-disk%d", id, index)
+}
+
+func (p *Provisioner) vmProvision(ctx context.Context, reservation *provision.Reservation) (interface{}, error) {
+	return p.vmProvisionImpl(ctx, reservation)
+}
+
+func (p *Provisioner) vmProvisionImpl(ctx context.Context, reservation *provision.Reservation) (result VMResult, err error) {
+	var (
+		storage = stubs.NewVDiskModuleStub(p.zbus)
+		flist   = stubs.NewFlisterStub(p.zbus)
+		vm      = stubs.NewVMModuleStub(p.zbus)
+
+		config VM
+	)
+
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	if err := validateVMConfig(config); err != nil {
+		return result, errors.Wrap(err, "invalid vm configuration")
+	}
+
+	networks, err := vmNetworks(VMNetwork{NetworkID: config.NetworkID, IP: config.IP}, config.Networks)
+	if err != nil {
+		return result, errors.Wrap(err, "invalid network configuration")
+	}
+
+	result.ID = reservation.ID
+	result.IP = config.IP.String()
+
+	if info, err := vm.Inspect(reservation.ID); err == nil && info.State == pkg.VMRunning {
+		// vm is already running, nothing to do here
+		return result, nil
+	}
+
+	var imagePath string
+	imagePath, err = flist.NamedMount(reservation.ID, config.FList, "", pkg.ReadOnlyMountOptions)
+	if err != nil {
+		return result, errors.Wrap(err, "could not mount vm flist")
+	}
+	// In case of future errors in the provisioning make sure we clean up
+	defer func() {
+		if err != nil {
+			_ = flist.Umount(imagePath)
+		}
+	}()
+
+	var initrd string
+	if _, err := os.Stat(filepath.Join(imagePath, vmInitrd)); err == nil {
+		initrd = filepath.Join(imagePath, vmInitrd)
+	}
+
+	disks := make([]pkg.VMDisk, 0, len(config.Disks))
+	for i, disk := range config.Disks {
+		name := vmDiskName(reservation.ID, i)
+		if storage.Exists(name) {
+			// the vm is redeployed, keep its data
+			var info pkg.VDisk
+			info, err = storage.Inspect(name)
+			if err != nil {
+				return result, errors.Wrapf(err, "could not get path to existing disk %s", name)
+			}
+			disks = append(disks, pkg.VMDisk{Path: info.Path})
+			continue
+		}
+
+		var path string
+		path, err = storage.Allocate(name, int64(disk.Size))
+		if err != nil {
+			return result, errors.Wrapf(err, "failed to allocate disk %s", name)
+		}
+		// only clean up the disks allocated by this call
+		defer func() {
+			if err != nil {
+				_ = storage.Deallocate(name)
+			}
+		}()
+
+		disks = append(disks, pkg.VMDisk{Path: path})
+	}
+
+	var netInfo pkg.VMNetworkInfo
+	netInfo, err = p.setupVMNetwork(ctx, reservation, networks, config.PublicIP6)
+	if err != nil {
+		return result, errors.Wrap(err, "could not set up vm network")
+	}
+
+	defer func() {
+		if err != nil {
+			_ = vm.Delete(reservation.ID)
+			_ = p.removeVMNetwork(reservation, networks, config.PublicIP6)
+		}
+	}()
+
+	cmdline := vmKernelArgs
+	if config.KernelArgs != "" {
+		cmdline = fmt.Sprintf("%s %s", cmdline, config.KernelArgs)
+	}
+
+	err = vm.Run(pkg.VM{
+		Name:        reservation.ID,
+		CPU:         config.CPU,
+		Memory:      int64(config.Memory),
+		Network:     netInfo,
+		KernelImage: filepath.Join(imagePath, vmKernel),
+		InitrdImage: initrd,
+		KernelArgs:  cmdline,
+		Disks:       disks,
+		CloudInit: &pkg.VMCloudInit{
+			UserData: config.UserData,
+			SSHKeys:  config.SSHKeys,
+		},
+	})
+
+	return result, err
+}
+
+func (p *Provisioner) vmUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	return p.vmUpdateImpl(ctx, old, reservation)
+}
+
+// vmUpdateImpl grows the disks of the vm and applies the new cpu and memory.
+// Disks are grown while the vm is running, a change of cpu or memory restarts the vm
+func (p *Provisioner) vmUpdateImpl(ctx context.Context, old, reservation *provision.Reservation) (result VMResult, err error) {
+	vm := stubs.NewVMModuleStub(p.zbus)
+
+	var current, config VM
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return result, errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	if err := validateVMConfig(config); err != nil {
+		return result, errors.Wrap(err, "invalid vm configuration")
+	}
+
+	result.ID = reservation.ID
+	result.IP = config.IP.String()
+
+	if err := checkVMUpdate(current, config); err != nil {
+		return result, err
+	}
+
+	for i, disk := range config.Disks {
+		if disk.Size == current.Disks[i].Size {
+			continue
+		}
+
+		log.Info().
+			Str("id", reservation.ID).
+			Int("disk", i).
+			Uint64("from", current.Disks[i].Size).
+			Uint64("to", disk.Size).
+			Msg("resizing vm disk")
+
+		if err := p.resizeVMDisk(reservation.ID, vmDiskName(reservation.ID, i), disk.Size); err != nil {
+			return result, err
+		}
+	}
+
+	if config.CPU == current.CPU && config.Memory == current.Memory {
+		return result, nil
+	}
+
+	// firecracker cannot change the cpu or memory of a running vm
+	if err := vm.Delete(reservation.ID); err != nil {
+		return result, errors.Wrapf(err, "failed to delete vm %s", reservation.ID)
+	}
+
+	result, err = p.vmProvisionImpl(ctx, reservation)
+	if err != nil {
+		// the vm is started again with its previous cpu and memory, so a
+		// failed update doesn't take the workload down
+		if _, err := p.vmProvisionImpl(ctx, old); err != nil {
+			log.Error().Err(err).Str("id", reservation.ID).Msg("failed to restart vm with its previous configuration")
+		}
+		return result, err
+	}
+
+	return result, nil
+}
+
+// checkVMUpdate makes sure only the cpu, memory and size of the disks of the vm changed
+func checkVMUpdate(current, config VM) error {
+	if len(current.Disks) != len(config.Disks) {
+		return fmt.Errorf("disks cannot be added to or removed from a vm")
+	}
+
+	for i := range config.Disks {
+		if config.Disks[i].Size < current.Disks[i].Size {
+			return fmt.Errorf("disk %d of a vm cannot shrink", i)
+		}
+	}
+
+	current.CPU = config.CPU
+	current.Memory = config.Memory
+	current.Disks = config.Disks
+
+	if !reflect.DeepEqual(current, config) {
+		return fmt.Errorf("only the cpu, memory and disk sizes of a vm can be updated")
+	}
+
+	return nil
+}
+
+// resizeVMDisk grows the vdisk of the vm to size (in MiB), and makes the vm
+// see the new size if it's running
+func (p *Provisioner) resizeVMDisk(id, disk string, size uint64) error {
+	var (
+		storage = stubs.NewVDiskModuleStub(p.zbus)
+		vm      = stubs.NewVMModuleStub(p.zbus)
+	)
+
+	path, err := storage.Resize(disk, int64(size))
+	if err != nil {
+		return errors.Wrapf(err, "failed to resize disk %s", disk)
+	}
+
+	if info, err := vm.Inspect(id); err != nil || info.State != pkg.VMRunning {
+		// the new size is picked up when the vm starts
+		return nil
+	}
+
+	if err := vm.RescanDisk(id, path); err != nil {
+		return errors.Wrapf(err, "failed to notify vm of the new size of disk %s", disk)
+	}
+
+	return nil
+}
+
+func (p *Provisioner) vmDecomission(ctx context.Context, reservation *provision.Reservation) error {
+	var (
+		storage = stubs.NewVDiskModuleStub(p.zbus)
+		flist   = stubs.NewFlisterStub(p.zbus)
+		vm      = stubs.NewVMModuleStub(p.zbus)
+
+		config VM
+	)
+
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	if _, err := vm.Inspect(reservation.ID); err == nil {
+		if err := vm.Delete(reservation.ID); err != nil {
+			return errors.Wrapf(err, "failed to delete vm %s", reservation.ID)
+		}
+	}
+
+	primary := VMNetwork{NetworkID: config.NetworkID, IP: config.IP}
+	networks := append([]VMNetwork{primary}, config.Networks...)
+	if err := p.removeVMNetwork(reservation, networks, config.PublicIP6); err != nil {
+		return errors.Wrap(err, "could not clean up vm network")
+	}
+
+	if err := p.removeLegacyVMNetwork(reservation, primary); err != nil {
+		return errors.Wrap(err, "could not clean up vm network")
+	}
+
+	for i := range config.Disks {
+		if err := storage.Deallocate(vmDiskName(reservation.ID, i)); err != nil {
+			return errors.Wrap(err, "could not remove vDisk")
+		}
+	}
+
+	if err := flist.NamedUmount(reservation.ID); err != nil {
+		return errors.Wrap(err, "could not unmount flist")
+	}
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package primitives
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVMUpdate(t *testing.T) {
+	current := VM{
+		FList:     "https://hub.grid.tf/tf-official-apps/ubuntu.flist",
+		CPU:       1,
+		Memory:    1024,
+		Disks:     []VMDisk{{Size: 1024}},
+		NetworkID: "net",
+		IP:        net.ParseIP("10.1.1.10"),
+	}
+
+	t.Run("size", func(t *testing.T) {
+		config := current
+		config.CPU = 2
+		config.Memory = 2048
+		config.Disks = []VMDisk{{Size: 2048}}
+		assert.NoError(t, checkVMUpdate(current, config))
+	})
+	t.Run("shrink", func(t *testing.T) {
+		config := current
+		config.Disks = []VMDisk{{Size: 512}}
+		assert.Error(t, checkVMUpdate(current, config))
+	})
+	t.Run("add_disk", func(t *testing.T) {
+		config := current
+		config.Disks = []VMDisk{{Size: 1024}, {Size: 1024}}
+		assert.Error(t, checkVMUpdate(current, config))
+	})
+	t.Run("ip", func(t *testing.T) {
+		config := current
+		config.IP = net.ParseIP("10.1.1.11")
+		assert.Error(t, checkVMUpdate(current, config))
+	})
+}
+
+func TestCheckKubernetesUpdate(t *testing.T) {
+	current := Kubernetes{
+		Size:          1,
+		NetworkID:     "net",
+		IP:            net.ParseIP("10.1.1.10"),
+		ClusterSecret: "secret",
+	}
+
+	config := current
+	config.Size = 2
+	config.ClusterSecret = "encrypted again"
+	assert.NoError(t, checkKubernetesUpdate(current, config))
+
+	config.CPU = 4
+	config.Memory = 8 * 1024
+	config.Disk = 200 * 1024
+	assert.NoError(t, checkKubernetesUpdate(current, config))
+
+	config.FList = "https://hub.grid.tf/tf-official-apps/k3os-v1.18.flist"
+	assert.Error(t, checkKubernetesUpdate(current, config))
+
+	config.FList = current.FList
+	config.SSHKeys = []string{"github:user"}
+	assert.Error(t, checkKubernetesUpdate(current, config))
+}
+
+func TestKubernetesResources(t *testing.T) {
+	cpu, memory, disk, err := (&Kubernetes{Size: 2}).resources()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(2), cpu)
+	assert.Equal(t, uint64(4*1024), memory)
+	assert.Equal(t, uint64(100*1024), disk)
+
+	cpu, memory, disk, err = (&Kubernetes{Size: 1, CPU: 4, Memory: 8 * 1024, Disk: 200 * 1024}).resources()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(4), cpu)
+	assert.Equal(t, uint64(8*1024), memory)
+	assert.Equal(t, uint64(200*1024), disk)
+
+	_, _, _, err = (&Kubernetes{Size: 3}).resources()
+	assert.Error(t, err)
+
+	// custom sizes must be complete
+	_, _, _, err = (&Kubernetes{CPU: 4, Memory: 8 * 1024}).resources()
+	assert.Error(t, err)
+}
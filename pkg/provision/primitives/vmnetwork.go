@@ -0,0 +1,175 @@
+package primitives
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+// VMNetwork is the attachment of a VM to a network resource
+type VMNetwork struct {
+	// NetworkID of the network resource. The network must be provisioned previously.
+	NetworkID pkg.NetID `json:"network_id"`
+	// IP of the VM in the network. The IP must be part of the subnet
+	// available in the network resource on this node
+	IP net.IP `json:"ip"`
+}
+
+// vmNetworks returns all the network attachments of a VM, the primary
+// attachment, which holds the default route, first
+func vmNetworks(primary VMNetwork, extra []VMNetwork) ([]VMNetwork, error) {
+	networks := append([]VMNetwork{primary}, extra...)
+
+	seen := make(map[pkg.NetID]struct{}, len(networks))
+	for _, network := range networks {
+		if network.NetworkID == "" {
+			return nil, fmt.Errorf("network ID cannot be empty")
+		}
+
+		if network.IP == nil {
+			return nil, fmt.Errorf("missing IP address in network %s", network.NetworkID)
+		}
+
+		if _, ok := seen[network.NetworkID]; ok {
+			return nil, fmt.Errorf("network %s is attached more than once", network.NetworkID)
+		}
+		seen[network.NetworkID] = struct{}{}
+	}
+
+	return networks, nil
+}
+
+// setupVMNetwork creates a tap device for each network attachment of the
+// reservation VM, and a public tap if public is true. It returns the network
+// configuration of the VM.
+func (p *Provisioner) setupVMNetwork(ctx context.Context, reservation *provision.Reservation, networks []VMNetwork, public bool) (info pkg.VMNetworkInfo, err error) {
+	networker := stubs.NewNetworkerStub(p.zbus)
+
+	defer func() {
+		if err != nil {
+			if err := p.removeVMNetwork(reservation, networks, public); err != nil {
+				log.Error().Err(err).Str("id", reservation.ID).Msg("failed to clean up vm network")
+			}
+		}
+	}()
+
+	for i, network := range networks {
+		netID := networkID(reservation.User, string(network.NetworkID))
+
+		var tap string
+		tap, err = networker.SetupTap(netID, reservation.ID)
+		if err != nil {
+			return info, errors.Wrapf(err, "could not set up tap device in network %s", network.NetworkID)
+		}
+
+		var iface pkg.VMIface
+		// only the primary network holds the default route
+		iface, err = p.buildVMIface(ctx, netID, tap, network.IP, i == 0)
+		if err != nil {
+			return info, errors.Wrapf(err, "could not generate network info of network %s", network.NetworkID)
+		}
+
+		info.Ifaces = append(info.Ifaces, iface)
+	}
+
+	if public {
+		var tap string
+		tap, err = networker.SetupPubTap(reservation.ID)
+		if err != nil {
+			return info, errors.Wrap(err, "could not set up public tap device")
+		}
+
+		// the address is configured by the VM with IPv6 autoconfiguration
+		info.Ifaces = append(info.Ifaces, pkg.VMIface{Tap: tap})
+	}
+
+	info.Nameservers = []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")}
+
+	return info, nil
+}
+
+// removeVMNetwork removes the tap devices created by setupVMNetwork. All the
+// devices are removed even if some of them fail, so none of them is leaked
+func (p *Provisioner) removeVMNetwork(reservation *provision.Reservation, networks []VMNetwork, public bool) error {
+	networker := stubs.NewNetworkerStub(p.zbus)
+
+	var failed []string
+	for _, network := range networks {
+		netID := networkID(reservation.User, string(network.NetworkID))
+		if err := networker.RemoveTap(netID, reservation.ID); err != nil {
+			log.Error().Err(err).Str("id", reservation.ID).Str("network", string(network.NetworkID)).Msg("could not remove tap device")
+			failed = append(failed, string(network.NetworkID))
+		}
+	}
+
+	if public {
+		if err := networker.RemovePubTap(reservation.ID); err != nil {
+			log.Error().Err(err).Str("id", reservation.ID).Msg("could not remove public tap device")
+			failed = append(failed, "public")
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("could not remove tap devices of networks %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// removeLegacyVMNetwork removes the tap device of the vms deployed before
+// each vm got its own tap, they are only attached to their primary network.
+// It is only called when the vm is decommissioned, the networker keeps the
+// tap if another vm is still attached to it
+func (p *Provisioner) removeLegacyVMNetwork(reservation *provision.Reservation, primary VMNetwork) error {
+	networker := stubs.NewNetworkerStub(p.zbus)
+
+	netID := networkID(reservation.User, string(primary.NetworkID))
+	if err := networker.RemoveLegacyTap(netID); err != nil {
+		return errors.Wrapf(err, "could not remove legacy tap device of network %s", primary.NetworkID)
+	}
+
+	return nil
+}
+
+// buildVMIface returns the configuration of the interface of a vm with address ip
+// in the network resource netID. The gateway is only set if route is true
+func (p *Provisioner) buildVMIface(ctx context.Context, netID pkg.NetID, tap string, ip net.IP, route bool) (pkg.VMIface, error) {
+	networker := stubs.NewNetworkerStub(p.zbus)
+
+	subnet, err := networker.GetSubnet(netID)
+	if err != nil {
+		return pkg.VMIface{}, errors.Wrapf(err, "could not get network resource subnet")
+	}
+
+	if !subnet.Contains(ip) {
+		return pkg.VMIface{}, fmt.Errorf("IP %s is not part of local nr subnet %s", ip.String(), subnet.String())
+	}
+
+	iface := pkg.VMIface{
+		Tap: tap,
+		MAC: "", // rely on static IP configuration so we don't care here
+		AddressCIDR: net.IPNet{
+			IP:   ip,
+			Mask: subnet.Mask,
+		},
+	}
+
+	if !route {
+		return iface, nil
+	}
+
+	gw, err := networker.GetDefaultGwIP(netID)
+	if err != nil {
+		return pkg.VMIface{}, errors.Wrapf(err, "could not get network resource default gateway")
+	}
+	iface.GatewayIP = net.IP(gw)
+
+	return iface, nil
+}
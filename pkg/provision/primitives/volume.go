@@ -3,6 +3,7 @@ package primitives
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/rs/zerolog/log"
 	"github.com/threefoldtech/zos/pkg"
@@ -58,6 +59,43 @@ func (p *Provisioner) volumeProvision(ctx context.Context, reservation *provisio
 	return p.volumeProvisionImpl(ctx, reservation)
 }
 
+func (p *Provisioner) volumeUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	return p.volumeUpdateImpl(ctx, old, reservation)
+}
+
+// volumeUpdateImpl resizes the volume to the new requested size
+func (p *Provisioner) volumeUpdateImpl(ctx context.Context, old, reservation *provision.Reservation) (VolumeResult, error) {
+	var current, config Volume
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return VolumeResult{}, err
+	}
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return VolumeResult{}, err
+	}
+
+	if current.Type != config.Type {
+		return VolumeResult{}, fmt.Errorf("disk type of a volume cannot be updated")
+	}
+
+	storageClient := stubs.NewStorageModuleStub(p.zbus)
+
+	if current.Size != config.Size {
+		log.Info().
+			Str("id", reservation.ID).
+			Uint64("from", current.Size).
+			Uint64("to", config.Size).
+			Msg("resizing volume")
+
+		if err := storageClient.ResizeFilesystem(reservation.ID, config.Size*gigabyte); err != nil {
+			return VolumeResult{}, err
+		}
+	}
+
+	return VolumeResult{
+		ID: reservation.ID,
+	}, nil
+}
+
 func (p *Provisioner) volumeDecommission(ctx context.Context, reservation *provision.Reservation) error {
 	storageClient := stubs.NewStorageModuleStub(p.zbus)
 
@@ -0,0 +1,153 @@
+package primitives
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/provision"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+// Watch streams the state changes of the workloads provisioned by the provisioner
+func (p *Provisioner) Watch(ctx context.Context) <-chan provision.StateChange {
+	ch := make(chan provision.StateChange)
+
+	go func() {
+		defer close(ch)
+
+		for {
+			if err := p.watchContainers(ctx, ch); err != nil {
+				log.Error().Err(err).Msg("failed to watch containers state")
+			}
+
+			// the stream is closed when contd restarts
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+
+	return ch
+}
+
+func (p *Provisioner) watchContainers(ctx context.Context, ch chan<- provision.StateChange) error {
+	containers := stubs.NewContainerModuleStub(p.zbus)
+
+	events, err := containers.Events(ctx)
+	if err != nil {
+		return err
+	}
+
+	for event := range events {
+		if event.Namespace == zdbContainerNS {
+			// 0-db containers are shared by multiple reservations
+			continue
+		}
+
+		if event.State == pkg.ContainerStopped || event.State == pkg.ContainerPaused {
+			// the engine already reports the reservation as suspended
+			continue
+		}
+
+		change, err := p.containerStateChange(event)
+		if err != nil {
+			log.Debug().Err(err).Str("container", string(event.Container)).Msg("skipping container event")
+			continue
+		}
+
+		select {
+		case ch <- change:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// containerStateChange converts a container event to the state change
+// of the reservation of the container
+func (p *Provisioner) containerStateChange(event pkg.ContainerEvent) (change provision.StateChange, err error) {
+	reservation, err := p.cache.Get(string(event.Container))
+	if err != nil {
+		return change, err
+	}
+
+	if reservation.Type != ContainerReservation {
+		return change, fmt.Errorf("reservation %s is not a container", reservation.ID)
+	}
+
+	result, err := p.containerResult(reservation, event.Namespace)
+	if err != nil {
+		return change, err
+	}
+
+	change.ID = reservation.ID
+	change.Result = result
+	if event.State != pkg.ContainerRunning {
+		change.Err = errors.New(event.Message)
+	}
+
+	return change, nil
+}
+
+// containerResult builds the result of a running container reservation
+func (p *Provisioner) containerResult(reservation *provision.Reservation, ns string) (ContainerResult, error) {
+	var config Container
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return ContainerResult{}, err
+	}
+
+	id := reservation.ID
+	if reservation.Reference != "" {
+		id = reservation.Reference
+	}
+
+	result := ContainerResult{ID: id}
+	if len(config.Network.IPs) > 0 {
+		result.IPv4 = config.Network.IPs[0].String()
+	}
+
+	if config.Network.PublicIP6 {
+		if ip, err := p.containerIP6(ns, pkg.ContainerID(id)); err == nil {
+			result.IPv6 = ip
+		}
+	}
+
+	return result, nil
+}
+
+// containerIP6 returns the public ipv6 of a running container
+func (p *Provisioner) containerIP6(ns string, id pkg.ContainerID) (string, error) {
+	var (
+		containers = stubs.NewContainerModuleStub(p.zbus)
+		network    = stubs.NewNetworkerStub(p.zbus)
+	)
+
+	info, err := containers.Inspect(ns, id)
+	if err != nil {
+		return "", err
+	}
+
+	ips, err := network.Addrs("pub", info.Network.Namespace)
+	if err != nil {
+		return "", err
+	}
+
+	for _, ip := range ips {
+		if isPublic(ip) {
+			return net.IP(ip).String(), nil
+		}
+	}
+
+	return "", fmt.Errorf("no public ipv6 found")
+}
@@ -107,6 +107,35 @@ func (p *Provisioner) zdbProvisionImpl(ctx context.Context, reservation *provisi
 	}, nil
 }
 
+func (p *Provisioner) zdbUpdate(ctx context.Context, old, reservation *provision.Reservation) (interface{}, error) {
+	return p.zdbUpdateImpl(ctx, old, reservation)
+}
+
+// zdbUpdateImpl applies the new size, password and public flag of the namespace.
+// The mode and disk type of a namespace cannot change since they define which 0-db
+// instance is holding the namespace
+func (p *Provisioner) zdbUpdateImpl(ctx context.Context, old, reservation *provision.Reservation) (ZDBResult, error) {
+	var current, config ZDB
+	if err := json.Unmarshal(old.Data, &current); err != nil {
+		return ZDBResult{}, errors.Wrap(err, "failed to decode reservation schema")
+	}
+	if err := json.Unmarshal(reservation.Data, &config); err != nil {
+		return ZDBResult{}, errors.Wrap(err, "failed to decode reservation schema")
+	}
+
+	if current.Mode != config.Mode {
+		return ZDBResult{}, fmt.Errorf("mode of a 0-db namespace cannot be updated")
+	}
+
+	if current.DiskType != config.DiskType {
+		return ZDBResult{}, fmt.Errorf("disk type of a 0-db namespace cannot be updated")
+	}
+
+	// provisioning a 0-db namespace is idempotent, it will find the existing
+	// allocation and apply size, password and public flag on the namespace
+	return p.zdbProvisionImpl(ctx, reservation)
+}
+
 func (p *Provisioner) ensureZdbContainer(ctx context.Context, allocation pkg.Allocation, mode pkg.ZDBMode) (pkg.Container, error) {
 	var container = stubs.NewContainerModuleStub(p.zbus)
 
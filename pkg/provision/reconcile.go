@@ -0,0 +1,133 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultReconcileInterval is the default time between two checks
+// of the provisioned workloads
+const defaultReconcileInterval = 10 * time.Minute
+
+// check runs the checker of the reservation type against the reservation
+// it returns nil if the type has no checker
+func (e *Engine) check(ctx context.Context, r *Reservation) error {
+	fn, ok := e.checkers[r.Type]
+	if !ok {
+		return nil
+	}
+
+	// workloads migrated from an old reservation are deployed
+	// using the reference as ID
+	c := *r
+	if c.Reference != "" {
+		c.ID = c.Reference
+	}
+
+	return fn(ctx, &c)
+}
+
+// reconcile checks all the cached reservations against the actual state
+// of the node, and sends the reservations that drifted to out
+func (e *Engine) reconcile(ctx context.Context, out chan<- *Reservation) {
+	reservations, err := e.cache.List()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list cached reservations")
+		return
+	}
+
+	for _, r := range reservations {
+		if r.Expired() || r.ToDelete {
+			// will be decommissioned
+			continue
+		}
+
+		err := e.check(ctx, r)
+		if err == nil {
+			continue
+		}
+
+		log.Warn().Err(err).Str("id", r.ID).Str("type", string(r.Type)).Msg("workload drift detected")
+		select {
+		case out <- r:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcileLoop periodically checks the provisioned workloads
+func (e *Engine) reconcileLoop(ctx context.Context, out chan<- *Reservation) {
+	ticker := time.NewTicker(e.reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.reconcile(ctx, out)
+		}
+	}
+}
+
+// redeploy provisions again a reservation that drifted from its
+// provisioned state. The result of the redeploy is sent to the feedback
+// so the user knows about the drift if the workload could not be restored
+func (e *Engine) redeploy(ctx context.Context, r *Reservation) {
+	// the reservation might have been updated or decommissioned
+	// since the drift was detected, so check again
+	cached, err := e.cache.Get(r.ID)
+	if err != nil {
+		log.Info().Str("id", r.ID).Msg("reservation not provisioned anymore, skip redeploy")
+		return
+	}
+
+	drift := e.check(ctx, cached)
+	if drift == nil {
+		return
+	}
+
+	fn, ok := e.provisioners[cached.Type]
+	if !ok {
+		log.Error().Str("id", cached.ID).Msgf("type of reservation not supported: %s", cached.Type)
+		return
+	}
+
+	log.Info().Str("id", cached.ID).Msg("redeploying workload")
+
+	realID := cached.ID
+	if cached.Reference != "" {
+		cached.ID = cached.Reference
+	}
+
+	result, err := fn(ctx, cached)
+	cached.ID = realID
+
+	if err == nil && cached.Suspended {
+		result, err = e.applySuspend(ctx, cached)
+	}
+
+	if err == nil {
+		// provisioners return early when they find the workload already
+		// deployed, so make sure the drift is actually resolved
+		if check := e.check(ctx, cached); check != nil {
+			err = fmt.Errorf("workload still not in expected state: %w", check)
+		}
+	}
+
+	if err != nil {
+		err = errors.Wrapf(err, "workload drift detected (%s), redeploy failed", drift)
+		log.Error().Err(err).Str("id", cached.ID).Msg("failed to redeploy workload")
+	} else {
+		log.Info().Str("id", cached.ID).Msg("workload redeployed")
+	}
+
+	if replyErr := e.reply(ctx, cached, err, result); replyErr != nil {
+		log.Error().Err(replyErr).Msg("failed to send result to BCDB")
+	}
+}
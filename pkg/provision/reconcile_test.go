@@ -0,0 +1,113 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type memCache map[string]*Reservation
+
+func (m memCache) Add(r *Reservation) error {
+	// like the fs cache, a reservation must be removed before it is added again
+	if _, ok := m[r.ID]; ok {
+		return fmt.Errorf("reservation %s already in the store", r.ID)
+	}
+	m[r.ID] = r
+	return nil
+}
+
+func (m memCache) Get(id string) (*Reservation, error) {
+	r, ok := m[id]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	cp := *r
+	return &cp, nil
+}
+
+func (m memCache) Remove(id string) error {
+	delete(m, id)
+	return nil
+}
+
+func (m memCache) Exists(id string) (bool, error) {
+	_, ok := m[id]
+	return ok, nil
+}
+
+func (m memCache) List() ([]*Reservation, error) {
+	var rs []*Reservation
+	for _, r := range m {
+		rs = append(rs, r)
+	}
+	return rs, nil
+}
+
+func (m memCache) Sync(Statser) error {
+	return nil
+}
+
+type testSigner struct{}
+
+func (testSigner) Sign(b []byte) ([]byte, error) {
+	return []byte("signature"), nil
+}
+
+func TestReconcile(t *testing.T) {
+	require := require.New(t)
+
+	deployed := map[string]bool{"1-1": true}
+	check := func(ctx context.Context, r *Reservation) error {
+		if !deployed[r.ID] {
+			return fmt.Errorf("workload %s not found", r.ID)
+		}
+		return nil
+	}
+
+	cache := memCache{}
+	for _, id := range []string{"1-1", "1-2"} {
+		cache.Add(&Reservation{
+			ID:       id,
+			Type:     "container",
+			Created:  time.Now(),
+			Duration: math.MaxInt64,
+		})
+	}
+
+	feedback := &testFeedback{}
+	e := New(EngineOps{
+		Cache:    cache,
+		Feedback: feedback,
+		Signer:   testSigner{},
+		Provisioners: map[ReservationType]ProvisionerFunc{
+			"container": func(ctx context.Context, r *Reservation) (interface{}, error) {
+				deployed[r.ID] = true
+				return nil, nil
+			},
+		},
+		Checkers: map[ReservationType]CheckerFunc{
+			"container": check,
+		},
+	})
+
+	out := make(chan *Reservation, 2)
+	e.reconcile(context.Background(), out)
+	require.Len(out, 1)
+
+	drifted := <-out
+	require.Equal("1-2", drifted.ID)
+
+	e.redeploy(context.Background(), drifted)
+	require.True(deployed["1-2"])
+	require.Equal([]string{"feedback-1-2"}, feedback.sent)
+
+	// nothing drifted anymore
+	e.reconcile(context.Background(), out)
+	require.Len(out, 0)
+}
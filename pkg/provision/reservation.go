@@ -34,11 +34,20 @@ type Reservation struct {
 	// Signature is the signature to the reservation
 	// it contains all the field of this struct except the signature itself and the Result field
 	Signature []byte `json:"signature,omitempty"`
+	// Challenge is the data signed by the user to create Signature.
+	// It is set by sources that receive reservations signed in their own format,
+	// if empty the canonical encoding returned by SigningBytes is used
+	Challenge []byte `json:"challenge,omitempty"`
 
 	// This flag is set to true when a reservation needs to be deleted
 	// before its expiration time
 	ToDelete bool `json:"to_delete"`
 
+	// Suspended is set to true when the workload needs to be suspended,
+	// e.g. because the pool runs out of capacity. It keeps its data and
+	// is resumed once the flag is unset
+	Suspended bool `json:"suspended,omitempty"`
+
 	// Tag object is mainly used for debugging.
 	Tag Tag `json:"-"`
 
@@ -106,15 +115,6 @@ func (r *Reservation) Expired() bool {
 }
 
 func (r *Reservation) validate() error {
-	// TODO: during testnet phase seems we don't need to verify this
-	// if err := Verify(r); err != nil {
-	// 	log.Warn().
-	// 		Err(err).
-	// 		Str("id", string(r.ID)).
-	// 		Msg("verification of reservation signature failed")
-	// 	return errors.Wrapf(err, "verification of reservation %s signature failed", r.ID)
-	// }
-
 	if r.Duration <= 0 {
 		return fmt.Errorf("reservation %s has not duration", r.ID)
 	}
@@ -159,6 +159,9 @@ type Result struct {
 	// if State is "error", then this field contains the error
 	// otherwise it's nil
 	Error string `json:"message"`
+	// Suspended is set on the ok results of suspended workloads, the
+	// explorer has no state for them
+	Suspended bool `json:"suspended,omitempty"`
 	// Data is the information generated by the provisioning of the workload
 	// its type depend on the reservation type
 	Data json.RawMessage `json:"data_json"`
@@ -0,0 +1,268 @@
+package provision
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// retryInterval is how often the engine checks the retry queue
+	retryInterval = 30 * time.Second
+	// retryMinBackoff is the delay before the first retry of an operation
+	retryMinBackoff = 30 * time.Second
+	// retryMaxBackoff is the maximum delay between two retries of an operation
+	retryMaxBackoff = time.Hour
+	// retryMaxAttempts is the number of times a failed provision is retried
+	// before giving up. Notifications are retried until they succeed
+	retryMaxAttempts = 5
+)
+
+// RetryKind is the type of operation stored in the RetryQueue
+type RetryKind string
+
+const (
+	// RetryProvision is a reservation that failed to be provisioned
+	RetryProvision RetryKind = "provision"
+	// RetryFeedback is a result that could not be sent to the Feedbacker
+	RetryFeedback RetryKind = "feedback"
+	// RetryDeleted is a deleted notification that could not be sent to the Feedbacker
+	RetryDeleted RetryKind = "deleted"
+)
+
+// RetryItem is an operation waiting in the RetryQueue
+type RetryItem struct {
+	Kind RetryKind `json:"kind"`
+	// ID of the reservation
+	ID string `json:"id"`
+	// Reservation is set for RetryProvision items
+	Reservation *Reservation `json:"reservation,omitempty"`
+	// Result is set for RetryFeedback items
+	Result *Result `json:"result,omitempty"`
+	// Attempts is the number of retries already done
+	Attempts    int       `json:"attempts"`
+	LastError   string    `json:"last_error"`
+	NextAttempt time.Time `json:"next_attempt"`
+}
+
+// Key returns the key of the item in the queue.
+// Feedback and deleted notifications of the same reservation share the same
+// key so only the latest notification is ever sent
+func (i *RetryItem) Key() string {
+	if i.Kind == RetryProvision {
+		return string(RetryProvision) + "-" + i.ID
+	}
+
+	return string(RetryFeedback) + "-" + i.ID
+}
+
+// retryBackoff returns the delay before the next retry of an operation
+// that already has been retried attempts times
+func retryBackoff(attempts int) time.Duration {
+	d := retryMinBackoff
+	for i := 0; i < attempts && d < retryMaxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > retryMaxBackoff {
+		d = retryMaxBackoff
+	}
+
+	return d
+}
+
+// retryLater queues a reservation that failed to be provisioned
+// if the reservation is already in the queue, only its last error is updated
+// since the attempts are accounted when the retry is dispatched
+func (e *Engine) retryLater(r *Reservation, cause error) {
+	if e.retries == nil {
+		return
+	}
+
+	item := &RetryItem{
+		Kind:        RetryProvision,
+		ID:          r.ID,
+		Reservation: r,
+		NextAttempt: time.Now().Add(retryBackoff(0)),
+	}
+
+	if existing, err := e.retries.Get(item.Key()); err == nil {
+		// keep the backoff of the queued item, but always retry
+		// the latest version of the reservation
+		item = existing
+		item.Reservation = r
+	}
+	item.LastError = cause.Error()
+
+	if err := e.retries.Push(item); err != nil {
+		log.Error().Err(err).Str("id", r.ID).Msg("failed to queue reservation for retry")
+	}
+}
+
+// retryDone removes a reservation from the retry queue
+// it is called once the reservation is provisioned or decommissioned
+func (e *Engine) retryDone(id string) {
+	if e.retries == nil {
+		return
+	}
+
+	item := RetryItem{Kind: RetryProvision, ID: id}
+	if err := e.retries.Remove(item.Key()); err != nil {
+		log.Error().Err(err).Str("id", id).Msg("failed to remove reservation from retry queue")
+	}
+}
+
+// notify sends a feedback or deleted notification to the Feedbacker
+// if sending fails, the notification is queued to be sent later
+func (e *Engine) notify(item *RetryItem) error {
+	e.notifyM.Lock()
+	defer e.notifyM.Unlock()
+
+	return e.send(item)
+}
+
+// send needs to be called with notifyM locked
+func (e *Engine) send(item *RetryItem) error {
+	var err error
+	switch item.Kind {
+	case RetryFeedback:
+		err = e.feedback.Feedback(e.nodeID, item.Result)
+	case RetryDeleted:
+		err = e.feedback.Deleted(e.nodeID, item.ID)
+	}
+
+	if e.retries == nil {
+		return err
+	}
+
+	if err == nil {
+		// any older notification for the same reservation is now outdated
+		if err := e.retries.Remove(item.Key()); err != nil {
+			log.Error().Err(err).Str("id", item.ID).Msg("failed to remove notification from retry queue")
+		}
+		return nil
+	}
+
+	item.LastError = err.Error()
+	item.NextAttempt = time.Now().Add(retryBackoff(item.Attempts))
+	if pushErr := e.retries.Push(item); pushErr != nil {
+		log.Error().Err(pushErr).Str("id", item.ID).Msg("failed to queue notification for retry")
+	}
+
+	return err
+}
+
+// resend retries the notification stored under key
+func (e *Engine) resend(key string) {
+	e.notifyM.Lock()
+	defer e.notifyM.Unlock()
+
+	// the notification might have been replaced or sent
+	// since the queue was listed
+	item, err := e.retries.Get(key)
+	if err != nil {
+		return
+	}
+
+	item.Attempts++
+	if err := e.send(item); err != nil {
+		log.Debug().Err(err).Str("id", item.ID).Msg("failed to resend notification")
+	}
+}
+
+// retry goes over the queue and retries the operations that are due.
+// reservations to provision again are sent to out so they go through the scheduler
+func (e *Engine) retry(ctx context.Context, out chan<- *Reservation) {
+	items, err := e.retries.List()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list retry queue")
+		return
+	}
+
+	now := time.Now()
+	for _, item := range items {
+		if item.NextAttempt.After(now) {
+			continue
+		}
+
+		if item.Kind != RetryProvision {
+			e.resend(item.Key())
+			continue
+		}
+
+		if item.Attempts >= retryMaxAttempts {
+			log.Warn().Str("id", item.ID).Str("error", item.LastError).Msg("giving up provisioning reservation")
+			e.retryDone(item.ID)
+			continue
+		}
+
+		// reserve the next attempt before dispatching, so the reservation
+		// is not dispatched again while it is still being processed
+		item.Attempts++
+		item.NextAttempt = now.Add(retryBackoff(item.Attempts))
+		if err := e.retries.Push(item); err != nil {
+			log.Error().Err(err).Str("id", item.ID).Msg("failed to update retry queue")
+			continue
+		}
+
+		log.Info().Str("id", item.ID).Int("attempt", item.Attempts).Msg("retrying reservation provision")
+		select {
+		case out <- item.Reservation:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// retryLoop periodically retries the operations from the retry queue
+func (e *Engine) retryLoop(ctx context.Context, out chan<- *Reservation) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+
+	for {
+		// first pass runs right away to send what was left over from a previous run
+		e.retry(ctx, out)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pending implements the pkg.ProvisionMonitor interface
+// it returns the operations waiting in the retry queue
+func (e *Engine) Pending() ([]pkg.ProvisionRetry, error) {
+	if e.retries == nil {
+		return nil, nil
+	}
+
+	items, err := e.retries.List()
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]pkg.ProvisionRetry, 0, len(items))
+	for _, item := range items {
+		p := pkg.ProvisionRetry{
+			Kind:        string(item.Kind),
+			ID:          item.ID,
+			Attempts:    item.Attempts,
+			LastError:   item.LastError,
+			NextAttempt: item.NextAttempt,
+		}
+		if item.Reservation != nil {
+			p.Type = string(item.Reservation.Type)
+		} else if item.Result != nil {
+			p.Type = string(item.Result.Type)
+		}
+
+		pending = append(pending, p)
+	}
+
+	return pending, nil
+}
@@ -0,0 +1,149 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/models/generated/directory"
+)
+
+type memQueue map[string]*RetryItem
+
+func (m memQueue) Push(item *RetryItem) error {
+	cp := *item
+	m[item.Key()] = &cp
+	return nil
+}
+
+func (m memQueue) Get(key string) (*RetryItem, error) {
+	item, ok := m[key]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	cp := *item
+	return &cp, nil
+}
+
+func (m memQueue) Remove(key string) error {
+	delete(m, key)
+	return nil
+}
+
+func (m memQueue) List() ([]*RetryItem, error) {
+	var items []*RetryItem
+	for _, item := range m {
+		cp := *item
+		items = append(items, &cp)
+	}
+	return items, nil
+}
+
+type testFeedback struct {
+	err     error
+	sent    []string
+	results []Result
+}
+
+func (f *testFeedback) Feedback(nodeID string, r *Result) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, fmt.Sprintf("feedback-%s", r.ID))
+	f.results = append(f.results, *r)
+	return nil
+}
+
+func (f *testFeedback) Deleted(nodeID, id string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, fmt.Sprintf("deleted-%s", id))
+	return nil
+}
+
+func (f *testFeedback) UpdateStats(nodeID string, w directory.WorkloadAmount, u directory.ResourceAmount) error {
+	return nil
+}
+
+func TestRetryBackoff(t *testing.T) {
+	assert.Equal(t, retryMinBackoff, retryBackoff(0))
+	assert.Equal(t, 2*retryMinBackoff, retryBackoff(1))
+	assert.Equal(t, retryMaxBackoff, retryBackoff(100))
+}
+
+func TestRetryNotify(t *testing.T) {
+	require := require.New(t)
+
+	queue := memQueue{}
+	feedback := &testFeedback{err: fmt.Errorf("explorer unreachable")}
+	e := New(EngineOps{Feedback: feedback, Retries: queue})
+
+	err := e.notify(&RetryItem{Kind: RetryFeedback, ID: "1-1", Result: &Result{ID: "1-1"}})
+	require.Error(err)
+	require.Len(queue, 1)
+
+	// a newer notification replaces the pending one
+	err = e.notify(&RetryItem{Kind: RetryDeleted, ID: "1-1"})
+	require.Error(err)
+	require.Len(queue, 1)
+
+	item, err := queue.Get((&RetryItem{Kind: RetryFeedback, ID: "1-1"}).Key())
+	require.NoError(err)
+	require.Equal(RetryDeleted, item.Kind)
+
+	// not due yet
+	e.retry(context.Background(), nil)
+	require.Empty(feedback.sent)
+
+	feedback.err = nil
+	item.NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(queue.Push(item))
+
+	e.retry(context.Background(), nil)
+	require.Equal([]string{"deleted-1-1"}, feedback.sent)
+	require.Empty(queue)
+}
+
+func TestRetryProvision(t *testing.T) {
+	require := require.New(t)
+
+	queue := memQueue{}
+	e := New(EngineOps{Retries: queue})
+
+	r := &Reservation{ID: "1-1", Type: "container"}
+	e.retryLater(r, fmt.Errorf("flist not reachable"))
+	require.Len(queue, 1)
+
+	key := (&RetryItem{Kind: RetryProvision, ID: "1-1"}).Key()
+	item, err := queue.Get(key)
+	require.NoError(err)
+	item.NextAttempt = time.Now().Add(-time.Second)
+	require.NoError(queue.Push(item))
+
+	out := make(chan *Reservation, 1)
+	e.retry(context.Background(), out)
+	require.Equal(r.ID, (<-out).ID)
+
+	// the next attempt is reserved while the reservation is processed
+	item, err = queue.Get(key)
+	require.NoError(err)
+	require.Equal(1, item.Attempts)
+	require.True(item.NextAttempt.After(time.Now()))
+
+	// a new version of the reservation replaces the queued one
+	updated := &Reservation{ID: "1-1", Type: "container", Data: []byte(`{"flist": "new"}`)}
+	e.retryLater(updated, fmt.Errorf("flist not reachable"))
+
+	item, err = queue.Get(key)
+	require.NoError(err)
+	require.Equal(1, item.Attempts)
+	require.Equal(updated.Data, item.Reservation.Data)
+
+	e.retryDone(r.ID)
+	require.Empty(queue)
+}
@@ -0,0 +1,116 @@
+package provision
+
+import (
+	"sync"
+)
+
+// job is a reservation waiting to be processed by one of the engine workers
+type job struct {
+	seq         uint64
+	reservation *Reservation
+	// redeploy is set when the job is to redeploy a reservation
+	// that drifted from its provisioned state
+	redeploy bool
+	// change is set when the job is to report a change of the
+	// state of a provisioned reservation
+	change *StateChange
+}
+
+// scheduler keeps track of all the reservations that have been handed to the
+// engine workers and are not yet fully processed.
+// It makes sure that two operations on the same reservation ID (or on the reservation
+// it references) are never executed at the same time and are executed in the order
+// they were received. It also makes sure a reservation is only processed once all the
+// reservations from the same user that have a lower provision order and were received
+// before it are done. This is what guarantees that a network is deployed before
+// a container that uses it, or a volume before the container that mounts it.
+type scheduler struct {
+	m    sync.Mutex
+	cond *sync.Cond
+
+	order   map[ReservationType]int
+	next    uint64
+	pending map[uint64]*Reservation
+}
+
+func newScheduler(order map[ReservationType]int) *scheduler {
+	s := &scheduler{
+		order:   order,
+		pending: make(map[uint64]*Reservation),
+	}
+	s.cond = sync.NewCond(&s.m)
+
+	return s
+}
+
+// add registers a reservation in the scheduler. It needs to be called
+// in the same order the reservations are received from the source
+func (s *scheduler) add(r *Reservation) job {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	s.next++
+	s.pending[s.next] = r
+
+	return job{seq: s.next, reservation: r}
+}
+
+// acquire blocks until the job is allowed to be processed
+func (s *scheduler) acquire(j job) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for s.blocked(j) {
+		s.cond.Wait()
+	}
+}
+
+// release marks the job as done and wakes up all the jobs waiting on it
+func (s *scheduler) release(j job) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	delete(s.pending, j.seq)
+	s.cond.Broadcast()
+}
+
+// blocked returns true if any job received before j needs to be
+// completed before j can be processed
+func (s *scheduler) blocked(j job) bool {
+	r := j.reservation
+	for seq, other := range s.pending {
+		if seq >= j.seq {
+			continue
+		}
+
+		if conflicts(r, other) {
+			return true
+		}
+
+		if r.User == other.User && s.order[other.Type] < s.order[r.Type] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// conflicts returns true if a and b point to the same workload
+func conflicts(a, b *Reservation) bool {
+	ids := func(r *Reservation) []string {
+		if r.Reference != "" {
+			return []string{r.ID, r.Reference}
+		}
+		return []string{r.ID}
+	}
+
+	for _, x := range ids(a) {
+		for _, y := range ids(b) {
+			if x == y {
+				return true
+			}
+		}
+	}
+
+	return false
+}
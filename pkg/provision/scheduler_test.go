@@ -0,0 +1,88 @@
+package provision
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerSameID(t *testing.T) {
+	require := require.New(t)
+	s := newScheduler(nil)
+
+	first := s.add(&Reservation{ID: "1-1", User: "1"})
+	second := s.add(&Reservation{ID: "1-1", User: "1", ToDelete: true})
+	other := s.add(&Reservation{ID: "2-1", User: "2"})
+
+	require.False(s.blocked(first))
+	require.True(s.blocked(second))
+	require.False(s.blocked(other))
+
+	s.release(first)
+	require.False(s.blocked(second))
+}
+
+func TestSchedulerReference(t *testing.T) {
+	require := require.New(t)
+	s := newScheduler(nil)
+
+	old := s.add(&Reservation{ID: "1-1", User: "1"})
+	migrated := s.add(&Reservation{ID: "2-1", User: "1", Reference: "1-1"})
+
+	require.False(s.blocked(old))
+	require.True(s.blocked(migrated))
+}
+
+func TestSchedulerOrder(t *testing.T) {
+	require := require.New(t)
+	s := newScheduler(map[ReservationType]int{
+		"network":   1,
+		"volume":    2,
+		"container": 3,
+	})
+
+	network := s.add(&Reservation{ID: "1-1", User: "1", Type: "network"})
+	volume := s.add(&Reservation{ID: "1-2", User: "1", Type: "volume"})
+	container := s.add(&Reservation{ID: "1-3", User: "1", Type: "container"})
+	otherUser := s.add(&Reservation{ID: "2-1", User: "2", Type: "container"})
+
+	require.False(s.blocked(network))
+	require.True(s.blocked(volume))
+	require.True(s.blocked(container))
+	require.False(s.blocked(otherUser))
+
+	s.release(network)
+	require.False(s.blocked(volume))
+	require.True(s.blocked(container))
+
+	s.release(volume)
+	require.False(s.blocked(container))
+}
+
+func TestSchedulerAcquire(t *testing.T) {
+	s := newScheduler(nil)
+
+	first := s.add(&Reservation{ID: "1-1"})
+	second := s.add(&Reservation{ID: "1-1"})
+
+	done := make(chan struct{})
+	go func() {
+		s.acquire(second)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("second job acquired while first still pending")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.release(first)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("second job never acquired")
+	}
+}
@@ -0,0 +1,66 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// suspend suspends or resumes the workload of a provisioned reservation
+// according to its Suspended flag
+func (e *Engine) suspend(ctx context.Context, r *Reservation) error {
+	action := "resuming"
+	if r.Suspended {
+		action = "suspending"
+	}
+	log.Info().Str("id", r.ID).Msgf("%s workload", action)
+
+	result, err := e.applySuspend(ctx, r)
+	if err != nil {
+		log.Error().Err(err).Str("id", r.ID).Msgf("failed %s workload", action)
+	}
+
+	if replyErr := e.reply(ctx, r, err, result); replyErr != nil {
+		log.Error().Err(replyErr).Msg("failed to send result to BCDB")
+	}
+
+	if err != nil {
+		e.retryLater(r, err)
+		return err
+	}
+
+	if err := e.cache.Remove(r.ID); err != nil {
+		return errors.Wrapf(err, "failed to remove old version of reservation %s from cache", r.ID)
+	}
+
+	if err := e.cache.Add(r); err != nil {
+		return errors.Wrapf(err, "failed to cache reservation %s locally", r.ID)
+	}
+
+	return nil
+}
+
+// applySuspend calls the suspender of the reservation type if the reservation
+// is suspended, or its resumer otherwise
+func (e *Engine) applySuspend(ctx context.Context, r *Reservation) (interface{}, error) {
+	fns, action := e.resumers, "resumed"
+	if r.Suspended {
+		fns, action = e.suspenders, "suspended"
+	}
+
+	fn, ok := fns[r.Type]
+	if !ok {
+		return nil, fmt.Errorf("reservation of type %s cannot be %s", r.Type, action)
+	}
+
+	// workloads migrated from an old reservation are deployed
+	// using the reference as ID
+	c := *r
+	if c.Reference != "" {
+		c.ID = c.Reference
+	}
+
+	return fn(ctx, &c)
+}
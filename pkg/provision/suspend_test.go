@@ -0,0 +1,96 @@
+package provision
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/tfexplorer/models/generated/directory"
+)
+
+type testStatser struct{}
+
+func (testStatser) Increment(r *Reservation) error { return nil }
+func (testStatser) Decrement(r *Reservation) error { return nil }
+func (testStatser) CurrentUnits() directory.ResourceAmount {
+	return directory.ResourceAmount{}
+}
+func (testStatser) CurrentWorkloads() directory.WorkloadAmount {
+	return directory.WorkloadAmount{}
+}
+
+func TestSuspend(t *testing.T) {
+	require := require.New(t)
+
+	var (
+		deployed  = map[string]bool{}
+		suspended = map[string]bool{}
+	)
+
+	cache := memCache{}
+	feedback := &testFeedback{}
+	e := New(EngineOps{
+		Cache:    cache,
+		Feedback: feedback,
+		Signer:   testSigner{},
+		Statser:  testStatser{},
+		Provisioners: map[ReservationType]ProvisionerFunc{
+			"container": func(ctx context.Context, r *Reservation) (interface{}, error) {
+				deployed[r.ID] = true
+				return nil, nil
+			},
+		},
+		Suspenders: map[ReservationType]SuspenderFunc{
+			"container": func(ctx context.Context, r *Reservation) (interface{}, error) {
+				suspended[r.ID] = true
+				return nil, nil
+			},
+		},
+		Resumers: map[ReservationType]SuspenderFunc{
+			"container": func(ctx context.Context, r *Reservation) (interface{}, error) {
+				suspended[r.ID] = false
+				return nil, nil
+			},
+		},
+	})
+
+	r := &Reservation{
+		ID:        "1-1",
+		Type:      "container",
+		Created:   time.Now(),
+		Duration:  math.MaxInt64,
+		Data:      []byte(`{}`),
+		Suspended: true,
+	}
+
+	// a new suspended reservation is deployed then suspended
+	require.NoError(e.provision(context.Background(), r))
+	require.True(deployed[r.ID])
+	require.True(suspended[r.ID])
+
+	cached, err := cache.Get(r.ID)
+	require.NoError(err)
+	require.True(cached.Suspended)
+
+	// suspended reservations can't be updated
+	update := *r
+	update.Data = []byte(`{"cpu": 2}`)
+	require.Error(e.provision(context.Background(), &update))
+
+	resumed := *r
+	resumed.Suspended = false
+	require.NoError(e.provision(context.Background(), &resumed))
+	require.False(suspended[r.ID])
+
+	cached, err = cache.Get(r.ID)
+	require.NoError(err)
+	require.False(cached.Suspended)
+
+	require.Len(feedback.results, 3)
+	require.Equal(StateOk, feedback.results[0].State)
+	require.True(feedback.results[0].Suspended)
+	require.Equal(StateError, feedback.results[1].State)
+	require.Equal(StateOk, feedback.results[2].State)
+}
@@ -0,0 +1,29 @@
+package provision
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+)
+
+// stateChanged sends an updated result of the reservation to the feedback
+// after the state of its workload changed
+func (e *Engine) stateChanged(ctx context.Context, r *Reservation, change *StateChange) {
+	// the reservation might have been decommissioned since
+	// the change was detected
+	cached, err := e.cache.Get(r.ID)
+	if err != nil || cached.ToDelete || cached.Expired() {
+		log.Info().Str("id", r.ID).Msg("reservation not provisioned anymore, skip state change")
+		return
+	}
+
+	if change.Err != nil {
+		log.Warn().Err(change.Err).Str("id", cached.ID).Msg("workload state changed")
+	} else {
+		log.Info().Str("id", cached.ID).Msg("workload is working again")
+	}
+
+	if err := e.reply(ctx, cached, change.Err, change.Result); err != nil {
+		log.Error().Err(err).Msg("failed to send result to BCDB")
+	}
+}
@@ -0,0 +1,45 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateChanged(t *testing.T) {
+	require := require.New(t)
+
+	cache := memCache{}
+	cache.Add(&Reservation{
+		ID:       "1-1",
+		Type:     "container",
+		Created:  time.Now(),
+		Duration: math.MaxInt64,
+	})
+
+	queue := memQueue{}
+	feedback := &testFeedback{}
+	e := New(EngineOps{
+		Cache:    cache,
+		Feedback: feedback,
+		Signer:   testSigner{},
+		Retries:  queue,
+	})
+
+	r := &Reservation{ID: "1-1"}
+	e.stateChanged(context.Background(), r, &StateChange{
+		ID:  r.ID,
+		Err: fmt.Errorf("container exited with code 1, restarting"),
+	})
+	require.Equal([]string{"feedback-1-1"}, feedback.sent)
+
+	// state changes of decommissioned reservations are not sent
+	require.NoError(cache.Remove(r.ID))
+	e.stateChanged(context.Background(), r, &StateChange{ID: r.ID})
+	require.Len(feedback.sent, 1)
+	require.Empty(queue)
+}
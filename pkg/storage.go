@@ -129,9 +129,21 @@ type VolumeAllocater interface {
 	// space which has been reserved for this filesystem will be reclaimed.
 	ReleaseFilesystem(name string) error
 
+	// ResizeFilesystem changes the maximum size of the named filesystem.
+	// If the filesystem grows, `ErrNotEnoughSpace` is returned in case the
+	// pool holding the filesystem does not have enough space left.
+	ResizeFilesystem(name string, size uint64) error
+
 	// Path return the path of the mountpoint of the named filesystem
 	// if no volume with name exists, an empty path and an error is returned
 	Path(name string) (path string, err error)
+
+	// SnapshotFilesystem creates a read-only snapshot of the named filesystem
+	// in the same pool. The snapshot is a filesystem on its own, it is not
+	// affected by later changes or by the removal of the source filesystem and
+	// must be released with ReleaseFilesystem when not needed anymore.
+	// Returns the path of the mountpoint of the snapshot
+	SnapshotFilesystem(name string, snapshot string) (string, error)
 }
 
 // VDisk info returned by a call to inspect
@@ -146,12 +158,21 @@ type VDisk struct {
 type VDiskModule interface {
 	// AllocateDisk with given id and size, return path to virtual disk
 	Allocate(id string, size int64) (string, error)
-	// DeallocateVDisk removes a virtual disk
+	// DeallocateVDisk removes a virtual disk, and the snapshots taken of it
 	Deallocate(id string) error
 	// Exists checks if disk with that ID already allocated
 	Exists(id string) bool
 	// Inspect return info about the disk
 	Inspect(id string) (VDisk, error)
+	// Resize grows the disk with given id to size, return path to virtual disk
+	Resize(id string, size int64) (string, error)
+	// Snapshot creates a new disk that is a copy-on-write clone of the
+	// disk id. Returns the id of the snapshot
+	Snapshot(id string) (string, error)
+	// Restore replaces the content of each disk of snapshots, a map of disk id
+	// to snapshot id, with the content of its snapshot created by Snapshot.
+	// The disks are only replaced once all the snapshots are cloned
+	Restore(snapshots map[string]string) error
 }
 
 // StorageModule defines the api for storage
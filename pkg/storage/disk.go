@@ -2,11 +2,16 @@ package storage
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+
 	"github.com/threefoldtech/zos/pkg"
 )
 
@@ -14,6 +19,9 @@ const (
 	vdiskVolumeName = "vdisks"
 
 	mib = 1024 * 1024
+
+	// ficlone is the FICLONE ioctl, the reflink of a whole file
+	ficlone = 0x40049409
 )
 
 type vdiskModule struct {
@@ -69,7 +77,7 @@ func (d *vdiskModule) safePath(id string) (string, error) {
 	return path, nil
 }
 
-// DeallocateVDisk removes a virtual disk
+// DeallocateVDisk removes a virtual disk and its snapshots
 func (d *vdiskModule) Deallocate(id string) error {
 	path, err := d.safePath(id)
 	if err != nil {
@@ -80,6 +88,22 @@ func (d *vdiskModule) Deallocate(id string) error {
 		return err
 	}
 
+	infos, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return errors.Wrap(err, "failed to list vdisks")
+	}
+
+	prefix := snapshotPrefix(id)
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), prefix) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(d.path, info.Name())); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove snapshot '%s'", info.Name())
+		}
+	}
+
 	return nil
 }
 
@@ -115,3 +139,136 @@ func (d *vdiskModule) Inspect(id string) (disk pkg.VDisk, err error) {
 	disk.Size = stat.Size()
 	return
 }
+
+// Resize grows the disk to size (in MB), disks cannot shrink
+func (d *vdiskModule) Resize(id string, size int64) (string, error) {
+	path, err := d.safePath(id)
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	grow := size*mib - info.Size()
+	if grow < 0 {
+		return "", fmt.Errorf("disk '%s' cannot shrink from %d to %d bytes", id, info.Size(), size*mib)
+	} else if grow == 0 {
+		return path, nil
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(d.path, &stat); err != nil {
+		return "", errors.Wrap(err, "failed to get free space of the vdisks volume")
+	}
+
+	if int64(stat.Bavail)*stat.Bsize < grow {
+		return "", pkg.ErrNotEnoughSpace{DeviceType: pkg.SSDDevice}
+	}
+
+	return path, syscall.Fallocate(int(file.Fd()), 0, 0, size*mib)
+}
+
+// snapshotPrefix is the prefix of the ids of the snapshots of disk id
+func snapshotPrefix(id string) string {
+	return fmt.Sprintf("%s-snapshot-", id)
+}
+
+// Snapshot creates a copy-on-write clone of the disk
+func (d *vdiskModule) Snapshot(id string) (string, error) {
+	path, err := d.safePath(id)
+	if err != nil {
+		return "", err
+	}
+
+	snapshot := fmt.Sprintf("%s%d", snapshotPrefix(id), time.Now().UnixNano())
+	target, err := d.safePath(snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		return "", errors.Wrapf(os.ErrExist, "disk with id '%s' already exists", snapshot)
+	}
+
+	if err := clone(path, target); err != nil {
+		return "", errors.Wrapf(err, "failed to snapshot disk '%s'", id)
+	}
+
+	return snapshot, nil
+}
+
+// Restore replaces the disks with a copy-on-write clone of their snapshot
+func (d *vdiskModule) Restore(snapshots map[string]string) (err error) {
+	// all the snapshots are cloned before any disk is replaced,
+	// so the disks are never left half restored
+	restored := make(map[string]string, len(snapshots))
+	defer func() {
+		for _, tmp := range restored {
+			os.Remove(tmp)
+		}
+	}()
+
+	for id, snapshot := range snapshots {
+		if !strings.HasPrefix(snapshot, snapshotPrefix(id)) {
+			return fmt.Errorf("'%s' is not a snapshot of disk '%s'", snapshot, id)
+		}
+
+		path, err := d.safePath(id)
+		if err != nil {
+			return err
+		}
+
+		source, err := d.safePath(snapshot)
+		if err != nil {
+			return err
+		}
+
+		tmp := path + ".restore"
+		os.Remove(tmp)
+		if err := clone(source, tmp); err != nil {
+			return errors.Wrapf(err, "failed to restore disk '%s' from '%s'", id, snapshot)
+		}
+		restored[path] = tmp
+	}
+
+	for path, tmp := range restored {
+		if err := os.Rename(tmp, path); err != nil {
+			return errors.Wrapf(err, "failed to replace disk '%s'", filepath.Base(path))
+		}
+		delete(restored, path)
+	}
+
+	return nil
+}
+
+// clone creates target as a copy-on-write clone of source, both files
+// must be on the same btrfs filesystem
+func clone(source, target string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := unix.IoctlSetInt(int(dst.Fd()), ficlone, int(src.Fd())); err != nil {
+		dst.Close()
+		os.Remove(target)
+		return errors.Wrap(err, "failed to clone file")
+	}
+
+	return dst.Close()
+}
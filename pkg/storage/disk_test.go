@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVDiskDeallocateSnapshots(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "vdisks")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	d := &vdiskModule{path: root}
+	for _, id := range []string{"disk", "disk-snapshot-1", "disk-snapshot-2", "disk2", "disk2-snapshot-1"} {
+		require.NoError(ioutil.WriteFile(filepath.Join(root, id), []byte(id), 0644))
+	}
+
+	// a disk is only restored from its own snapshots
+	err = d.Restore(map[string]string{"disk": "disk-snapshot-1", "disk2": "disk-snapshot-2"})
+	require.Error(err)
+
+	data, err := ioutil.ReadFile(filepath.Join(root, "disk"))
+	require.NoError(err)
+	require.Equal("disk", string(data))
+
+	require.NoError(d.Deallocate("disk"))
+
+	infos, err := ioutil.ReadDir(root)
+	require.NoError(err)
+
+	var ids []string
+	for _, info := range infos {
+		ids = append(ids, info.Name())
+	}
+	require.Equal([]string{"disk2", "disk2-snapshot-1"}, ids)
+}
@@ -359,6 +359,26 @@ func (p *btrfsPool) AddVolume(name string) (Volume, error) {
 	return p.addVolume(root)
 }
 
+func (p *btrfsPool) AddSnapshot(volume Volume, name string) (Volume, error) {
+	mnt, ok := p.Mounted()
+	if !ok {
+		return nil, ErrDeviceNotMounted
+	}
+
+	ctx := context.Background()
+	root := filepath.Join(mnt, name)
+	if err := p.utils.SubvolumeSnapshot(ctx, volume.Path(), root); err != nil {
+		return nil, err
+	}
+
+	info, err := p.utils.SubvolumeInfo(ctx, root)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBtrfsVolume(info.ID, root, p.utils), nil
+}
+
 func (p *btrfsPool) removeVolume(root string) error {
 	ctx := context.Background()
 
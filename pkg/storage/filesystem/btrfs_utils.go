@@ -139,6 +139,12 @@ func (u *BtrfsUtil) SubvolumeRemove(ctx context.Context, root string) error {
 	return err
 }
 
+// SubvolumeSnapshot creates a read-only snapshot of the subvolume source at target
+func (u *BtrfsUtil) SubvolumeSnapshot(ctx context.Context, source, target string) error {
+	_, err := u.run(ctx, "btrfs", "subvolume", "snapshot", "-r", source, target)
+	return err
+}
+
 // DeviceAdd adds a device to a btrfs pool
 func (u *BtrfsUtil) DeviceAdd(ctx context.Context, dev string, root string) error {
 	_, err := u.run(ctx, "btrfs", "device", "add", dev, root)
@@ -335,6 +335,19 @@ func TestBtrfsRemoveVolume(t *testing.T) {
 	require.NoError(err)
 }
 
+func TestBtrfsSnapshotVolume(t *testing.T) {
+	require := require.New(t)
+
+	var exec TestExecuter
+	utils := newUtils(&exec)
+
+	exec.On("run", mock.Anything, "btrfs", "subvolume", "snapshot", "-r", "/tmp/root/subvol1", "/tmp/root/snap1").
+		Return([]byte{}, nil)
+
+	err := utils.SubvolumeSnapshot(context.Background(), "/tmp/root/subvol1", "/tmp/root/snap1")
+	require.NoError(err)
+}
+
 func TestBtrfsQGroupLimit(t *testing.T) {
 	require := require.New(t)
 
@@ -56,6 +56,9 @@ type Pool interface {
 	AddVolume(name string) (Volume, error)
 	// RemoveVolume removes a subvolume with the given name
 	RemoveVolume(name string) error
+	// AddSnapshot creates a read-only snapshot of the volume as a new
+	// subvolume with the given name
+	AddSnapshot(volume Volume, name string) (Volume, error)
 	// Devices list attached devices
 	Devices() []*Device
 
@@ -382,6 +382,94 @@ func (s *storageModule) ReleaseFilesystem(name string) error {
 	return nil
 }
 
+// ResizeFilesystem changes the size limit of the named filesystem.
+// When the filesystem grows, the pool it lives in must have enough
+// unreserved space left to hold the new size
+func (s *storageModule) ResizeFilesystem(name string, size uint64) error {
+	log.Info().Str("name", name).Uint64("size", size).Msg("resizing volume")
+
+	for _, pool := range s.pools {
+		if _, mounted := pool.Mounted(); !mounted {
+			continue
+		}
+
+		volumes, err := pool.Volumes()
+		if err != nil {
+			return err
+		}
+
+		for _, volume := range volumes {
+			if volume.Name() != name {
+				continue
+			}
+
+			current, err := volume.Usage()
+			if err != nil {
+				return errors.Wrapf(err, "failed to get usage of volume %s", name)
+			}
+
+			if size > current.Size {
+				usage, err := pool.Usage()
+				if err != nil {
+					return errors.Wrapf(err, "failed to get usage of pool %s", pool.Name())
+				}
+
+				reserved, err := pool.Reserved()
+				if err != nil {
+					return errors.Wrapf(err, "failed to get reserved size of pool %s", pool.Name())
+				}
+
+				if reserved-current.Size+size > usage.Size {
+					return pkg.ErrNotEnoughSpace{DeviceType: pool.Type()}
+				}
+			}
+
+			return volume.Limit(size)
+		}
+	}
+
+	return errors.Wrapf(os.ErrNotExist, "subvolume '%s' not found", name)
+}
+
+// SnapshotFilesystem creates a read-only snapshot of the named filesystem
+// in the pool where it lives
+func (s *storageModule) SnapshotFilesystem(name, snapshot string) (string, error) {
+	log.Info().Str("name", name).Str("snapshot", snapshot).Msg("creating volume snapshot")
+	if strings.HasPrefix(snapshot, "zdb") {
+		return "", fmt.Errorf("invalid snapshot name. zdb prefix is reserved")
+	}
+
+	if _, err := s.Path(snapshot); err == nil {
+		return "", fmt.Errorf("a subvolume with name '%s' already exists", snapshot)
+	}
+
+	for _, pool := range s.pools {
+		if _, mounted := pool.Mounted(); !mounted {
+			continue
+		}
+
+		volumes, err := pool.Volumes()
+		if err != nil {
+			return "", err
+		}
+
+		for _, volume := range volumes {
+			if volume.Name() != name {
+				continue
+			}
+
+			snap, err := pool.AddSnapshot(volume, snapshot)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to snapshot volume %s", name)
+			}
+
+			return snap.Path(), nil
+		}
+	}
+
+	return "", errors.Wrapf(os.ErrNotExist, "subvolume '%s' not found", name)
+}
+
 // Path return the path of the mountpoint of the named filesystem
 // if no volume with name exists, an empty path and an error is returned
 func (s *storageModule) Path(name string) (string, error) {
@@ -122,6 +122,11 @@ func (p *testPool) RemoveVolume(name string) error {
 	return args.Error(1)
 }
 
+func (p *testPool) AddSnapshot(volume filesystem.Volume, name string) (filesystem.Volume, error) {
+	args := p.Called(volume, name)
+	return args.Get(0).(filesystem.Volume), args.Error(1)
+}
+
 func (p *testPool) Devices() []*filesystem.Device {
 	return []*filesystem.Device{}
 }
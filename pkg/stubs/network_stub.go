@@ -24,6 +24,22 @@ func NewNetworkerStub(client zbus.Client) *NetworkerStub {
 	}
 }
 
+func (s *NetworkerStub) AddPortForward(arg0 pkg.NetID, arg1 string, arg2 pkg.PortForward) (ret0 uint16, ret1 error) {
+	args := []interface{}{arg0, arg1, arg2}
+	result, err := s.client.Request(s.module, s.object, "AddPortForward", args...)
+	if err != nil {
+		panic(err)
+	}
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	ret1 = new(zbus.RemoteError)
+	if err := result.Unmarshal(1, &ret1); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *NetworkerStub) Addrs(arg0 string, arg1 string) (ret0 [][]uint8, ret1 error) {
 	args := []interface{}{arg0, arg1}
 	result, err := s.client.Request(s.module, s.object, "Addrs", args...)
@@ -40,6 +56,22 @@ func (s *NetworkerStub) Addrs(arg0 string, arg1 string) (ret0 [][]uint8, ret1 er
 	return
 }
 
+func (s *NetworkerStub) Bandwidth(arg0 pkg.NetID, arg1 string) (ret0 pkg.BandwidthUsage, ret1 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.Request(s.module, s.object, "Bandwidth", args...)
+	if err != nil {
+		panic(err)
+	}
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	ret1 = new(zbus.RemoteError)
+	if err := result.Unmarshal(1, &ret1); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *NetworkerStub) CreateNR(arg0 pkg.NetResource) (ret0 string, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.Request(s.module, s.object, "CreateNR", args...)
@@ -120,8 +152,8 @@ func (s *NetworkerStub) GetSubnet(arg0 pkg.NetID) (ret0 net.IPNet, ret1 error) {
 	return
 }
 
-func (s *NetworkerStub) Join(arg0 pkg.NetID, arg1 string, arg2 []string, arg3 bool) (ret0 pkg.Member, ret1 error) {
-	args := []interface{}{arg0, arg1, arg2, arg3}
+func (s *NetworkerStub) Join(arg0 pkg.NetID, arg1 string, arg2 []string, arg3 bool, arg4 pkg.Bandwidth) (ret0 pkg.Member, ret1 error) {
+	args := []interface{}{arg0, arg1, arg2, arg3, arg4}
 	result, err := s.client.Request(s.module, s.object, "Join", args...)
 	if err != nil {
 		panic(err)
@@ -149,6 +181,25 @@ func (s *NetworkerStub) Leave(arg0 pkg.NetID, arg1 string) (ret0 error) {
 	return
 }
 
+func (s *NetworkerStub) NetResourceCounters(ctx context.Context) (<-chan pkg.NetResourcesCounters, error) {
+	ch := make(chan pkg.NetResourcesCounters)
+	recv, err := s.client.Stream(ctx, s.module, s.object, "NetResourceCounters")
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(ch)
+		for event := range recv {
+			var obj pkg.NetResourcesCounters
+			if err := event.Unmarshal(&obj); err != nil {
+				panic(err)
+			}
+			ch <- obj
+		}
+	}()
+	return ch, nil
+}
+
 func (s *NetworkerStub) PublicAddresses(ctx context.Context) (<-chan pkg.NetlinkAddresses, error) {
 	ch := make(chan pkg.NetlinkAddresses)
 	recv, err := s.client.Stream(ctx, s.module, s.object, "PublicAddresses")
@@ -181,8 +232,47 @@ func (s *NetworkerStub) Ready() (ret0 error) {
 	return
 }
 
-func (s *NetworkerStub) RemoveTap(arg0 pkg.NetID) (ret0 error) {
+func (s *NetworkerStub) RemoveLegacyTap(arg0 pkg.NetID) (ret0 error) {
 	args := []interface{}{arg0}
+	result, err := s.client.Request(s.module, s.object, "RemoveLegacyTap", args...)
+	if err != nil {
+		panic(err)
+	}
+	ret0 = new(zbus.RemoteError)
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *NetworkerStub) RemovePortForward(arg0 string) (ret0 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.Request(s.module, s.object, "RemovePortForward", args...)
+	if err != nil {
+		panic(err)
+	}
+	ret0 = new(zbus.RemoteError)
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *NetworkerStub) RemovePubTap(arg0 string) (ret0 error) {
+	args := []interface{}{arg0}
+	result, err := s.client.Request(s.module, s.object, "RemovePubTap", args...)
+	if err != nil {
+		panic(err)
+	}
+	ret0 = new(zbus.RemoteError)
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *NetworkerStub) RemoveTap(arg0 pkg.NetID, arg1 string) (ret0 error) {
+	args := []interface{}{arg0, arg1}
 	result, err := s.client.Request(s.module, s.object, "RemoveTap", args...)
 	if err != nil {
 		panic(err)
@@ -194,8 +284,24 @@ func (s *NetworkerStub) RemoveTap(arg0 pkg.NetID) (ret0 error) {
 	return
 }
 
-func (s *NetworkerStub) SetupTap(arg0 pkg.NetID) (ret0 string, ret1 error) {
+func (s *NetworkerStub) SetupPubTap(arg0 string) (ret0 string, ret1 error) {
 	args := []interface{}{arg0}
+	result, err := s.client.Request(s.module, s.object, "SetupPubTap", args...)
+	if err != nil {
+		panic(err)
+	}
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	ret1 = new(zbus.RemoteError)
+	if err := result.Unmarshal(1, &ret1); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *NetworkerStub) SetupTap(arg0 pkg.NetID, arg1 string) (ret0 string, ret1 error) {
+	args := []interface{}{arg0, arg1}
 	result, err := s.client.Request(s.module, s.object, "SetupTap", args...)
 	if err != nil {
 		panic(err)
@@ -41,3 +41,19 @@ func (s *ProvisionMonitorStub) Counters(ctx context.Context) (<-chan pkg.Provisi
 	}()
 	return ch, nil
 }
+
+func (s *ProvisionMonitorStub) Pending() (ret0 []pkg.ProvisionRetry, ret1 error) {
+	args := []interface{}{}
+	result, err := s.client.Request(s.module, s.object, "Pending", args...)
+	if err != nil {
+		panic(err)
+	}
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	ret1 = new(zbus.RemoteError)
+	if err := result.Unmarshal(1, &ret1); err != nil {
+		panic(err)
+	}
+	return
+}
@@ -143,6 +143,35 @@ func (s *StorageModuleStub) ReleaseFilesystem(arg0 string) (ret0 error) {
 	return
 }
 
+func (s *StorageModuleStub) ResizeFilesystem(arg0 string, arg1 uint64) (ret0 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.Request(s.module, s.object, "ResizeFilesystem", args...)
+	if err != nil {
+		panic(err)
+	}
+	ret0 = new(zbus.RemoteError)
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	return
+}
+
+func (s *StorageModuleStub) SnapshotFilesystem(arg0 string, arg1 string) (ret0 string, ret1 error) {
+	args := []interface{}{arg0, arg1}
+	result, err := s.client.Request(s.module, s.object, "SnapshotFilesystem", args...)
+	if err != nil {
+		panic(err)
+	}
+	if err := result.Unmarshal(0, &ret0); err != nil {
+		panic(err)
+	}
+	ret1 = new(zbus.RemoteError)
+	if err := result.Unmarshal(1, &ret1); err != nil {
+		panic(err)
+	}
+	return
+}
+
 func (s *StorageModuleStub) Total(arg0 pkg.DeviceType) (ret0 uint64, ret1 error) {
 	args := []interface{}{arg0}
 	result, err := s.client.Request(s.module, s.object, "Total", args...)
@@ -34,7 +34,13 @@ type Upgrader struct {
 	FLister      pkg.Flister
 	Zinit        *zinit.Client
 	NoSelfUpdate bool
-	hub          hubClient
+	// PublisherKey is the hex encoded ed25519 key that signs the upgrade
+	// flists. Flists without a valid signature are not installed
+	PublisherKey string
+	// AllowUnsigned allows installing the flists without verifying them
+	// if there is no PublisherKey
+	AllowUnsigned bool
+	hub           hubClient
 }
 
 // Upgrade is the method that does a full upgrade flow
@@ -46,11 +52,27 @@ func (u *Upgrader) Upgrade(from, to FListEvent) error {
 	return u.applyUpgrade(from, to)
 }
 
+// mount mounts the flist read-only, verifying its signature. Without
+// a publisher key, the flist is only mounted if unsigned flists are allowed
+func (u *Upgrader) mount(flist listFListInfo) (string, error) {
+	opts := pkg.ReadOnlyMountOptions
+	url := u.hub.MountURL(flist.Fqdn())
+	if u.PublisherKey != "" {
+		// the signature is published next to the actual flist, not its symlinks
+		url = u.hub.MountURL(flist.Absolute())
+		opts.PublicKey = u.PublisherKey
+	} else if !u.AllowUnsigned {
+		return "", fmt.Errorf("no publisher key to verify flist %s", flist.Fqdn())
+	}
+
+	return u.FLister.Mount(url, u.hub.StorageURL(), opts)
+}
+
 // InstallBinary from a single flist.
 func (u *Upgrader) InstallBinary(flist RepoFList) error {
 	log.Info().Str("flist", flist.Fqdn()).Msg("start applying upgrade")
 
-	flistRoot, err := u.FLister.Mount(u.hub.MountURL(flist.Fqdn()), u.hub.StorageURL(), pkg.ReadOnlyMountOptions)
+	flistRoot, err := u.mount(flist.listFListInfo)
 	if err != nil {
 		return err
 	}
@@ -296,7 +318,7 @@ func (u *Upgrader) uninstall(flist listFListInfo) error {
 func (u *Upgrader) applyUpgrade(from, to FListEvent) error {
 	log.Info().Str("flist", to.Fqdn()).Str("version", to.TryVersion().String()).Msg("start applying upgrade")
 
-	flistRoot, err := u.FLister.Mount(u.hub.MountURL(to.Fqdn()), u.hub.StorageURL(), pkg.ReadOnlyMountOptions)
+	flistRoot, err := u.mount(to.listFListInfo)
 	if err != nil {
 		return err
 	}
@@ -1,22 +1,32 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"net"
+	"time"
 )
 
 //go:generate zbusc -module vmd -version 0.0.1 -name manager -package stubs github.com/threefoldtech/zos/pkg+VMModule stubs/vmd_stub.go
 
-// VMNetworkInfo structure
-type VMNetworkInfo struct {
+// VMIface is a network interface of a VM
+type VMIface struct {
 	// Tap device name
 	Tap string
 	// Mac address of the device
 	MAC string
-	// Address of the device in the form of cidr
+	// Address of the device in the form of cidr. If empty, the configuration
+	// of the interface is left to the VM (for example IPv6 autoconfiguration)
 	AddressCIDR net.IPNet
-	// Gateway gateway address
+	// Gateway gateway address, only set on the interface of the default route
 	GatewayIP net.IP
+}
+
+// VMNetworkInfo structure
+type VMNetworkInfo struct {
+	// Ifaces are the network interfaces of the VM, in order. The first
+	// interface is eth0 inside the VM
+	Ifaces []VMIface
 	// Nameservers dns servers
 	Nameservers []net.IP
 }
@@ -29,6 +39,16 @@ type VMDisk struct {
 	Root     bool
 }
 
+// VMCloudInit is the configuration of cloud-init inside the VM
+type VMCloudInit struct {
+	// Hostname of the VM
+	Hostname string
+	// UserData is the cloud-init user data, usually a #cloud-config document
+	UserData string
+	// SSHKeys are the public keys allowed to log in the VM
+	SSHKeys []string
+}
+
 // VM config structure
 type VM struct {
 	// virtual machine name, or ID
@@ -48,6 +68,10 @@ type VM struct {
 	// Disks are a list of disks that are going to
 	// be auto allocated on the provided storage path
 	Disks []VMDisk
+	// CloudInit (optional) configuration served to the VM by the firecracker
+	// metadata service. The kernel arguments are extended to point the cloud-init
+	// NoCloud datasource to it
+	CloudInit *VMCloudInit
 }
 
 // Validate vm data
@@ -72,9 +96,24 @@ func (vm *VM) Validate() error {
 		return fmt.Errorf("invalid cpu must be between 1 and 32")
 	}
 
+	if len(vm.Network.Ifaces) == 0 {
+		return fmt.Errorf("at least one network interface is required")
+	}
+
 	return nil
 }
 
+// VMState is the state of a VM
+type VMState string
+
+const (
+	// VMRunning the VM process is running
+	VMRunning VMState = "running"
+	// VMStopped the VM is not running, either it has been stopped with Stop
+	// or it has shut itself down. It can be started again with Start
+	VMStopped VMState = "stopped"
+)
+
 // VMInfo returned by the inspect method
 type VMInfo struct {
 	// Flag for enabling/disabling Hyperthreading
@@ -87,6 +126,57 @@ type VMInfo struct {
 
 	// Number of vCPUs (either 1 or an even number)
 	CPU int64
+
+	// State of the VM
+	State VMState
+}
+
+// VMCPUMetrics are the vCPU counters of a VM
+type VMCPUMetrics struct {
+	// ExitIOIn number of exits on io port reads
+	ExitIOIn uint64
+	// ExitIOOut number of exits on io port writes
+	ExitIOOut uint64
+	// ExitMMIORead number of exits on mmio reads
+	ExitMMIORead uint64
+	// ExitMMIOWrite number of exits on mmio writes
+	ExitMMIOWrite uint64
+	// Failures number of vCPU failures
+	Failures uint64
+}
+
+// VMBlockMetrics are the counters of all the disks of a VM
+type VMBlockMetrics struct {
+	ReadBytes  uint64
+	WriteBytes uint64
+	ReadCount  uint64
+	WriteCount uint64
+}
+
+// VMNetMetrics are the counters of all the network interfaces of a VM
+type VMNetMetrics struct {
+	RxBytes   uint64
+	TxBytes   uint64
+	RxPackets uint64
+	TxPackets uint64
+}
+
+// VMMetrics are the counters of a VM since it's been started. Firecracker
+// flushes its metrics every minute, so they can lag behind by as much.
+type VMMetrics struct {
+	CPU   VMCPUMetrics
+	Block VMBlockMetrics
+	Net   VMNetMetrics
+	// Updated is the time of the last metrics flush, zero if the VM didn't
+	// flush any metrics yet
+	Updated time.Time
+}
+
+// VMLog is a line of the serial console of a VM
+type VMLog struct {
+	// Name of the VM
+	Name string
+	Line string
 }
 
 // VMModule defines the virtual machine module interface
@@ -95,4 +185,28 @@ type VMModule interface {
 	Inspect(name string) (VMInfo, error)
 	Delete(name string) error
 	Exists(id string) bool
+
+	// Stop shuts the VM down. Its configuration is kept so it can be started
+	// again with Start
+	Stop(name string) error
+	// Start starts again a VM that has been stopped
+	Start(name string) error
+	// Reboot stops and starts the VM again
+	Reboot(name string) error
+	// RescanDisk notifies the running VM that its disk at path has been resized
+	RescanDisk(name, path string) error
+
+	// Snapshot pauses the running VM and writes its memory and state to the
+	// directory path, which must not exist. The writable disks of the VM are
+	// snapshotted with the VDiskModule at the same time. The VM is resumed
+	// once the snapshot is taken
+	Snapshot(name, path string) error
+	// Restore starts the VM from a snapshot taken with Snapshot. The VM must
+	// not be running, its disks are rolled back to their snapshot
+	Restore(name, path string) error
+
+	// Console streams the serial console output of all the VMs running on the node
+	Console(ctx context.Context) <-chan VMLog
+	// Metrics returns the counters of the running VM
+	Metrics(name string) (VMMetrics, error)
 }
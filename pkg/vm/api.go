@@ -0,0 +1,129 @@
+package vm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// apiTimeout is the timeout of the requests to the firecracker api, creating
+	// and loading snapshots of large vms can take a while
+	apiTimeout = 5 * time.Minute
+)
+
+// apiClient is a client of the firecracker api endpoints that
+// are not supported by the firecracker sdk
+type apiClient struct {
+	client http.Client
+}
+
+func newAPIClient(socket string) *apiClient {
+	return &apiClient{
+		client: http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+			Timeout: apiTimeout,
+		},
+	}
+}
+
+// apiError is the body of the error responses of the firecracker api
+type apiError struct {
+	FaultMessage string `json:"fault_message"`
+}
+
+func (c *apiClient) request(ctx context.Context, method, path string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(method, "http://localhost"+path, bytes.NewBuffer(data))
+	if err != nil {
+		return err
+	}
+
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return errors.Wrapf(err, "failed to request %s %s", method, path)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+
+	content, _ := ioutil.ReadAll(response.Body)
+	var apiErr apiError
+	if err := json.Unmarshal(content, &apiErr); err != nil || len(apiErr.FaultMessage) == 0 {
+		apiErr.FaultMessage = string(content)
+	}
+
+	return fmt.Errorf("%s %s failed with status %d: %s", method, path, response.StatusCode, apiErr.FaultMessage)
+}
+
+// Pause pauses the vCPUs of the vm
+func (c *apiClient) Pause(ctx context.Context) error {
+	return c.request(ctx, http.MethodPatch, "/vm", map[string]string{"state": "Paused"})
+}
+
+// Resume resumes the vCPUs of a paused vm
+func (c *apiClient) Resume(ctx context.Context) error {
+	return c.request(ctx, http.MethodPatch, "/vm", map[string]string{"state": "Resumed"})
+}
+
+// CreateSnapshot writes a full snapshot of a paused vm, the paths
+// are relative to the machine root
+func (c *apiClient) CreateSnapshot(ctx context.Context, state, memory string) error {
+	return c.request(ctx, http.MethodPut, "/snapshot/create", map[string]string{
+		"snapshot_type": "Full",
+		"snapshot_path": state,
+		"mem_file_path": memory,
+	})
+}
+
+// LoadSnapshot loads a snapshot in a firecracker process that has not been
+// configured yet. The vm is left paused.
+func (c *apiClient) LoadSnapshot(ctx context.Context, state, memory string) error {
+	return c.request(ctx, http.MethodPut, "/snapshot/load", map[string]string{
+		"snapshot_path": state,
+		"mem_file_path": memory,
+	})
+}
+
+// UpdateDrive makes firecracker open again the file of the drive, so a
+// running vm sees the new size of the file
+func (c *apiClient) UpdateDrive(ctx context.Context, id, path string) error {
+	return c.request(ctx, http.MethodPatch, "/drives/"+id, map[string]string{
+		"drive_id":     id,
+		"path_on_host": path,
+	})
+}
+
+// SetLogger configures the logger of a firecracker process that has not
+// been configured yet
+func (c *apiClient) SetLogger(ctx context.Context, logger *Logger) error {
+	return c.request(ctx, http.MethodPut, "/logger", logger)
+}
+
+// SetMetrics configures the metrics of a firecracker process that has not
+// been configured yet
+func (c *apiClient) SetMetrics(ctx context.Context, metrics *Metrics) error {
+	return c.request(ctx, http.MethodPut, "/metrics", metrics)
+}
@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClient(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "vm_api")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	socket := filepath.Join(root, "api.socket")
+	listener, err := net.Listen("unix", socket)
+	require.NoError(err)
+
+	type request struct {
+		Method string
+		Path   string
+		Body   map[string]string
+	}
+
+	var requests []request
+	server := http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			requests = append(requests, request{Method: r.Method, Path: r.URL.Path, Body: body})
+			if r.URL.Path == "/snapshot/load" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"fault_message": "cannot load snapshot"}`))
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+
+	ctx := context.Background()
+	api := newAPIClient(socket)
+
+	require.NoError(api.Pause(ctx))
+	require.NoError(api.CreateSnapshot(ctx, "/vmstate", "/memory"))
+
+	err = api.LoadSnapshot(ctx, "/vmstate", "/memory")
+	require.Error(err)
+	require.Contains(err.Error(), "cannot load snapshot")
+
+	require.Equal([]request{
+		{
+			Method: http.MethodPatch,
+			Path:   "/vm",
+			Body:   map[string]string{"state": "Paused"},
+		},
+		{
+			Method: http.MethodPut,
+			Path:   "/snapshot/create",
+			Body: map[string]string{
+				"snapshot_type": "Full",
+				"snapshot_path": "/vmstate",
+				"mem_file_path": "/memory",
+			},
+		},
+		{
+			Method: http.MethodPut,
+			Path:   "/snapshot/load",
+			Body: map[string]string{
+				"snapshot_path": "/vmstate",
+				"mem_file_path": "/memory",
+			},
+		},
+	}, requests)
+}
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	firecracker "github.com/firecracker-microvm/firecracker-go-sdk"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// mmdsAddress is the link local address where firecracker serves
+// the metadata of the machine
+const mmdsAddress = "169.254.169.254"
+
+// cloudInitArgs returns the kernel arguments that point the cloud-init
+// NoCloud datasource to the metadata service
+func cloudInitArgs() string {
+	return fmt.Sprintf("ds=nocloud-net;s=http://%s/latest/", mmdsAddress)
+}
+
+// cloudInitMetadata builds the metadata tree served to the machine. cloud-init
+// fetches the meta-data and user-data documents under the datasource url
+func cloudInitMetadata(name string, cfg pkg.VMCloudInit, network pkg.VMNetworkInfo) map[string]interface{} {
+	hostname := cfg.Hostname
+	if hostname == "" {
+		hostname = name
+	}
+
+	// meta-data is a yaml document, quoted strings are valid yaml scalars
+	var meta strings.Builder
+	fmt.Fprintf(&meta, "instance-id: %s\n", strconv.Quote(name))
+	fmt.Fprintf(&meta, "local-hostname: %s\n", strconv.Quote(hostname))
+	if len(cfg.SSHKeys) > 0 {
+		meta.WriteString("public-keys:\n")
+		for _, key := range cfg.SSHKeys {
+			fmt.Fprintf(&meta, "  - %s\n", strconv.Quote(key))
+		}
+	}
+
+	// cloud-init configures all the interfaces, the kernel only configures the first one
+	if len(network.Ifaces) > 0 {
+		meta.WriteString("network-interfaces: |\n")
+		for _, line := range strings.SplitAfter(networkInterfaces(network), "\n") {
+			if line != "" {
+				fmt.Fprintf(&meta, "  %s", line)
+			}
+		}
+	}
+
+	userData := cfg.UserData
+	if userData == "" {
+		userData = "#cloud-config\n"
+	}
+
+	return map[string]interface{}{
+		"latest": map[string]interface{}{
+			"meta-data": meta.String(),
+			"user-data": userData,
+		},
+	}
+}
+
+// setMetadata sets the metadata served to a running machine
+func (m *vmModuleImpl) setMetadata(name string, cfg pkg.VMCloudInit, network pkg.VMNetworkInfo) error {
+	client := firecracker.NewClient(m.socket(name), nil, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := client.PutMmds(ctx, cloudInitMetadata(name, cfg, network))
+	return err
+}
@@ -0,0 +1,52 @@
+package vm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestCloudInitMetadata(t *testing.T) {
+	require := require.New(t)
+
+	metadata := cloudInitMetadata("vm-1", pkg.VMCloudInit{
+		SSHKeys: []string{"ssh-ed25519 AAAA user@host"},
+	}, pkg.VMNetworkInfo{})
+
+	require.Equal(map[string]interface{}{
+		"latest": map[string]interface{}{
+			"meta-data": "instance-id: \"vm-1\"\n" +
+				"local-hostname: \"vm-1\"\n" +
+				"public-keys:\n" +
+				"  - \"ssh-ed25519 AAAA user@host\"\n",
+			"user-data": "#cloud-config\n",
+		},
+	}, metadata)
+
+	metadata = cloudInitMetadata("vm-1", pkg.VMCloudInit{
+		Hostname: "web",
+		UserData: "#cloud-config\npackages: [nginx]\n",
+	}, pkg.VMNetworkInfo{
+		Ifaces: []pkg.VMIface{{
+			Tap: "t-primary",
+			AddressCIDR: net.IPNet{
+				IP:   net.ParseIP("10.1.2.10").To4(),
+				Mask: net.CIDRMask(24, 32),
+			},
+		}},
+	})
+
+	require.Equal(map[string]interface{}{
+		"latest": map[string]interface{}{
+			"meta-data": "instance-id: \"vm-1\"\n" +
+				"local-hostname: \"web\"\n" +
+				"network-interfaces: |\n" +
+				"  auto eth0\n" +
+				"  iface eth0 inet static\n" +
+				"    address 10.1.2.10/24\n",
+			"user-data": "#cloud-config\npackages: [nginx]\n",
+		},
+	}, metadata)
+}
@@ -0,0 +1,93 @@
+package vm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// configPath is the path where the configuration of the vm is kept
+// so it can be started again after it stops
+func (m *vmModuleImpl) configPath(name string) string {
+	return filepath.Join(m.root, "config", name)
+}
+
+func (m *vmModuleImpl) saveConfig(vm pkg.VM) error {
+	path := m.configPath(vm.Name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(vm)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0600)
+}
+
+func (m *vmModuleImpl) loadConfig(name string) (pkg.VM, error) {
+	var vm pkg.VM
+	data, err := ioutil.ReadFile(m.configPath(name))
+	if err != nil {
+		return vm, err
+	}
+
+	if err := json.Unmarshal(data, &vm); err != nil {
+		return vm, errors.Wrapf(err, "failed to decode configuration of vm '%s'", name)
+	}
+
+	return vm, nil
+}
+
+func (m *vmModuleImpl) removeConfig(name string) error {
+	if err := os.Remove(m.configPath(name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Stop implements the VMModule.Stop interface
+func (m *vmModuleImpl) Stop(name string) error {
+	if !m.Exists(name) {
+		if _, err := m.loadConfig(name); err == nil {
+			// already stopped
+			return nil
+		}
+
+		return fmt.Errorf("machine '%s' does not exist", name)
+	}
+
+	return m.stop(name)
+}
+
+// Start implements the VMModule.Start interface
+func (m *vmModuleImpl) Start(name string) error {
+	if m.Exists(name) {
+		return fmt.Errorf("machine '%s' is already running", name)
+	}
+
+	vm, err := m.loadConfig(name)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("machine '%s' does not exist", name)
+	} else if err != nil {
+		return err
+	}
+
+	return m.run(vm)
+}
+
+// Reboot implements the VMModule.Reboot interface
+func (m *vmModuleImpl) Reboot(name string) error {
+	if err := m.Stop(name); err != nil {
+		return errors.Wrapf(err, "failed to stop machine '%s'", name)
+	}
+
+	return m.Start(name)
+}
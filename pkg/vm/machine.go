@@ -30,9 +30,10 @@ type Drive struct {
 
 // Interface nic struct
 type Interface struct {
-	ID  string `json:"iface_id"`
-	Tap string `json:"host_dev_name"`
-	Mac string `json:"guest_mac,omitempty"`
+	ID        string `json:"iface_id"`
+	Tap       string `json:"host_dev_name"`
+	Mac       string `json:"guest_mac,omitempty"`
+	AllowMMDS bool   `json:"allow_mmds_requests,omitempty"`
 }
 
 // Config struct
@@ -42,6 +43,17 @@ type Config struct {
 	HTEnabled bool  `json:"ht_enabled"`
 }
 
+// Logger firecracker logger config
+type Logger struct {
+	LogPath string `json:"log_path"`
+	Level   string `json:"level"`
+}
+
+// Metrics firecracker metrics config
+type Metrics struct {
+	MetricsPath string `json:"metrics_path"`
+}
+
 // Machine struct
 type Machine struct {
 	ID         string      `json:"-"`
@@ -49,6 +61,8 @@ type Machine struct {
 	Drives     []Drive     `json:"drives"`
 	Interfaces []Interface `json:"network-interfaces"`
 	Config     Config      `json:"machine-config"`
+	Logger     *Logger     `json:"logger,omitempty"`
+	Metrics    *Metrics    `json:"metrics,omitempty"`
 }
 
 func (m *Machine) root(base string) string {
@@ -139,7 +153,31 @@ func (m *Machine) Start(ctx context.Context, base string) error {
 
 	cfg.Close()
 
-	return jailed.exec(ctx, base)
+	return jailed.exec(ctx, base, "--config-file", "/config.json")
+}
+
+// Restore starts a firecracker process that is not configured, the
+// snapshot files are made available to it as /vmstate and /memory
+// so it can be loaded with the api.
+func (m *Machine) Restore(ctx context.Context, base, state, memory string) error {
+	root := m.root(base)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return errors.Wrap(err, "failed to create machine root")
+	}
+
+	// the drives must be available under the same name they
+	// had when the snapshot was taken
+	if _, err := m.jail(root); err != nil {
+		return errors.Wrap(err, "failed to jail files")
+	}
+
+	for src, name := range map[string]string{state: snapshotState, memory: snapshotMemory} {
+		if err := mount(src, filepath.Join(root, name)); err != nil {
+			return errors.Wrap(err, "failed to jail snapshot files")
+		}
+	}
+
+	return m.exec(ctx, base)
 }
 
 // Log returns machine log file path
@@ -147,7 +185,7 @@ func (m *Machine) Log(base string) string {
 	return filepath.Join(m.root(base), "machine.log")
 }
 
-func (m *Machine) exec(ctx context.Context, base string) error {
+func (m *Machine) exec(ctx context.Context, base string, fcArgs ...string) error {
 	// prepare command
 	// because the --daemonize flag does not work as expected
 	// we are daemonizing with `ash and &` so we can use cmd.Run().
@@ -175,9 +213,9 @@ func (m *Machine) exec(ctx context.Context, base string) error {
 		"--exec-file", fcBin,
 		"--node", "0",
 		"--", // fc flags starts here
-		"--config-file", "/config.json",
 		"--api-sock", "/api.socket",
 	}
+	args = append(args, fcArgs...)
 
 	const (
 		// if this is enabled machine will start in the
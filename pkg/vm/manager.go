@@ -10,6 +10,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/firecracker-microvm/firecracker-go-sdk/client/models"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"github.com/threefoldtech/zbus"
 	"github.com/threefoldtech/zos/pkg"
 )
 
@@ -30,7 +32,12 @@ const (
 
 // vmModuleImpl implements the VMModule interface
 type vmModuleImpl struct {
-	root string
+	root   string
+	client zbus.Client
+
+	m        sync.Mutex
+	monitors map[string]*monitor
+	console  *console
 }
 
 var (
@@ -40,20 +47,46 @@ var (
 )
 
 // NewVMModule creates a new instance of vm manager
-func NewVMModule(root string) (pkg.VMModule, error) {
+func NewVMModule(root string, client zbus.Client) (pkg.VMModule, error) {
 	if err := os.MkdirAll(FCSockDir, 0755); err != nil {
 		return nil, err
 	}
 
-	return &vmModuleImpl{
-		root: root,
-	}, nil
+	m := &vmModuleImpl{
+		root:     root,
+		client:   client,
+		monitors: make(map[string]*monitor),
+		console:  newConsole(),
+	}
+
+	// vms keep running when the module restarts
+	configs, err := ioutil.ReadDir(filepath.Join(root, "config"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, config := range configs {
+		if !m.Exists(config.Name()) {
+			continue
+		}
+
+		if err := m.startMonitor(config.Name()); err != nil {
+			log.Error().Err(err).Str("vm", config.Name()).Msg("failed to monitor vm")
+		}
+	}
+
+	return m, nil
+}
+
+// driveID returns the firecracker id of the disk at index
+func driveID(index int) string {
+	return fmt.Sprintf("%d", index+2)
 }
 
 func (m *vmModuleImpl) makeDevices(vm *pkg.VM) ([]Drive, error) {
 	var drives []Drive
 	for i, disk := range vm.Disks {
-		id := fmt.Sprintf("%d", i+2)
+		id := driveID(i)
 
 		drives = append(drives, Drive{
 			ID:         id,
@@ -111,35 +144,6 @@ func (m *vmModuleImpl) cleanFs(id string) error {
 	return os.RemoveAll(m.machineRoot(id))
 }
 
-func (m *vmModuleImpl) makeNetwork(vm *pkg.VM) (iface Interface, cmdline string, err error) {
-	netIP := vm.Network.AddressCIDR
-
-	nic := Interface{
-		ID:  "eth0",
-		Tap: vm.Network.Tap,
-		Mac: vm.Network.MAC,
-	}
-
-	dns0 := ""
-	dns1 := ""
-	if len(vm.Network.Nameservers) > 0 {
-		dns0 = vm.Network.Nameservers[0].String()
-	}
-	if len(vm.Network.Nameservers) > 1 {
-		dns1 = vm.Network.Nameservers[1].String()
-	}
-
-	cmdline = fmt.Sprintf("ip=%s::%s:%s:::off:%s:%s:",
-		netIP.IP.String(),
-		vm.Network.GatewayIP.String(),
-		net.IP(netIP.Mask).String(),
-		dns0,
-		dns1,
-	)
-
-	return nic, cmdline, nil
-}
-
 func (m *vmModuleImpl) tail(path string) (string, error) {
 	// fetch 2k of bytes from the path ?
 	// TODO: implement a better tail algo.
@@ -197,12 +201,30 @@ func (m *vmModuleImpl) Run(vm pkg.VM) error {
 		return errors.Wrap(err, "machine configuration validation failed")
 	}
 
-	ctx := context.Background()
+	if err := validateNetwork(vm.Network, vm.CloudInit != nil); err != nil {
+		return errors.Wrap(err, "machine configuration validation failed")
+	}
 
 	if m.Exists(vm.Name) {
 		return fmt.Errorf("a vm with same name already exists")
 	}
 
+	if err := m.saveConfig(vm); err != nil {
+		return errors.Wrap(err, "failed to save machine configuration")
+	}
+
+	if err := m.run(vm); err != nil {
+		_ = m.removeConfig(vm.Name)
+		return err
+	}
+
+	return nil
+}
+
+// run starts the vm process
+func (m *vmModuleImpl) run(vm pkg.VM) error {
+	ctx := context.Background()
+
 	// make sure to clean up previous roots just in case
 	if err := m.cleanFs(vm.Name); err != nil {
 		return err
@@ -219,17 +241,18 @@ func (m *vmModuleImpl) Run(vm pkg.VM) error {
 		kargs.WriteString(defaultKernelArgs)
 	}
 
-	nic, args, err := m.makeNetwork(&vm)
-	if err != nil {
-		return err
+	nics, args := makeNetwork(vm.Network)
+	if len(args) != 0 {
+		kargs.WriteRune(' ')
+		kargs.WriteString(args)
 	}
 
-	if kargs.Len() != 0 {
+	if vm.CloudInit != nil {
+		nics[0].AllowMMDS = true
 		kargs.WriteRune(' ')
+		kargs.WriteString(cloudInitArgs())
 	}
 
-	kargs.WriteString(args)
-
 	machine := Machine{
 		ID: vm.Name,
 		Boot: Boot{
@@ -242,30 +265,50 @@ func (m *vmModuleImpl) Run(vm pkg.VM) error {
 			Mem:       vm.Memory,
 			HTEnabled: false,
 		},
-		Interfaces: []Interface{
-			nic,
-		},
-		Drives: devices,
+		Interfaces: nics,
+		Drives:     devices,
+		Logger:     machineLogger(),
+		Metrics:    machineMetrics(),
 	}
 
 	defer func() {
 		if err != nil {
-			m.Delete(machine.ID)
+			m.stop(machine.ID)
 		}
 	}()
 
+	if err = m.startMonitor(machine.ID); err != nil {
+		return errors.Wrap(err, "failed to monitor machine")
+	}
+
 	logFile := machine.Log(m.root)
 
 	if err = machine.Start(ctx, m.root); err != nil {
 		return m.withLogs(logFile, err)
 	}
 
+	// wait for the machine to answer
+	if err = m.waitSocket(ctx, machine.ID); err != nil {
+		return m.withLogs(logFile, err)
+	}
+
+	if vm.CloudInit != nil {
+		if err = m.setMetadata(machine.ID, *vm.CloudInit, vm.Network); err != nil {
+			return errors.Wrap(err, "failed to set machine metadata")
+		}
+	}
+
+	return nil
+}
+
+// waitSocket waits for the api of the machine to answer
+func (m *vmModuleImpl) waitSocket(ctx context.Context, name string) error {
 	check := func() error {
-		if !m.Exists(machine.ID) {
-			return fmt.Errorf("failed to spawn vm machine process '%s'", machine.ID)
+		if !m.Exists(name) {
+			return fmt.Errorf("failed to spawn vm machine process '%s'", name)
 		}
 		//TODO: check unix connection
-		socket := m.socket(machine.ID)
+		socket := m.socket(name)
 		con, err := net.Dial("unix", socket)
 		if err != nil {
 			return err
@@ -278,17 +321,21 @@ func (m *vmModuleImpl) Run(vm pkg.VM) error {
 	ctx, cancel := context.WithTimeout(ctx, 6*time.Second)
 	defer cancel()
 
-	// wait for the machine to answer
-	if err = backoff.Retry(check, backoff.WithContext(backoff.NewConstantBackOff(2*time.Second), ctx)); err != nil {
-		return m.withLogs(logFile, err)
-	}
-
-	return nil
+	return backoff.Retry(check, backoff.WithContext(backoff.NewConstantBackOff(2*time.Second), ctx))
 }
 
 func (m *vmModuleImpl) Inspect(name string) (pkg.VMInfo, error) {
 	if !m.Exists(name) {
-		return pkg.VMInfo{}, fmt.Errorf("machine '%s' does not exist", name)
+		vm, err := m.loadConfig(name)
+		if err != nil {
+			return pkg.VMInfo{}, fmt.Errorf("machine '%s' does not exist", name)
+		}
+
+		return pkg.VMInfo{
+			CPU:    int64(vm.CPU),
+			Memory: vm.Memory,
+			State:  pkg.VMStopped,
+		}, nil
 	}
 
 	client := firecracker.NewClient(m.socket(name), nil, false)
@@ -301,9 +348,56 @@ func (m *vmModuleImpl) Inspect(name string) (pkg.VMInfo, error) {
 		CPU:       *cfg.Payload.VcpuCount,
 		Memory:    *cfg.Payload.MemSizeMib,
 		HtEnabled: *cfg.Payload.HtEnabled,
+		State:     pkg.VMRunning,
 	}, nil
 }
 
+// Metrics implements the VMModule.Metrics interface
+func (m *vmModuleImpl) Metrics(name string) (pkg.VMMetrics, error) {
+	m.m.Lock()
+	mon, ok := m.monitors[name]
+	m.m.Unlock()
+
+	if !ok || !m.Exists(name) {
+		return pkg.VMMetrics{}, fmt.Errorf("machine '%s' is not running", name)
+	}
+
+	return mon.Metrics(), nil
+}
+
+// RescanDisk implements the VMModule.RescanDisk interface
+func (m *vmModuleImpl) RescanDisk(name, path string) error {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' is not running", name)
+	}
+
+	vm, err := m.loadConfig(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load configuration of machine '%s'", name)
+	}
+
+	for i, disk := range vm.Disks {
+		if filepath.Clean(disk.Path) != filepath.Clean(path) {
+			continue
+		}
+
+		// drives are mounted in the machine root with their base name
+		api := newAPIClient(m.socket(name))
+		if err := api.UpdateDrive(context.Background(), driveID(i), filepath.Base(path)); err != nil {
+			return errors.Wrapf(err, "failed to update drive of disk '%s'", path)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("machine '%s' has no disk '%s'", name, path)
+}
+
+// Console implements the VMModule.Console interface
+func (m *vmModuleImpl) Console(ctx context.Context) <-chan pkg.VMLog {
+	return m.console.subscribe(ctx)
+}
+
 func (m *vmModuleImpl) find(name string) (int, error) {
 	const (
 		proc   = "/proc"
@@ -364,7 +458,17 @@ func (m *vmModuleImpl) find(name string) (int, error) {
 }
 
 func (m *vmModuleImpl) Delete(name string) error {
+	if err := m.stop(name); err != nil {
+		return err
+	}
+
+	return m.removeConfig(name)
+}
+
+// stop shuts the vm down and cleans up its files
+func (m *vmModuleImpl) stop(name string) error {
 	defer m.cleanFs(name)
+	defer m.stopMonitor(name)
 
 	pid, err := m.find(name)
 	if err != nil {
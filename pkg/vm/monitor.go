@@ -0,0 +1,318 @@
+package vm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+const (
+	// logsFifo is the named pipe firecracker writes its own logs to
+	logsFifo = "logs.fifo"
+	// metricsFifo is the named pipe firecracker flushes its metrics to
+	metricsFifo = "metrics.fifo"
+
+	// consoleInterval is the interval at which the console
+	// logs of the vms are checked for new lines
+	consoleInterval = 500 * time.Millisecond
+	// consoleBuffer is the size of the Console stream channels
+	consoleBuffer = 1024
+	// metricsMaxLine is the maximum length of a metrics flush
+	metricsMaxLine = 1024 * 1024
+)
+
+// machineLogger returns the logger config of the machines, the logs
+// are read by the monitor of the machine
+func machineLogger() *Logger {
+	return &Logger{
+		LogPath: "/" + logsFifo,
+		Level:   "Warning",
+	}
+}
+
+// machineMetrics returns the metrics config of the machines, the metrics
+// are read by the monitor of the machine
+func machineMetrics() *Metrics {
+	return &Metrics{
+		MetricsPath: "/" + metricsFifo,
+	}
+}
+
+// fcMetrics is the part of the metrics flushed by firecracker
+// that is exposed by the module
+type fcMetrics struct {
+	Timestamp int64 `json:"utc_timestamp_ms"`
+	VCPU      struct {
+		ExitIOIn      uint64 `json:"exit_io_in"`
+		ExitIOOut     uint64 `json:"exit_io_out"`
+		ExitMMIORead  uint64 `json:"exit_mmio_read"`
+		ExitMMIOWrite uint64 `json:"exit_mmio_write"`
+		Failures      uint64 `json:"failures"`
+	} `json:"vcpu"`
+	Block struct {
+		ReadBytes  uint64 `json:"read_bytes"`
+		WriteBytes uint64 `json:"write_bytes"`
+		ReadCount  uint64 `json:"read_count"`
+		WriteCount uint64 `json:"write_count"`
+	} `json:"block"`
+	Net struct {
+		RxBytes   uint64 `json:"rx_bytes_count"`
+		TxBytes   uint64 `json:"tx_bytes_count"`
+		RxPackets uint64 `json:"rx_packets_count"`
+		TxPackets uint64 `json:"tx_packets_count"`
+	} `json:"net"`
+}
+
+// addTo adds the flushed counters to metrics, firecracker only
+// flushes what has been counted since its previous flush
+func (f *fcMetrics) addTo(metrics *pkg.VMMetrics) {
+	metrics.CPU.ExitIOIn += f.VCPU.ExitIOIn
+	metrics.CPU.ExitIOOut += f.VCPU.ExitIOOut
+	metrics.CPU.ExitMMIORead += f.VCPU.ExitMMIORead
+	metrics.CPU.ExitMMIOWrite += f.VCPU.ExitMMIOWrite
+	metrics.CPU.Failures += f.VCPU.Failures
+
+	metrics.Block.ReadBytes += f.Block.ReadBytes
+	metrics.Block.WriteBytes += f.Block.WriteBytes
+	metrics.Block.ReadCount += f.Block.ReadCount
+	metrics.Block.WriteCount += f.Block.WriteCount
+
+	metrics.Net.RxBytes += f.Net.RxBytes
+	metrics.Net.TxBytes += f.Net.TxBytes
+	metrics.Net.RxPackets += f.Net.RxPackets
+	metrics.Net.TxPackets += f.Net.TxPackets
+
+	metrics.Updated = time.Unix(0, f.Timestamp*int64(time.Millisecond))
+}
+
+// monitor collects the metrics, logs and console output of a running vm
+type monitor struct {
+	name   string
+	cancel context.CancelFunc
+
+	m       sync.Mutex
+	metrics pkg.VMMetrics
+}
+
+// Metrics returns the metrics collected since the monitor started
+func (mon *monitor) Metrics() pkg.VMMetrics {
+	mon.m.Lock()
+	defer mon.m.Unlock()
+
+	return mon.metrics
+}
+
+// readMetrics reads the metrics flushed by firecracker to r until r is closed
+func (mon *monitor) readMetrics(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), metricsMaxLine)
+	for scanner.Scan() {
+		var flushed fcMetrics
+		if err := json.Unmarshal(scanner.Bytes(), &flushed); err != nil {
+			log.Error().Err(err).Str("vm", mon.name).Msg("failed to decode vm metrics")
+			continue
+		}
+
+		mon.m.Lock()
+		flushed.addTo(&mon.metrics)
+		mon.m.Unlock()
+	}
+}
+
+// readLogs forwards the logs of firecracker read from r until r is closed
+func (mon *monitor) readLogs(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		log.Info().Str("vm", mon.name).Msg(scanner.Text())
+	}
+}
+
+// follow sends the lines appended to the console log file until ctx is done
+func (mon *monitor) follow(ctx context.Context, path string, console *console) {
+	ticker := time.NewTicker(consoleInterval)
+	defer ticker.Stop()
+
+	var (
+		offset  int64
+		partial []byte
+	)
+
+	// only the lines written after the monitor started are sent
+	if info, err := os.Stat(path); err == nil {
+		offset = info.Size()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == offset {
+			continue
+		}
+
+		if info.Size() < offset {
+			// file has been truncated
+			offset = 0
+			partial = nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+
+		buf := make([]byte, info.Size()-offset)
+		n, err := file.ReadAt(buf, offset)
+		file.Close()
+		if n == 0 && err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to read vm console log")
+			continue
+		}
+		offset += int64(n)
+
+		partial = append(partial, buf[:n]...)
+		for {
+			index := bytes.IndexByte(partial, '\n')
+			if index < 0 {
+				break
+			}
+
+			console.send(pkg.VMLog{
+				Name: mon.name,
+				Line: string(bytes.TrimRight(partial[:index], "\r")),
+			})
+
+			partial = partial[index+1:]
+		}
+	}
+}
+
+// openFifo creates the named pipe at path if needed and opens it for reading.
+// It's opened read-write so opening does not block until firecracker
+// opens it, and reads don't reach EOF while firecracker restarts
+func openFifo(path string) (*os.File, error) {
+	if err := unix.Mkfifo(path, 0600); err != nil && err != unix.EEXIST {
+		return nil, errors.Wrapf(err, "failed to create fifo %s", path)
+	}
+
+	return os.OpenFile(path, os.O_RDWR, 0)
+}
+
+// startMonitor starts collecting the metrics, logs and console output of the
+// vm. It must be called before the vm starts, because firecracker fails
+// to start if nothing reads its fifos
+func (m *vmModuleImpl) startMonitor(name string) error {
+	m.stopMonitor(name)
+
+	root := filepath.Join(m.machineRoot(name), "root")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return errors.Wrap(err, "failed to create machine root")
+	}
+
+	metrics, err := openFifo(filepath.Join(root, metricsFifo))
+	if err != nil {
+		return err
+	}
+
+	logs, err := openFifo(filepath.Join(root, logsFifo))
+	if err != nil {
+		metrics.Close()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mon := &monitor{name: name, cancel: cancel}
+
+	go mon.readMetrics(metrics)
+	go mon.readLogs(logs)
+	go mon.follow(ctx, filepath.Join(root, "machine.log"), m.console)
+
+	go func() {
+		<-ctx.Done()
+		// closing the fifos unblocks the readers
+		metrics.Close()
+		logs.Close()
+	}()
+
+	m.m.Lock()
+	m.monitors[name] = mon
+	m.m.Unlock()
+
+	return nil
+}
+
+// stopMonitor stops the monitor of the vm if it has any
+func (m *vmModuleImpl) stopMonitor(name string) {
+	m.m.Lock()
+	defer m.m.Unlock()
+
+	mon, ok := m.monitors[name]
+	if !ok {
+		return
+	}
+
+	mon.cancel()
+	delete(m.monitors, name)
+}
+
+// console sends the console output of the
+// vms to the Console stream subscribers
+type console struct {
+	m           sync.Mutex
+	subscribers map[chan pkg.VMLog]struct{}
+}
+
+func newConsole() *console {
+	return &console{
+		subscribers: make(map[chan pkg.VMLog]struct{}),
+	}
+}
+
+// subscribe returns a channel that receives the console
+// lines of all the vms until ctx is done
+func (c *console) subscribe(ctx context.Context) <-chan pkg.VMLog {
+	ch := make(chan pkg.VMLog, consoleBuffer)
+
+	c.m.Lock()
+	c.subscribers[ch] = struct{}{}
+	c.m.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.m.Lock()
+		delete(c.subscribers, ch)
+		c.m.Unlock()
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (c *console) send(entry pkg.VMLog) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for ch := range c.subscribers {
+		select {
+		case ch <- entry:
+		default:
+			log.Warn().Str("vm", entry.Name).Msg("console subscriber is too slow, dropping line")
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestMachineMonitorConfig(t *testing.T) {
+	require := require.New(t)
+
+	// firecracker reads the logs and metrics paths from separate
+	// sections of its configuration file
+	data, err := json.Marshal(Machine{
+		Logger:  machineLogger(),
+		Metrics: machineMetrics(),
+	})
+	require.NoError(err)
+
+	var cfg struct {
+		Logger  map[string]string `json:"logger"`
+		Metrics map[string]string `json:"metrics"`
+	}
+	require.NoError(json.Unmarshal(data, &cfg))
+
+	require.Equal(map[string]string{"log_path": "/logs.fifo", "level": "Warning"}, cfg.Logger)
+	require.Equal(map[string]string{"metrics_path": "/metrics.fifo"}, cfg.Metrics)
+}
+
+func TestMonitorMetrics(t *testing.T) {
+	require := require.New(t)
+
+	flushes := strings.Join([]string{
+		`{"utc_timestamp_ms":1000,"vcpu":{"exit_io_in":10,"exit_mmio_write":2},"block":{"read_bytes":4096,"read_count":1},"net":{"rx_bytes_count":100,"rx_packets_count":2}}`,
+		`not json`,
+		`{"utc_timestamp_ms":61000,"vcpu":{"exit_io_in":5,"failures":1},"block":{"write_bytes":512,"write_count":1},"net":{"tx_bytes_count":50,"tx_packets_count":1}}`,
+	}, "\n")
+
+	mon := monitor{name: "vm"}
+	mon.readMetrics(strings.NewReader(flushes))
+
+	require.Equal(pkg.VMMetrics{
+		CPU: pkg.VMCPUMetrics{
+			ExitIOIn:      15,
+			ExitMMIOWrite: 2,
+			Failures:      1,
+		},
+		Block: pkg.VMBlockMetrics{
+			ReadBytes:  4096,
+			WriteBytes: 512,
+			ReadCount:  1,
+			WriteCount: 1,
+		},
+		Net: pkg.VMNetMetrics{
+			RxBytes:   100,
+			TxBytes:   50,
+			RxPackets: 2,
+			TxPackets: 1,
+		},
+		Updated: time.Unix(61, 0),
+	}, mon.Metrics())
+}
+
+func TestMonitorFollow(t *testing.T) {
+	require := require.New(t)
+
+	root, err := ioutil.TempDir("", "vm_console")
+	require.NoError(err)
+	defer os.RemoveAll(root)
+
+	path := filepath.Join(root, "machine.log")
+	require.NoError(ioutil.WriteFile(path, []byte("before monitor\n"), 0644))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	console := newConsole()
+	ch := console.subscribe(ctx)
+
+	mon := monitor{name: "vm"}
+	go mon.follow(ctx, path, console)
+	// give follow the time to skip the existing content
+	time.Sleep(consoleInterval / 2)
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(err)
+	defer file.Close()
+
+	_, err = file.WriteString("login: \r\nroot")
+	require.NoError(err)
+
+	select {
+	case entry := <-ch:
+		require.Equal(pkg.VMLog{Name: "vm", Line: "login: "}, entry)
+	case <-time.After(5 * consoleInterval):
+		require.Fail("console line not received")
+	}
+
+	// partial lines are only sent once they are complete
+	select {
+	case entry := <-ch:
+		require.Failf("unexpected console line", "%+v", entry)
+	case <-time.After(2 * consoleInterval):
+	}
+}
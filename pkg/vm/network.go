@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/threefoldtech/zos/pkg"
+)
+
+// makeNetwork returns the firecracker interfaces of the machine and
+// the kernel argument that configures the first one. The kernel only
+// applies a single ip= argument, and only for IPv4 addresses, the other
+// interfaces are configured by cloud-init, see networkInterfaces
+func makeNetwork(network pkg.VMNetworkInfo) ([]Interface, string) {
+	var nics []Interface
+	for i, iface := range network.Ifaces {
+		nics = append(nics, Interface{
+			ID:  ifaceName(i),
+			Tap: iface.Tap,
+			Mac: iface.MAC,
+		})
+	}
+
+	if len(network.Ifaces) == 0 || !kernelConfigured(network.Ifaces[0]) {
+		return nics, ""
+	}
+
+	return nics, ipArg(ifaceName(0), network.Ifaces[0], network.Nameservers)
+}
+
+// validateNetwork checks that all the interfaces with an address can be
+// configured. Without cloud-init, only the first interface can have one
+func validateNetwork(network pkg.VMNetworkInfo, cloudInit bool) error {
+	if cloudInit {
+		return nil
+	}
+
+	for i, iface := range network.Ifaces {
+		if iface.AddressCIDR.IP == nil {
+			continue
+		}
+
+		if i != 0 || !kernelConfigured(iface) {
+			return fmt.Errorf("interface %s can only be configured with cloud-init", ifaceName(i))
+		}
+	}
+
+	return nil
+}
+
+func ifaceName(i int) string {
+	return fmt.Sprintf("eth%d", i)
+}
+
+// kernelConfigured checks if the address of the interface can be
+// configured with the ip= kernel argument
+func kernelConfigured(iface pkg.VMIface) bool {
+	return iface.AddressCIDR.IP.To4() != nil
+}
+
+// ipArg returns the ip= kernel argument that configures the IPv4
+// interface as device
+func ipArg(device string, iface pkg.VMIface, nameservers []net.IP) string {
+	var dns [2]string
+	for i := 0; i < len(nameservers) && i < len(dns); i++ {
+		dns[i] = nameservers[i].String()
+	}
+
+	var gw string
+	if iface.GatewayIP != nil {
+		gw = iface.GatewayIP.String()
+	}
+
+	return fmt.Sprintf("ip=%s::%s:%s::%s:off:%s:%s:",
+		iface.AddressCIDR.IP.String(),
+		gw,
+		net.IP(iface.AddressCIDR.Mask).String(),
+		device,
+		dns[0],
+		dns[1],
+	)
+}
+
+// networkInterfaces returns the configuration of all the interfaces in the
+// interfaces(5) format, served to cloud-init in the network-interfaces
+// meta-data. The interfaces without address are configured by the VM with
+// IPv6 autoconfiguration
+func networkInterfaces(network pkg.VMNetworkInfo) string {
+	var buf strings.Builder
+	for i, iface := range network.Ifaces {
+		device := ifaceName(i)
+		fmt.Fprintf(&buf, "auto %s\n", device)
+
+		ip := iface.AddressCIDR.IP
+		if ip == nil {
+			fmt.Fprintf(&buf, "iface %s inet6 manual\n", device)
+			continue
+		}
+
+		family := "inet"
+		if ip.To4() == nil {
+			family = "inet6"
+		}
+
+		ones, _ := iface.AddressCIDR.Mask.Size()
+		fmt.Fprintf(&buf, "iface %s %s static\n", device, family)
+		fmt.Fprintf(&buf, "  address %s/%d\n", ip.String(), ones)
+
+		if iface.GatewayIP == nil {
+			continue
+		}
+
+		fmt.Fprintf(&buf, "  gateway %s\n", iface.GatewayIP.String())
+		if len(network.Nameservers) > 0 {
+			var dns []string
+			for _, ns := range network.Nameservers {
+				dns = append(dns, ns.String())
+			}
+			fmt.Fprintf(&buf, "  dns-nameservers %s\n", strings.Join(dns, " "))
+		}
+	}
+
+	return buf.String()
+}
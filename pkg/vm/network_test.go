@@ -0,0 +1,88 @@
+package vm
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/threefoldtech/zos/pkg"
+)
+
+func TestMakeNetwork(t *testing.T) {
+	require := require.New(t)
+
+	network := pkg.VMNetworkInfo{
+		Ifaces: []pkg.VMIface{
+			{
+				Tap: "t-primary",
+				AddressCIDR: net.IPNet{
+					IP:   net.ParseIP("10.1.2.10").To4(),
+					Mask: net.CIDRMask(24, 32),
+				},
+				GatewayIP: net.ParseIP("10.1.2.1"),
+			},
+			{
+				Tap: "t-secondary",
+				AddressCIDR: net.IPNet{
+					IP:   net.ParseIP("10.2.3.10").To4(),
+					Mask: net.CIDRMask(24, 32),
+				},
+			},
+			{
+				Tap: "p-public",
+			},
+			{
+				Tap: "t-ipv6",
+				AddressCIDR: net.IPNet{
+					IP:   net.ParseIP("fd00::10"),
+					Mask: net.CIDRMask(64, 128),
+				},
+			},
+		},
+		Nameservers: []net.IP{net.ParseIP("8.8.8.8"), net.ParseIP("8.8.4.4")},
+	}
+
+	nics, args := makeNetwork(network)
+
+	require.Equal([]Interface{
+		{ID: "eth0", Tap: "t-primary"},
+		{ID: "eth1", Tap: "t-secondary"},
+		{ID: "eth2", Tap: "p-public"},
+		{ID: "eth3", Tap: "t-ipv6"},
+	}, nics)
+
+	require.Equal("ip=10.1.2.10::10.1.2.1:255.255.255.0::eth0:off:8.8.8.8:8.8.4.4:", args)
+
+	require.Equal(
+		"auto eth0\n"+
+			"iface eth0 inet static\n"+
+			"  address 10.1.2.10/24\n"+
+			"  gateway 10.1.2.1\n"+
+			"  dns-nameservers 8.8.8.8 8.8.4.4\n"+
+			"auto eth1\n"+
+			"iface eth1 inet static\n"+
+			"  address 10.2.3.10/24\n"+
+			"auto eth2\n"+
+			"iface eth2 inet6 manual\n"+
+			"auto eth3\n"+
+			"iface eth3 inet6 static\n"+
+			"  address fd00::10/64\n",
+		networkInterfaces(network),
+	)
+
+	// only the first interface can be configured without cloud-init
+	require.NoError(validateNetwork(network, true))
+	require.Error(validateNetwork(network, false))
+
+	network.Ifaces = network.Ifaces[:1]
+	require.NoError(validateNetwork(network, false))
+
+	network.Ifaces[0].AddressCIDR = net.IPNet{
+		IP:   net.ParseIP("fd00::10"),
+		Mask: net.CIDRMask(64, 128),
+	}
+	require.Error(validateNetwork(network, false))
+
+	_, args = makeNetwork(network)
+	require.Empty(args)
+}
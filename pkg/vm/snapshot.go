@@ -0,0 +1,243 @@
+package vm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+
+	"github.com/threefoldtech/zos/pkg"
+	"github.com/threefoldtech/zos/pkg/stubs"
+)
+
+const (
+	// snapshotState is the file of the vm state in the snapshot directory
+	snapshotState = "vmstate"
+	// snapshotMemory is the file of the vm memory in the snapshot directory
+	snapshotMemory = "memory"
+	// snapshotManifest is the file describing the snapshot in the snapshot directory
+	snapshotManifest = "manifest.json"
+)
+
+// manifest describes a vm snapshot
+type manifest struct {
+	// VM is the configuration of the vm when the snapshot was taken
+	VM pkg.VM `json:"vm"`
+	// Disks maps the vdisks of the vm to their snapshot
+	Disks map[string]string `json:"disks"`
+}
+
+// Snapshot implements the VMModule.Snapshot interface
+func (m *vmModuleImpl) Snapshot(name, path string) (err error) {
+	if !m.Exists(name) {
+		return fmt.Errorf("machine '%s' is not running", name)
+	}
+
+	vm, err := m.loadConfig(name)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load configuration of machine '%s'", name)
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("snapshot directory '%s' already exists", path)
+	}
+
+	if err := os.MkdirAll(path, 0700); err != nil {
+		return errors.Wrap(err, "failed to create snapshot directory")
+	}
+
+	var (
+		vdisk = stubs.NewVDiskModuleStub(m.client)
+		api   = newAPIClient(m.socket(name))
+		ctx   = context.Background()
+		root  = filepath.Join(m.machineRoot(name), "root")
+		snap  = manifest{VM: vm, Disks: make(map[string]string)}
+	)
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, snapshot := range snap.Disks {
+			if err := vdisk.Deallocate(snapshot); err != nil {
+				log.Error().Err(err).Str("disk", snapshot).Msg("failed to clean up disk snapshot")
+			}
+		}
+		os.RemoveAll(path)
+	}()
+
+	if err := api.Pause(ctx); err != nil {
+		return errors.Wrap(err, "failed to pause machine")
+	}
+
+	defer func() {
+		if resumeErr := api.Resume(ctx); resumeErr != nil {
+			log.Error().Err(resumeErr).Str("vm", name).Msg("failed to resume machine")
+			if err == nil {
+				err = errors.Wrap(resumeErr, "failed to resume machine")
+			}
+		}
+	}()
+
+	// the disks are snapshotted while the machine is paused
+	// so they are consistent with the memory of the machine
+	for _, disk := range vm.Disks {
+		if disk.ReadOnly {
+			continue
+		}
+
+		id := filepath.Base(disk.Path)
+		snapshot, err := vdisk.Snapshot(id)
+		if err != nil {
+			return errors.Wrapf(err, "failed to snapshot disk '%s'", id)
+		}
+		snap.Disks[id] = snapshot
+	}
+
+	if err := api.CreateSnapshot(ctx, "/"+snapshotState, "/"+snapshotMemory); err != nil {
+		return errors.Wrap(err, "failed to create machine snapshot")
+	}
+
+	for _, file := range []string{snapshotState, snapshotMemory} {
+		if err := move(filepath.Join(root, file), filepath.Join(path, file)); err != nil {
+			return errors.Wrapf(err, "failed to move snapshot file '%s'", file)
+		}
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(path, snapshotManifest), data, 0600)
+}
+
+// Restore implements the VMModule.Restore interface
+func (m *vmModuleImpl) Restore(name, path string) (err error) {
+	if m.Exists(name) {
+		return fmt.Errorf("machine '%s' is running", name)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, snapshotManifest))
+	if err != nil {
+		return errors.Wrap(err, "failed to read snapshot manifest")
+	}
+
+	var snap manifest
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return errors.Wrap(err, "failed to decode snapshot manifest")
+	}
+
+	if snap.VM.Name != name {
+		return fmt.Errorf("snapshot is of machine '%s'", snap.VM.Name)
+	}
+
+	vdisk := stubs.NewVDiskModuleStub(m.client)
+	if err := vdisk.Restore(snap.Disks); err != nil {
+		return errors.Wrap(err, "failed to restore disks")
+	}
+
+	if err := m.cleanFs(name); err != nil {
+		return err
+	}
+
+	devices, err := m.makeDevices(&snap.VM)
+	if err != nil {
+		return err
+	}
+
+	nics, _ := makeNetwork(snap.VM.Network)
+	machine := Machine{
+		ID: name,
+		Boot: Boot{
+			Kernel: snap.VM.KernelImage,
+			Initrd: snap.VM.InitrdImage,
+		},
+		Interfaces: nics,
+		Drives:     devices,
+	}
+
+	defer func() {
+		if err != nil {
+			m.stop(name)
+		}
+	}()
+
+	if err = m.startMonitor(name); err != nil {
+		return errors.Wrap(err, "failed to monitor machine")
+	}
+
+	ctx := context.Background()
+	logFile := machine.Log(m.root)
+	err = machine.Restore(ctx, m.root, filepath.Join(path, snapshotState), filepath.Join(path, snapshotMemory))
+	if err != nil {
+		return m.withLogs(logFile, err)
+	}
+
+	if err = m.waitSocket(ctx, name); err != nil {
+		return m.withLogs(logFile, err)
+	}
+
+	api := newAPIClient(m.socket(name))
+	if err = api.SetLogger(ctx, machineLogger()); err != nil {
+		return errors.Wrap(err, "failed to configure machine logger")
+	}
+
+	if err = api.SetMetrics(ctx, machineMetrics()); err != nil {
+		return errors.Wrap(err, "failed to configure machine metrics")
+	}
+
+	if err = api.LoadSnapshot(ctx, "/"+snapshotState, "/"+snapshotMemory); err != nil {
+		return m.withLogs(logFile, errors.Wrap(err, "failed to load machine snapshot"))
+	}
+
+	if err = api.Resume(ctx); err != nil {
+		return errors.Wrap(err, "failed to resume machine")
+	}
+
+	// the metadata service is not part of the snapshot
+	if snap.VM.CloudInit != nil {
+		if err = m.setMetadata(name, *snap.VM.CloudInit, snap.VM.Network); err != nil {
+			return errors.Wrap(err, "failed to set machine metadata")
+		}
+	}
+
+	return m.saveConfig(snap.VM)
+}
+
+// move moves the file at source to target, even if they
+// are on different filesystems
+func move(source, target string) error {
+	if err := os.Rename(source, target); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(source)
+}